@@ -16,9 +16,12 @@ type TableAlterClause interface {
 
 // Unsafer interface represents a type of clause that may have the ability to
 // destroy data. Structs satisfying this interface can indicate whether or not
-// this particular clause destroys data.
+// this particular clause destroys data, and if so, why. UnsafeReason should
+// return a non-empty description, including what data could be lost, if and
+// only if Unsafe returns true.
 type Unsafer interface {
 	Unsafe() bool
+	UnsafeReason() string
 }
 
 ///// AddColumn ////////////////////////////////////////////////////////////////
@@ -69,6 +72,15 @@ func (dc DropColumn) Unsafe() bool {
 	return !dc.Column.Virtual
 }
 
+// UnsafeReason returns a description of why this clause is unsafe, or an empty
+// string if it is safe.
+func (dc DropColumn) UnsafeReason() string {
+	if !dc.Unsafe() {
+		return ""
+	}
+	return fmt.Sprintf("column %s is being dropped; all of its values would be lost", EscapeIdentifier(dc.Column.Name))
+}
+
 ///// AddIndex /////////////////////////////////////////////////////////////////
 
 // AddIndex represents an index that is present on the right-side ("to")
@@ -110,7 +122,8 @@ func (di DropIndex) Clause(mods StatementModifiers) string {
 
 ///// AlterIndex ///////////////////////////////////////////////////////////////
 
-// AlterIndex represents a change in an index's visibility in MySQL 8+.
+// AlterIndex represents a change in an index's visibility in MySQL 8+, or
+// equivalently whether an index is ignored in MariaDB 10.6+.
 type AlterIndex struct {
 	Index          *Index
 	NewInvisible   bool // true if index is being changed from visible to invisible
@@ -123,12 +136,16 @@ type AlterIndex struct {
 // ALTER TABLE will also have DROP and re-ADD clauses for this index, which
 // prevents use of an ALTER INDEX clause.)
 func (ai AlterIndex) Clause(mods StatementModifiers) string {
-	if !mods.Flavor.MySQLishMinVersion(8, 0) || (ai.alsoReordering && mods.StrictIndexOrder) {
+	if !mods.Flavor.InvisibleIndexes() || (ai.alsoReordering && mods.StrictIndexOrder) {
 		return ""
 	}
 	newVis := "VISIBLE"
-	if ai.NewInvisible {
+	if ai.NewInvisible && mods.Flavor.Vendor == VendorMariaDB {
+		newVis = "IGNORED"
+	} else if ai.NewInvisible {
 		newVis = "INVISIBLE"
+	} else if mods.Flavor.Vendor == VendorMariaDB {
+		newVis = "NOT IGNORED"
 	}
 	return fmt.Sprintf("ALTER INDEX %s %s", EscapeIdentifier(ai.Index.Name), newVis)
 }
@@ -146,7 +163,7 @@ type AddForeignKey struct {
 // Clause returns an ADD CONSTRAINT ... FOREIGN KEY clause of an ALTER TABLE
 // statement.
 func (afk AddForeignKey) Clause(mods StatementModifiers) string {
-	if !mods.StrictForeignKeyNaming && afk.renameOnly {
+	if (!mods.StrictForeignKeyNaming && afk.renameOnly) || mods.Vitess == VitessStrip {
 		return ""
 	}
 	return fmt.Sprintf("ADD %s", afk.ForeignKey.Definition(mods.Flavor))
@@ -256,6 +273,11 @@ func (rc RenameColumn) Unsafe() bool {
 	return true
 }
 
+// UnsafeReason returns a description of why this clause is unsafe.
+func (rc RenameColumn) UnsafeReason() string {
+	return fmt.Sprintf("column %s is being renamed to %s; queries using the old name would fail", EscapeIdentifier(rc.OldColumn.Name), EscapeIdentifier(rc.NewName))
+}
+
 ///// ModifyColumn /////////////////////////////////////////////////////////////
 // for changing type, nullable, auto-incr, default, and/or position
 
@@ -308,27 +330,40 @@ func (mc ModifyColumn) Clause(mods StatementModifiers) string {
 // Unsafe returns true if this clause is potentially destructive of data.
 // ModifyColumn's safety depends on the nature of the column change; for example,
 // increasing the size of a varchar is safe, but changing decreasing the size or
-// changing the column type entirely is considered unsafe.
+// changing the column type entirely is considered unsafe. Changing the character
+// set is unsafe, unless the conversion is lossless, such as utf8 to utf8mb4.
 func (mc ModifyColumn) Unsafe() bool {
+	return mc.UnsafeReason() != ""
+}
+
+// UnsafeReason returns a description of why this clause is unsafe, including
+// which data could be lost, or an empty string if the clause is safe. See
+// Unsafe for the rules used.
+func (mc ModifyColumn) UnsafeReason() string {
 	if mc.OldColumn.Virtual {
-		return false
+		return ""
 	}
 
-	if mc.OldColumn.CharSet != mc.NewColumn.CharSet {
-		return true
+	colName := EscapeIdentifier(mc.OldColumn.Name)
+	if mc.OldColumn.CharSet != mc.NewColumn.CharSet && !CharSetConversionLossless(mc.OldColumn.CharSet, mc.NewColumn.CharSet) {
+		return fmt.Sprintf("column %s character set changing from %s to %s; characters not representable in the new character set would be lost", colName, mc.OldColumn.CharSet, mc.NewColumn.CharSet)
 	}
 
 	oldType := strings.ToLower(mc.OldColumn.TypeInDB)
 	newType := strings.ToLower(mc.NewColumn.TypeInDB)
 	if oldType == newType {
-		return false
+		return ""
+	}
+	shrinking := func(what, loss string) string {
+		return fmt.Sprintf("column %s %s decreasing from %s to %s; %s", colName, what, oldType, newType, loss)
 	}
 
 	// signed -> unsigned is always unsafe
 	// (The opposite is checked later specifically for the integer types)
 	if !strings.Contains(oldType, "unsigned") && strings.Contains(newType, "unsigned") {
-		return true
+		return fmt.Sprintf("column %s changing from signed %s to unsigned %s; negative values would be lost", colName, oldType, newType)
 	}
+	typeChange := fmt.Sprintf("column %s type changing from %s to %s; existing values may not convert losslessly", colName, oldType, newType)
 
 	bothSamePrefix := func(prefix ...string) bool {
 		for _, candidate := range prefix {
@@ -341,7 +376,10 @@ func (mc ModifyColumn) Unsafe() bool {
 
 	// For enum and set, adding to end of value list is safe; any other change is unsafe
 	if bothSamePrefix("enum", "set") {
-		return !strings.HasPrefix(newType, oldType[0:len(oldType)-1])
+		if !strings.HasPrefix(newType, oldType[0:len(oldType)-1]) {
+			return fmt.Sprintf("column %s value list changing from %s to %s other than by appending; existing values may be lost or remapped", colName, oldType, newType)
+		}
+		return ""
 	}
 
 	// decimal(a,b) -> decimal(x,y) unsafe if x < a or y < b
@@ -350,13 +388,16 @@ func (mc ModifyColumn) Unsafe() bool {
 		oldMatches := re.FindStringSubmatch(oldType)
 		newMatches := re.FindStringSubmatch(newType)
 		if oldMatches == nil || newMatches == nil {
-			return true
+			return typeChange
 		}
 		oldPrecision, _ := strconv.Atoi(oldMatches[1])
 		oldScale, _ := strconv.Atoi(oldMatches[2])
 		newPrecision, _ := strconv.Atoi(newMatches[1])
 		newScale, _ := strconv.Atoi(newMatches[2])
-		return (newPrecision < oldPrecision || newScale < oldScale)
+		if newPrecision < oldPrecision || newScale < oldScale {
+			return shrinking("precision or scale", "values may be truncated or rounded")
+		}
+		return ""
 	}
 
 	// bit(x) -> bit(y) unsafe if y < x
@@ -365,30 +406,37 @@ func (mc ModifyColumn) Unsafe() bool {
 		oldMatches := re.FindStringSubmatch(oldType)
 		newMatches := re.FindStringSubmatch(newType)
 		if oldMatches == nil || newMatches == nil {
-			return true
+			return typeChange
 		}
 		oldSize, _ := strconv.Atoi(oldMatches[1])
 		newSize, _ := strconv.Atoi(newMatches[1])
-		return newSize < oldSize
+		if newSize < oldSize {
+			return shrinking("bit width", "high-order bits would be lost")
+		}
+		return ""
 	}
 
 	// time, timestamp, datetime: unsafe if decreasing or removing fractional second precision
 	// but always safe if adding fsp when none was there before
 	if bothSamePrefix("time", "timestamp", "datetime") {
+		fspLoss := shrinking("fractional second precision", "sub-second values would be truncated")
 		if !strings.ContainsRune(oldType, '(') {
-			return false
+			return ""
 		} else if !strings.ContainsRune(newType, '(') {
-			return true
+			return fspLoss
 		}
 		re := regexp.MustCompile(`^[^(]+\((\d+)\)`)
 		oldMatches := re.FindStringSubmatch(oldType)
 		newMatches := re.FindStringSubmatch(newType)
 		if oldMatches == nil || newMatches == nil {
-			return true
+			return typeChange
 		}
 		oldSize, _ := strconv.Atoi(oldMatches[1])
 		newSize, _ := strconv.Atoi(newMatches[1])
-		return newSize < oldSize
+		if newSize < oldSize {
+			return fspLoss
+		}
+		return ""
 	}
 
 	// float or double:
@@ -398,22 +446,26 @@ func (mc ModifyColumn) Unsafe() bool {
 	// Converting from float to double may be safe (same rules as above), but double to float always unsafe
 	// No extra check for unsigned->signed needed; although float/double support these, they don't affect max values
 	if bothSamePrefix("float", "double") || (strings.HasPrefix(oldType, "float") && strings.HasPrefix(newType, "double")) {
+		floatLoss := shrinking("precision or scale", "values may be rounded or out of range")
 		if !strings.ContainsRune(newType, '(') { // no parens = max allowed for type
-			return false
+			return ""
 		} else if !strings.ContainsRune(oldType, '(') {
-			return true
+			return floatLoss
 		}
 		re := regexp.MustCompile(`^(?:float|double)\((\d+),(\d+)\)`)
 		oldMatches := re.FindStringSubmatch(oldType)
 		newMatches := re.FindStringSubmatch(newType)
 		if oldMatches == nil || newMatches == nil {
-			return true
+			return typeChange
 		}
 		oldPrecision, _ := strconv.Atoi(oldMatches[1])
 		oldScale, _ := strconv.Atoi(oldMatches[2])
 		newPrecision, _ := strconv.Atoi(newMatches[1])
 		newScale, _ := strconv.Atoi(newMatches[2])
-		return (newPrecision < oldPrecision || newScale < oldScale)
+		if newPrecision < oldPrecision || newScale < oldScale {
+			return floatLoss
+		}
+		return ""
 	}
 
 	// ints: unsafe if reducing to a smaller-storage type. Also unsafe if switching
@@ -430,9 +482,13 @@ func (mc ModifyColumn) Unsafe() bool {
 	}
 	if oldRank > 0 && newRank > 0 {
 		if strings.Contains(oldType, "unsigned") && !strings.Contains(newType, "unsigned") {
-			return oldRank >= newRank
+			if oldRank >= newRank {
+				return shrinking("maximum value", "values above the new maximum would be lost")
+			}
+		} else if oldRank > newRank {
+			return shrinking("integer range", "values outside the new range would be lost")
 		}
-		return oldRank > newRank
+		return ""
 	}
 
 	// Conversions between string types (char, varchar, *text): unsafe if
@@ -458,14 +514,17 @@ func (mc ModifyColumn) Unsafe() bool {
 	oldString, oldStringSize := isStringType(oldType)
 	newString, newStringSize := isStringType(newType)
 	if oldString && newString {
-		return newStringSize < oldStringSize
+		if newStringSize < oldStringSize {
+			return shrinking("maximum length", "longer values would be truncated")
+		}
+		return ""
 	}
 
 	// MariaDB 10.5+ conversions between the new inet6 type and binary(16) are
 	// always safe, as per manual description in
 	// https://mariadb.com/kb/en/inet6/#migration-between-binary16-and-inet6
 	if (oldType == "binary(16)" && newType == "inet6") || (oldType == "inet6" && newType == "binary(16)") {
-		return false
+		return ""
 	}
 
 	// Conversions between variable-length binary types (varbinary, *blob):
@@ -495,11 +554,14 @@ func (mc ModifyColumn) Unsafe() bool {
 	oldVarBin, oldVarBinSize := isVarBinType(oldType)
 	newVarBin, newVarBinSize := isVarBinType(newType)
 	if oldVarBin && newVarBin {
-		return newVarBinSize < oldVarBinSize
+		if newVarBinSize < oldVarBinSize {
+			return shrinking("maximum length", "longer values would be truncated")
+		}
+		return ""
 	}
 
 	// All other changes considered unsafe.
-	return true
+	return typeChange
 }
 
 ///// ChangeAutoIncrement //////////////////////////////////////////////////////
@@ -542,6 +604,51 @@ func (ccs ChangeCharSet) Clause(_ StatementModifiers) string {
 	return fmt.Sprintf("DEFAULT CHARACTER SET = %s%s", ccs.CharSet, collationClause)
 }
 
+///// ConvertCharSet ///////////////////////////////////////////////////////////
+
+// ConvertCharSet represents converting a table's default character set and
+// collation, along with all of its textual columns, to a new character set and
+// collation. It satisfies the TableAlterClause interface. Table.Diff uses this
+// in place of ChangeCharSet and per-column MODIFY COLUMN clauses, if every
+// pre-existing textual column is being converted to the new table default.
+type ConvertCharSet struct {
+	Table     *Table // table prior to conversion
+	CharSet   string
+	Collation string // blank string means "default collation for CharSet"
+}
+
+// Clause returns a CONVERT TO CHARACTER SET clause of an ALTER TABLE statement.
+func (ccs ConvertCharSet) Clause(_ StatementModifiers) string {
+	var collationClause string
+	if ccs.Collation != "" {
+		collationClause = fmt.Sprintf(" COLLATE %s", ccs.Collation)
+	}
+	return fmt.Sprintf("CONVERT TO CHARACTER SET %s%s", ccs.CharSet, collationClause)
+}
+
+// Unsafe returns true if this clause is potentially destructive of data.
+// ConvertCharSet is unsafe if any textual column's existing character set
+// cannot be losslessly converted to the new character set. See
+// CharSetConversionLossless.
+func (ccs ConvertCharSet) Unsafe() bool {
+	return ccs.UnsafeReason() != ""
+}
+
+// UnsafeReason returns a description of why this clause is unsafe, or an empty
+// string if it is safe.
+func (ccs ConvertCharSet) UnsafeReason() string {
+	var lossy []string
+	for _, col := range ccs.Table.Columns {
+		if col.CharSet != "" && !CharSetConversionLossless(col.CharSet, ccs.CharSet) {
+			lossy = append(lossy, fmt.Sprintf("%s (%s)", EscapeIdentifier(col.Name), col.CharSet))
+		}
+	}
+	if len(lossy) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("converting to character set %s; characters not representable in the new character set would be lost from columns %s", ccs.CharSet, strings.Join(lossy, ", "))
+}
+
 ///// ChangeCreateOptions //////////////////////////////////////////////////////
 
 // ChangeCreateOptions represents a difference in the create options
@@ -648,6 +755,11 @@ func (cse ChangeStorageEngine) Unsafe() bool {
 	return true
 }
 
+// UnsafeReason returns a description of why this clause is unsafe.
+func (cse ChangeStorageEngine) UnsafeReason() string {
+	return fmt.Sprintf("storage engine is changing to %s; data, indexes, or constraints unsupported by the new engine may be lost", cse.NewStorageEngine)
+}
+
 ///// PartitionBy //////////////////////////////////////////////////////////////
 
 // PartitionBy represents initially partitioning a previously-unpartitioned
@@ -661,7 +773,7 @@ type PartitionBy struct {
 // Clause returns a clause of an ALTER TABLE statement that partitions a
 // previously-unpartitioned table.
 func (pb PartitionBy) Clause(mods StatementModifiers) string {
-	if mods.Partitioning == PartitioningRemove || (pb.RePartition && mods.Partitioning == PartitioningKeep) {
+	if mods.Partitioning == PartitioningRemove || (pb.RePartition && mods.Partitioning == PartitioningKeep) || mods.Vitess == VitessStrip {
 		return ""
 	}
 	return strings.TrimSpace(pb.Partitioning.Definition(mods.Flavor))
@@ -676,7 +788,7 @@ type RemovePartitioning struct{}
 // Clause returns a clause of an ALTER TABLE statement that partitions a
 // previously-unpartitioned table.
 func (rp RemovePartitioning) Clause(mods StatementModifiers) string {
-	if mods.Partitioning == PartitioningKeep {
+	if mods.Partitioning == PartitioningKeep || mods.Vitess == VitessStrip {
 		return ""
 	}
 	return "REMOVE PARTITIONING"
@@ -705,7 +817,7 @@ func (mp ModifyPartitions) Clause(mods StatementModifiers) string {
 	if !mp.ForDropTable || len(mp.Drop) == 0 {
 		return ""
 	}
-	if mp.ForDropTable && mods.SkipPreDropAlters {
+	if mp.ForDropTable && (mods.SkipPreDropAlters || mods.Vitess == VitessStrip) {
 		return ""
 	}
 	var names []string
@@ -719,3 +831,19 @@ func (mp ModifyPartitions) Clause(mods StatementModifiers) string {
 func (mp ModifyPartitions) Unsafe() bool {
 	return len(mp.Drop) > 0
 }
+
+// UnsafeReason returns a description of why this clause is unsafe, or an empty
+// string if it is safe.
+func (mp ModifyPartitions) UnsafeReason() string {
+	if !mp.Unsafe() {
+		return ""
+	}
+	names := make([]string, len(mp.Drop))
+	for n, p := range mp.Drop {
+		names[n] = EscapeIdentifier(p.Name)
+	}
+	if len(names) == 1 {
+		return fmt.Sprintf("partition %s is being dropped; all rows stored in it would be lost", names[0])
+	}
+	return fmt.Sprintf("partitions %s are being dropped; all rows stored in them would be lost", strings.Join(names, ", "))
+}
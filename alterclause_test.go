@@ -72,6 +72,9 @@ func TestModifyColumnUnsafe(t *testing.T) {
 		if actual := mc.Unsafe(); actual != expected {
 			t.Errorf("For %s -> %s, expected unsafe=%t, instead found unsafe=%t", type1, type2, expected, actual)
 		}
+		if reason := mc.UnsafeReason(); (reason != "") != expected {
+			t.Errorf("For %s -> %s, expected unsafe=%t, instead found reason %q", type1, type2, expected, reason)
+		}
 	}
 
 	expectUnsafe := [][]string{
@@ -0,0 +1,360 @@
+package tengo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/VividCortex/mysqlerr"
+	"github.com/jmoiron/sqlx"
+)
+
+// Blocker represents a session which may prevent DDL from obtaining a metadata
+// lock on one or more tables, either because it holds a metadata lock in an
+// open transaction, or because it is currently running a query referencing the
+// tables. Blockers which were not confirmed via performance_schema's metadata
+// lock information are marked as Heuristic.
+type Blocker struct {
+	ConnectionID   uint64
+	User           string
+	Host           string
+	Schema         string        // default database of the session, if any
+	Command        string        // e.g. "Query" or "Sleep"
+	State          string        // thread state, if any
+	Query          string        // currently-executing statement, if any
+	QueryTime      time.Duration // time spent in current Command
+	TransactionAge time.Duration // age of the session's open InnoDB transaction, or 0 if none
+	Tables         []string      // tables known to be locked or referenced by the session; empty if unknown
+	Heuristic      bool          // true if only found by query text or transaction age, rather than a metadata lock
+}
+
+func (b *Blocker) String() string {
+	desc := fmt.Sprintf("connection %d (%s@%s)", b.ConnectionID, b.User, b.Host)
+	if b.TransactionAge > 0 {
+		desc += fmt.Sprintf(", transaction open for %s", b.TransactionAge)
+	}
+	if b.Query != "" {
+		desc += fmt.Sprintf(", running query for %s", b.QueryTime)
+	}
+	if len(b.Tables) > 0 {
+		desc += fmt.Sprintf(", tables %s", strings.Join(b.Tables, ", "))
+	}
+	if b.Heuristic {
+		desc += " (unconfirmed)"
+	}
+	return desc
+}
+
+// BlockerAction determines how CheckBlockers handles any blockers found.
+type BlockerAction int
+
+// Constants enumerating valid BlockerAction values
+const (
+	BlockerActionAbort BlockerAction = iota // Return an error immediately if blockers are found
+	BlockerActionWait                       // Wait up to the grace period for blockers to finish, then return an error if any remain
+	BlockerActionKill                       // Wait up to the grace period for blockers to finish, then kill any that remain, unless any are Heuristic
+)
+
+// BlockerOptions controls the behavior of CheckBlockers.
+type BlockerOptions struct {
+	Action       BlockerAction
+	GracePeriod  time.Duration // How long to wait for blockers to finish with BlockerActionWait or BlockerActionKill
+	PollInterval time.Duration // How often to re-check blockers during the grace period; 1 second if 0
+}
+
+// BlockerError is returned by CheckBlockers if blockers remain.
+type BlockerError struct {
+	Schema   string
+	Blockers []*Blocker
+}
+
+// Error satisfies the builtin error interface.
+func (e *BlockerError) Error() string {
+	descriptions := make([]string, len(e.Blockers))
+	for n, b := range e.Blockers {
+		descriptions[n] = b.String()
+	}
+	return fmt.Sprintf("Found %d sessions which may block DDL in schema %s: %s", len(e.Blockers), EscapeIdentifier(e.Schema), strings.Join(descriptions, "; "))
+}
+
+// IsBlockerError returns true if err represents sessions which may block DDL.
+func IsBlockerError(err error) bool {
+	_, ok := err.(*BlockerError)
+	return ok
+}
+
+// AffectedTableNames returns the names of tables which would be altered or
+// dropped by the diff, along with any same-schema tables referenced by foreign
+// keys of those tables, since DDL also requires metadata locks on them. Newly-
+// created tables are not included, unless referenced by a foreign key.
+func (sd *SchemaDiff) AffectedTableNames() []string {
+	seen := make(map[string]bool)
+	addFKParents := func(t *Table) {
+		if t == nil {
+			return
+		}
+		for _, fk := range t.ForeignKeys {
+			if fk.ReferencedSchemaName == "" {
+				seen[fk.ReferencedTableName] = true
+			}
+		}
+	}
+	for _, td := range sd.TableDiffs {
+		if td.Type == DiffTypeCreate {
+			continue
+		}
+		seen[td.From.Name] = true
+		addFKParents(td.From)
+		addFKParents(td.To)
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckBlockers looks for sessions which may block the DDL of diff, when run
+// in the supplied schema. If none are found, nil is returned. Otherwise,
+// handling depends on opts.Action: the method may return a *BlockerError
+// immediately, wait up to opts.GracePeriod for the blockers to finish, or kill
+// the blockers if they do not finish by then. In the latter case, nil is
+// returned if the blockers were successfully killed. Heuristic blockers may be
+// unrelated to the tables, so they are never killed: if any remain after the
+// grace period, a *BlockerError is returned without killing anything.
+func (instance *Instance) CheckBlockers(schema string, diff *SchemaDiff, opts BlockerOptions) error {
+	tableNames := diff.AffectedTableNames()
+	if len(tableNames) == 0 {
+		return nil
+	}
+	interval := opts.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	deadline := time.Now().Add(opts.GracePeriod)
+	for {
+		blockers, err := instance.TableBlockers(schema, tableNames...)
+		if err != nil {
+			return err
+		} else if len(blockers) == 0 {
+			return nil
+		}
+		if opts.Action == BlockerActionAbort || (opts.Action == BlockerActionWait && !time.Now().Before(deadline)) {
+			return &BlockerError{Schema: schema, Blockers: blockers}
+		} else if opts.Action == BlockerActionKill && !time.Now().Before(deadline) {
+			for _, b := range blockers {
+				if b.Heuristic {
+					return &BlockerError{Schema: schema, Blockers: blockers}
+				}
+			}
+			for _, b := range blockers {
+				if err := instance.KillConnection(b.ConnectionID); err != nil && !IsDatabaseError(err, mysqlerr.ER_NO_SUCH_THREAD) {
+					return fmt.Errorf("Unable to kill %s: %s", b, err)
+				}
+			}
+			opts.Action = BlockerActionAbort // only kill once; if still blocked after next poll, return error
+		}
+		time.Sleep(interval)
+	}
+}
+
+// blockerFallbackTrxAge is the minimum age of an open transaction for it to be
+// reported as a blocker by TableBlockers, when metadata lock information is
+// unavailable.
+const blockerFallbackTrxAge = time.Second
+
+// TableBlockers returns sessions which may block DDL on any of the supplied
+// tables in schema. This includes sessions holding a metadata lock on the
+// tables (from performance_schema.metadata_locks), and sessions currently
+// running a query which references the tables (from processlist). Query text
+// only counts as a reference if the table is qualified with schema, or if it is
+// unqualified and the session's default database is schema. Details of open
+// transactions are obtained from information_schema.innodb_trx.
+// Metadata lock information requires MySQL 5.7+ with the
+// wait/lock/metadata/sql/mdl instrument enabled, which is the default in MySQL
+// 8.0. If unavailable, any transaction open for at least one second is
+// conservatively reported as a blocker, since it may hold a metadata lock.
+// Blockers not found via metadata lock information are marked as Heuristic.
+// Sessions of the calling user are not excluded, but the connection used to
+// run these queries is.
+func (instance *Instance) TableBlockers(schema string, tableNames ...string) ([]*Blocker, error) {
+	if len(tableNames) == 0 {
+		return []*Blocker{}, nil
+	}
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	wantTable := make(map[string]bool, len(tableNames))
+	for _, name := range tableNames {
+		wantTable[name] = true
+	}
+	blockers := make(map[uint64]*Blocker)
+	getBlocker := func(id uint64) *Blocker {
+		if blockers[id] == nil {
+			blockers[id] = &Blocker{ConnectionID: id}
+		}
+		return blockers[id]
+	}
+
+	// Sessions holding metadata locks on the tables
+	mdlAvailable := true
+	lockHolders, err := queryMetadataLockHolders(db, schema, tableNames)
+	if err != nil {
+		mdlAvailable = false
+	}
+	for id, tables := range lockHolders {
+		getBlocker(id).Tables = tables
+	}
+
+	// Open transactions
+	var rawTrx []struct {
+		ConnectionID uint64 `db:"trx_mysql_thread_id"`
+		AgeSeconds   int64  `db:"trx_age"`
+	}
+	query := `
+		SELECT trx_mysql_thread_id AS trx_mysql_thread_id,
+		       TIMESTAMPDIFF(SECOND, trx_started, NOW()) AS trx_age
+		FROM   information_schema.innodb_trx
+		WHERE  trx_mysql_thread_id != CONNECTION_ID()`
+	if err := db.Select(&rawTrx, query); err != nil {
+		return nil, fmt.Errorf("Error querying information_schema.innodb_trx: %s", err)
+	}
+	for _, trx := range rawTrx {
+		age := time.Duration(trx.AgeSeconds) * time.Second
+		if b, ok := blockers[trx.ConnectionID]; ok {
+			b.TransactionAge = age
+		} else if !mdlAvailable && age >= blockerFallbackTrxAge {
+			b := getBlocker(trx.ConnectionID)
+			b.TransactionAge = age
+			b.Heuristic = true
+		}
+	}
+
+	// Sessions running queries referencing the tables, as well as processlist
+	// details for sessions found above
+	procs, err := instance.Processlist(ProcessFilter{ExcludeSystem: true})
+	if err != nil {
+		return nil, err
+	}
+	reTables := tableReferenceRegexp(tableNames)
+	for _, proc := range procs {
+		b, ok := blockers[proc.ID]
+		if !proc.Idle() && proc.Info != "" {
+			for _, match := range reTables.FindAllStringSubmatch(proc.Info, -1) {
+				name := unescapeIdentifier(match[2])
+				if !wantTable[name] {
+					continue
+				} else if qualifier := unescapeIdentifier(match[1]); qualifier != schema && (qualifier != "" || proc.DB != schema) {
+					continue
+				}
+				if !ok {
+					b = getBlocker(proc.ID)
+					b.Heuristic = true
+					ok = true
+				}
+				if !containsString(b.Tables, name) {
+					b.Tables = append(b.Tables, name)
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		b.User, b.Host, b.Schema = proc.User, proc.Host, proc.DB
+		b.Command, b.State, b.Query = proc.Command, proc.State, proc.Info
+		b.QueryTime = proc.Time
+	}
+
+	result := make([]*Blocker, 0, len(blockers))
+	for _, b := range blockers {
+		// Skip sessions that were found in innodb_trx or metadata_locks but have
+		// since disconnected
+		if b.User == "" {
+			continue
+		}
+		sort.Strings(b.Tables)
+		result = append(result, b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ConnectionID < result[j].ConnectionID
+	})
+	return result, nil
+}
+
+// queryMetadataLockHolders returns a map of connection ID to names of tables
+// in tableNames on which the connection holds a granted metadata lock. An
+// error is returned if performance_schema.metadata_locks is unavailable or
+// not instrumented.
+func queryMetadataLockHolders(db *sqlx.DB, schema string, tableNames []string) (map[uint64][]string, error) {
+	var enabled string
+	query := `
+		SELECT enabled AS enabled
+		FROM   performance_schema.setup_instruments
+		WHERE  name = 'wait/lock/metadata/sql/mdl'`
+	if err := db.Get(&enabled, query); err != nil {
+		return nil, err
+	} else if enabled != "YES" {
+		return nil, fmt.Errorf("Metadata lock instrumentation is not enabled")
+	}
+	var rawLocks []struct {
+		ConnectionID uint64 `db:"processlist_id"`
+		TableName    string `db:"object_name"`
+	}
+	query = `
+		SELECT t.processlist_id AS processlist_id, ml.object_name AS object_name
+		FROM   performance_schema.metadata_locks ml
+		JOIN   performance_schema.threads t ON t.thread_id = ml.owner_thread_id
+		WHERE  ml.object_type = 'TABLE' AND ml.lock_status = 'GRANTED'
+		AND    ml.object_schema = ? AND ml.object_name IN (?)
+		AND    t.processlist_id IS NOT NULL AND t.processlist_id != CONNECTION_ID()`
+	query, args, err := sqlx.In(query, schema, tableNames)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Select(&rawLocks, query, args...); err != nil {
+		return nil, err
+	}
+	result := make(map[uint64][]string)
+	for _, lock := range rawLocks {
+		if !containsString(result[lock.ConnectionID], lock.TableName) {
+			result[lock.ConnectionID] = append(result[lock.ConnectionID], lock.TableName)
+		}
+	}
+	return result, nil
+}
+
+// tableReferenceRegexp returns a regexp for finding references to any of the
+// supplied table names in a SQL statement. The first submatch is the schema
+// name qualifying the table, or an empty string if unqualified; the second
+// submatch is the table name. Either may be wrapped in backticks.
+func tableReferenceRegexp(tableNames []string) *regexp.Regexp {
+	alternatives := make([]string, 0, len(tableNames)*2)
+	for _, name := range tableNames {
+		alternatives = append(alternatives, regexp.QuoteMeta(EscapeIdentifier(name)), `\b`+regexp.QuoteMeta(name)+`\b`)
+	}
+	qualifier := "(?:(`(?:[^`]|``)+`|\\w+)\\s*\\.\\s*)?"
+	return regexp.MustCompile(`(?i)` + qualifier + `(` + strings.Join(alternatives, "|") + `)`)
+}
+
+// unescapeIdentifier reverses EscapeIdentifier, if the input is wrapped in
+// backticks. Otherwise the input is returned unchanged.
+func unescapeIdentifier(input string) string {
+	if len(input) < 2 || input[0] != '`' || input[len(input)-1] != '`' {
+		return input
+	}
+	return strings.Replace(input[1:len(input)-1], "``", "`", -1)
+}
+
+func containsString(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package tengo
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchemaDiffAffectedTableNames(t *testing.T) {
+	from, to := aTable(1), anotherTable()
+	fkTable := foreignKeyTable()
+	sd := &SchemaDiff{
+		TableDiffs: []*TableDiff{
+			{Type: DiffTypeCreate, To: &to},
+			{Type: DiffTypeDrop, From: &from},
+			{Type: DiffTypeAlter, From: &fkTable, To: &fkTable},
+		},
+	}
+	// Only the same-schema FK parent should be included, not the one in the
+	// purchasing schema
+	expected := []string{"actor", "products", "warranties"}
+	if actual := sd.AffectedTableNames(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected AffectedTableNames to return %v, instead found %v", expected, actual)
+	}
+	if actual := (&SchemaDiff{}).AffectedTableNames(); len(actual) != 0 {
+		t.Errorf("Expected empty diff to return no table names, instead found %v", actual)
+	}
+}
+
+func TestTableReferenceRegexp(t *testing.T) {
+	re := tableReferenceRegexp([]string{"actor", "my-table"})
+	cases := map[string][]string{
+		"SELECT * FROM actor WHERE id=1":                 {".actor"},
+		"SELECT * FROM `actor` a JOIN `my-table` m":      {".`actor`", ".`my-table`"},
+		"UPDATE testing.Actor SET name='x'":              {"testing.Actor"},
+		"SELECT * FROM `other` . `actor`":                {"`other`.`actor`"},
+		"SELECT * FROM `odd``db`.actor":                  {"`odd``db`.actor"},
+		"SELECT * FROM actor_in_film":                    nil,
+		"SELECT factor FROM other_table":                 nil,
+		"INSERT INTO `actor_in_film` SELECT * FROM film": nil,
+	}
+	for query, expected := range cases {
+		var actual []string
+		for _, match := range re.FindAllStringSubmatch(query, -1) {
+			actual = append(actual, match[1]+"."+match[2])
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Query %q: expected matches %v, instead found %v", query, expected, actual)
+		}
+	}
+}
+
+func TestUnescapeIdentifier(t *testing.T) {
+	for _, input := range []string{"actor", "my-table", "odd`name", "`", ""} {
+		if actual := unescapeIdentifier(EscapeIdentifier(input)); actual != input {
+			t.Errorf("Expected unescapeIdentifier to return %q, instead found %q", input, actual)
+		}
+	}
+	if actual := unescapeIdentifier("actor"); actual != "actor" {
+		t.Errorf("Expected unescaped input to be returned unchanged, instead found %q", actual)
+	}
+}
+
+func TestBlockerError(t *testing.T) {
+	err := &BlockerError{
+		Schema: "testing",
+		Blockers: []*Blocker{
+			{ConnectionID: 12, User: "app", Host: "10.0.0.1:3306", TransactionAge: 90 * time.Second, Tables: []string{"actor"}},
+			{ConnectionID: 15, User: "app", Host: "10.0.0.2:3306", Query: "SELECT SLEEP(100)", QueryTime: 5 * time.Second, Heuristic: true},
+		},
+	}
+	if !IsBlockerError(err) {
+		t.Error("Expected IsBlockerError to return true, but it did not")
+	}
+	msg := err.Error()
+	for _, expected := range []string{"2 sessions", "`testing`", "connection 12 (app@10.0.0.1:3306), transaction open for 1m30s, tables actor", "connection 15 (app@10.0.0.2:3306), running query for 5s (unconfirmed)"} {
+		if !strings.Contains(msg, expected) {
+			t.Errorf("Expected error message to contain %q, but it did not: %s", expected, msg)
+		}
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceTableBlockers(t *testing.T) {
+	if blockers, err := s.d.TableBlockers("testing", "actor", "has_rows"); err != nil || len(blockers) != 0 {
+		t.Fatalf("Unexpected return from TableBlockers: %+v, %v", blockers, err)
+	}
+
+	// Open a transaction which reads from actor, obtaining a metadata lock
+	db, err := s.d.ConnectionPool("testing", "")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Unable to obtain connection: %v", err)
+	}
+	defer conn.Close()
+	var connID uint64
+	if err := conn.QueryRowContext(context.Background(), "SELECT CONNECTION_ID()").Scan(&connID); err != nil {
+		t.Fatalf("Unable to obtain connection ID: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "BEGIN"); err != nil {
+		t.Fatalf("Unable to begin transaction: %v", err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "SELECT * FROM actor"); err != nil {
+		t.Fatalf("Unable to query actor: %v", err)
+	}
+	time.Sleep(blockerFallbackTrxAge + 100*time.Millisecond)
+
+	blockers, err := s.d.TableBlockers("testing", "actor", "has_rows")
+	if err != nil {
+		t.Fatalf("Unexpected error from TableBlockers: %v", err)
+	} else if len(blockers) != 1 || blockers[0].ConnectionID != connID {
+		t.Fatalf("Expected TableBlockers to return connection %d, instead found %+v", connID, blockers)
+	} else if blockers[0].Command != "Sleep" || blockers[0].TransactionAge < blockerFallbackTrxAge {
+		t.Errorf("Unexpected fields in blocker: %+v", *blockers[0])
+	}
+	// Blocker is only confirmed if metadata lock instrumentation is available,
+	// which depends on flavor and configuration
+	heuristic := blockers[0].Heuristic
+
+	from := s.GetSchema(t, "testing")
+	to := s.GetSchema(t, "testing")
+	to.Tables = nil
+	for _, table := range from.Tables {
+		if table.Name != "actor" && table.Name != "actor_in_film" {
+			to.Tables = append(to.Tables, table)
+		}
+	}
+	diff := from.Diff(to)
+	opts := BlockerOptions{Action: BlockerActionWait, GracePeriod: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond}
+	if err := s.d.CheckBlockers("testing", diff, opts); !IsBlockerError(err) {
+		t.Errorf("Expected CheckBlockers to return a BlockerError, instead found %v", err)
+	}
+	// Heuristic blockers are never killed
+	opts.Action = BlockerActionKill
+	if heuristic {
+		if err := s.d.CheckBlockers("testing", diff, opts); !IsBlockerError(err) {
+			t.Errorf("Expected CheckBlockers to return a BlockerError, instead found %v", err)
+		}
+		if err := s.d.KillConnection(connID); err != nil {
+			t.Fatalf("Unexpected error from KillConnection: %v", err)
+		}
+	} else if err := s.d.CheckBlockers("testing", diff, opts); err != nil {
+		t.Errorf("Unexpected error from CheckBlockers: %v", err)
+	}
+	if blockers, err := s.d.TableBlockers("testing", "actor"); err != nil || len(blockers) != 0 {
+		t.Errorf("Expected blocker to be killed, instead TableBlockers returned %+v, %v", blockers, err)
+	}
+}
@@ -0,0 +1,339 @@
+package tengo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// charSetMaxBytes returns the maximum number of bytes per character in
+// charSet. Unknown character sets are assumed to use up to 4 bytes.
+func charSetMaxBytes(charSet string) int {
+	switch charSet {
+	case "latin1", "latin2", "latin5", "latin7", "ascii", "binary", "cp1250", "cp1251", "cp1256", "cp1257", "cp850", "cp852", "cp866", "dec8", "greek", "hebrew", "hp8", "keybcs2", "koi8r", "koi8u", "macce", "macroman", "swe7", "tis620", "armscii8", "geostd8":
+		return 1
+	case "ucs2", "big5", "gbk", "sjis", "cp932", "euckr":
+		return 2
+	case "utf8", "utf8mb3", "ujis", "eucjpms":
+		return 3
+	}
+	return 4
+}
+
+// CharSetConversionLossless returns true if every value in character set from
+// may be converted to character set to without any loss of characters or
+// change in byte length. This is true for conversions to a superset which
+// encodes common characters identically, such as utf8 (utf8mb3) to utf8mb4, or
+// ascii to latin1 or any utf8 variant. It is also true if from and to are the
+// same, or aliases of one another.
+func CharSetConversionLossless(from, to string) bool {
+	normalize := func(charSet string) string {
+		if charSet == "utf8" {
+			return "utf8mb3"
+		}
+		return charSet
+	}
+	from, to = normalize(from), normalize(to)
+	switch from {
+	case to:
+		return true
+	case "ascii":
+		return to == "latin1" || to == "utf8mb3" || to == "utf8mb4"
+	case "utf8mb3":
+		return to == "utf8mb4"
+	}
+	return false
+}
+
+// textTypeCapacities lists the maximum length in bytes of each text type, in
+// increasing order.
+var textTypeCapacities = []struct {
+	typ      string
+	capacity uint64
+}{
+	{"tinytext", 255},
+	{"text", 65535},
+	{"mediumtext", 16777215},
+	{"longtext", 4294967295},
+}
+
+// convertColumn returns a copy of col converted to the supplied character set
+// and collation, in the same manner as ALTER TABLE ... CONVERT TO CHARACTER
+// SET. This includes promoting text types to a larger type if needed to hold
+// the same number of characters in the new character set. Columns without a
+// character set are returned unchanged.
+func convertColumn(col *Column, charSet, collation string, collationIsDefault bool) *Column {
+	if col.CharSet == "" {
+		return col
+	}
+	converted := *col
+	converted.CharSet = charSet
+	converted.Collation = collation
+	converted.CollationIsDefault = collationIsDefault
+	oldMax, newMax := uint64(charSetMaxBytes(col.CharSet)), uint64(charSetMaxBytes(charSet))
+	if newMax > oldMax {
+		for n, tt := range textTypeCapacities {
+			if strings.ToLower(col.TypeInDB) != tt.typ {
+				continue
+			}
+			required := tt.capacity / oldMax * newMax
+			for _, larger := range textTypeCapacities[n:] {
+				if larger.capacity >= required || larger.typ == "longtext" {
+					converted.TypeInDB = larger.typ
+					break
+				}
+			}
+			break
+		}
+	}
+	return &converted
+}
+
+// ConvertedTable returns a copy of t with its default character set and
+// collation, as well as those of all textual columns, converted in the same
+// manner as ALTER TABLE ... CONVERT TO CHARACTER SET. The copy's
+// CreateStatement is regenerated for the supplied flavor. If t already fully
+// uses the supplied character set and collation, nil is returned.
+func (t *Table) ConvertedTable(charSet, collation string, collationIsDefault bool, flavor Flavor) *Table {
+	converted := *t
+	converted.CharSet = charSet
+	converted.Collation = collation
+	converted.CollationIsDefault = collationIsDefault
+	changed := (t.CharSet != charSet || t.Collation != collation)
+	converted.Columns = make([]*Column, len(t.Columns))
+	for n, col := range t.Columns {
+		converted.Columns[n] = convertColumn(col, charSet, collation, collationIsDefault)
+		changed = changed || !converted.Columns[n].Equals(col)
+	}
+	if !changed {
+		return nil
+	}
+	converted.CreateStatement = converted.GeneratedCreateStatement(flavor)
+	return &converted
+}
+
+// KeyLengthOverflow describes an index which exceeds InnoDB's limits on key
+// length, which would cause DDL creating or altering the table to fail.
+type KeyLengthOverflow struct {
+	Index  *Index
+	Column *Column // column whose index part exceeds the per-column limit; nil if the whole index exceeds the total limit
+	Bytes  int     // maximum length of the index part, or whole index, in bytes
+	Limit  int     // applicable limit in bytes
+}
+
+func (klo KeyLengthOverflow) String() string {
+	if klo.Column != nil {
+		return fmt.Sprintf("index %s part %s may use %d bytes, exceeding limit of %d bytes", EscapeIdentifier(klo.Index.Name), EscapeIdentifier(klo.Column.Name), klo.Bytes, klo.Limit)
+	}
+	return fmt.Sprintf("index %s may use %d bytes, exceeding limit of %d bytes", EscapeIdentifier(klo.Index.Name), klo.Bytes, klo.Limit)
+}
+
+// Maximum InnoDB key lengths, in bytes, for the default 16KB page size
+const (
+	keyPartLimitSmall = 767  // per-column limit for REDUNDANT or COMPACT row formats, or without innodb_large_prefix
+	keyPartLimitLarge = 3072 // per-column limit for DYNAMIC or COMPRESSED row formats with innodb_large_prefix
+	keyTotalLimit     = 3072 // limit for all parts of an index combined
+)
+
+// KeyLengthOverflows returns any indexes of t which exceed InnoDB's key length
+// limits in the supplied flavor. The per-column limit depends on the table's
+// row format, with the flavor's default row format assumed if none is
+// specified; innodb_large_prefix is assumed to have its default value for the
+// flavor. A 16KB page size is also assumed. Tables using other storage engines
+// always return nil. Key length overflows are common when converting tables
+// to utf8mb4, since each character of an indexed column may use 4 bytes.
+func (t *Table) KeyLengthOverflows(flavor Flavor) (overflows []KeyLengthOverflow) {
+	if t.Engine != "InnoDB" {
+		return nil
+	}
+	largePrefix := flavor.MySQLishMinVersion(5, 7) || flavor.VendorMinVersion(VendorMariaDB, 10, 2)
+	rowFormat := strings.ToUpper(t.RowFormatClause())
+	if rowFormat == "" || rowFormat == "DEFAULT" {
+		if largePrefix {
+			rowFormat = "DYNAMIC"
+		} else {
+			rowFormat = "COMPACT"
+		}
+	}
+	partLimit := keyPartLimitSmall
+	if largePrefix && (rowFormat == "DYNAMIC" || rowFormat == "COMPRESSED") {
+		partLimit = keyPartLimitLarge
+	}
+
+	indexes := t.SecondaryIndexes
+	if t.PrimaryKey != nil {
+		indexes = append([]*Index{t.PrimaryKey}, indexes...)
+	}
+	columns := t.ColumnsByName()
+	for _, idx := range indexes {
+		if idx.Type != "BTREE" && idx.Type != "" {
+			continue
+		}
+		var total int
+		for _, part := range idx.Parts {
+			col := columns[part.ColumnName]
+			if col == nil {
+				continue // functional index part
+			}
+			bytes := indexPartMaxBytes(part, col)
+			total += bytes
+			if bytes > partLimit {
+				overflows = append(overflows, KeyLengthOverflow{Index: idx, Column: col, Bytes: bytes, Limit: partLimit})
+			}
+		}
+		if total > keyTotalLimit {
+			overflows = append(overflows, KeyLengthOverflow{Index: idx, Bytes: total, Limit: keyTotalLimit})
+		}
+	}
+	return overflows
+}
+
+var reTypeLength = regexp.MustCompile(`^(\w+)\((\d+)\)`)
+
+// fixedTypeBytes maps column types to their storage size in bytes, for types
+// that have a fixed size. Sizes of temporal types reflect their current
+// storage format without fractional seconds.
+var fixedTypeBytes = map[string]int{
+	"tinyint":   1,
+	"smallint":  2,
+	"mediumint": 3,
+	"int":       4,
+	"integer":   4,
+	"bigint":    8,
+	"float":     4,
+	"double":    8,
+	"date":      3,
+	"time":      3,
+	"datetime":  5,
+	"timestamp": 4,
+	"year":      1,
+	"enum":      2,
+	"set":       8,
+}
+
+// indexPartMaxBytes returns the maximum length in bytes of an index part
+// referring to col. Types of unknown length return 0.
+func indexPartMaxBytes(part IndexPart, col *Column) int {
+	typ := strings.ToLower(col.TypeInDB)
+	baseType := typ
+	if pos := strings.IndexAny(typ, "( "); pos > -1 {
+		baseType = typ[0:pos]
+	}
+	if size, ok := fixedTypeBytes[baseType]; ok {
+		return size
+	}
+
+	// For textual types, lengths are in characters; for binary types, in bytes.
+	// Prefix lengths use the same units.
+	var length int
+	if matches := reTypeLength.FindStringSubmatch(typ); matches != nil {
+		length, _ = strconv.Atoi(matches[2])
+	}
+	if part.PrefixLength > 0 && (length == 0 || int(part.PrefixLength) < length) {
+		length = int(part.PrefixLength)
+	}
+	switch baseType {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext":
+		return length * charSetMaxBytes(col.CharSet)
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return length
+	}
+	return 0
+}
+
+// CharSetConversion is a plan for converting one table to a new character set
+// and collation.
+type CharSetConversion struct {
+	From      *Table
+	To        *Table              // From as it would be after conversion
+	Diff      *TableDiff          // ALTER TABLE performing the conversion
+	Overflows []KeyLengthOverflow // key length problems in To; if non-empty, the ALTER would fail
+}
+
+// CharSetConversionPlan is a plan for converting all tables in a schema to a
+// new character set and collation.
+type CharSetConversionPlan struct {
+	CharSet   string
+	Collation string
+	Tables    []*CharSetConversion // only tables requiring conversion, sorted by name
+}
+
+// Overflows returns all key length overflows across all tables in the plan.
+func (plan *CharSetConversionPlan) Overflows() map[string][]KeyLengthOverflow {
+	result := make(map[string][]KeyLengthOverflow)
+	for _, conv := range plan.Tables {
+		if len(conv.Overflows) > 0 {
+			result[conv.From.Name] = conv.Overflows
+		}
+	}
+	return result
+}
+
+// PlanCharSetConversion returns a plan for converting every table in schema,
+// which should have been introspected from this instance, to the supplied
+// character set and collation. If collation is blank, the instance's default
+// collation for charSet is used. Each table's diff uses CONVERT TO CHARACTER
+// SET, and so converts all textual columns along with the table default;
+// Statement on the diff returns a ForbiddenDiffError if any column's
+// conversion is not lossless and unsafe changes are not permitted. This method
+// does not modify the schema's default character set; see AlterSchema.
+func (instance *Instance) PlanCharSetConversion(schema *Schema, charSet, collation string) (*CharSetConversionPlan, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var collations []struct {
+		Name      string `db:"collation_name"`
+		IsDefault string `db:"is_default"`
+	}
+	query := `
+		SELECT collation_name AS collation_name, is_default AS is_default
+		FROM   information_schema.collations
+		WHERE  character_set_name = ?`
+	err = instance.retry(context.Background(), true, func() error {
+		collations = nil
+		return db.Select(&collations, query, charSet)
+	})
+	if err != nil {
+		return nil, err
+	} else if len(collations) == 0 {
+		return nil, fmt.Errorf("Character set %s is not supported by %s", charSet, instance)
+	}
+	var found, isDefault bool
+	for _, c := range collations {
+		if (collation == "" && showBool(c.IsDefault)) || c.Name == collation {
+			collation, found, isDefault = c.Name, true, showBool(c.IsDefault)
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("Collation %s is not valid for character set %s on %s", collation, charSet, instance)
+	}
+
+	flavor := instance.Flavor()
+	plan := &CharSetConversionPlan{
+		CharSet:   charSet,
+		Collation: collation,
+		Tables:    []*CharSetConversion{},
+	}
+	for _, t := range schema.Tables {
+		to := t.ConvertedTable(charSet, collation, isDefault, flavor)
+		if to == nil {
+			continue
+		}
+		plan.Tables = append(plan.Tables, &CharSetConversion{
+			From:      t,
+			To:        to,
+			Diff:      NewAlterTable(t, to),
+			Overflows: to.KeyLengthOverflows(flavor),
+		})
+	}
+	sort.Slice(plan.Tables, func(i, j int) bool {
+		return plan.Tables[i].From.Name < plan.Tables[j].From.Name
+	})
+	return plan, nil
+}
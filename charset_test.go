@@ -0,0 +1,231 @@
+package tengo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCharSetConversionLossless(t *testing.T) {
+	cases := []struct {
+		from, to string
+		expected bool
+	}{
+		{"utf8", "utf8mb4", true},
+		{"utf8mb3", "utf8mb4", true},
+		{"utf8", "utf8mb3", true},
+		{"ascii", "latin1", true},
+		{"ascii", "utf8mb4", true},
+		{"latin1", "latin1", true},
+		{"utf8mb4", "utf8", false},
+		{"latin1", "utf8mb4", false},
+		{"latin1", "ascii", false},
+		{"ucs2", "utf8mb4", false},
+	}
+	for _, c := range cases {
+		if actual := CharSetConversionLossless(c.from, c.to); actual != c.expected {
+			t.Errorf("Expected CharSetConversionLossless(%q, %q) to return %t, instead found %t", c.from, c.to, c.expected, actual)
+		}
+	}
+
+	// Lossless conversions should be considered safe by ModifyColumn, unless
+	// the type also changes in an unsafe way
+	mc := ModifyColumn{
+		OldColumn: &Column{Name: "foo", TypeInDB: "varchar(30)", CharSet: "utf8", Collation: "utf8_general_ci"},
+		NewColumn: &Column{Name: "foo", TypeInDB: "varchar(30)", CharSet: "utf8mb4", Collation: "utf8mb4_general_ci"},
+	}
+	if mc.Unsafe() {
+		t.Errorf("Expected utf8 to utf8mb4 conversion to be safe, instead found unsafe: %s", mc.UnsafeReason())
+	}
+	mc.NewColumn.TypeInDB = "varchar(20)"
+	if !mc.Unsafe() {
+		t.Error("Expected utf8 to utf8mb4 conversion with shorter length to be unsafe, but it was not")
+	}
+}
+
+func TestConvertColumn(t *testing.T) {
+	cases := []struct {
+		typ, fromCharSet, toCharSet, expectedType string
+	}{
+		{"varchar(255)", "utf8", "utf8mb4", "varchar(255)"},
+		{"tinytext", "utf8", "utf8mb4", "text"},
+		{"text", "latin1", "utf8mb4", "mediumtext"},
+		{"text", "utf8mb4", "latin1", "text"},
+		{"mediumtext", "utf8", "utf8mb4", "longtext"},
+		{"longtext", "utf8", "utf8mb4", "longtext"},
+	}
+	for _, c := range cases {
+		col := &Column{Name: "foo", TypeInDB: c.typ, CharSet: c.fromCharSet, Collation: c.fromCharSet + "_general_ci", CollationIsDefault: true}
+		converted := convertColumn(col, c.toCharSet, c.toCharSet+"_bin", false)
+		if converted.TypeInDB != c.expectedType || converted.CharSet != c.toCharSet || converted.Collation != c.toCharSet+"_bin" || converted.CollationIsDefault {
+			t.Errorf("Unexpected result converting %s %s to %s: %+v", c.typ, c.fromCharSet, c.toCharSet, *converted)
+		}
+		if col.CharSet != c.fromCharSet || col.TypeInDB != c.typ {
+			t.Errorf("convertColumn unexpectedly modified its input: %+v", *col)
+		}
+	}
+	col := &Column{Name: "id", TypeInDB: "int(10) unsigned"}
+	if converted := convertColumn(col, "utf8mb4", "utf8mb4_general_ci", true); converted != col {
+		t.Errorf("Expected column without character set to be unchanged, instead found %+v", *converted)
+	}
+}
+
+func TestTableDiffConvertCharSet(t *testing.T) {
+	from := aTable(1)
+	to := from.ConvertedTable("utf8mb4", "utf8mb4_general_ci", true, FlavorMySQL57)
+	if to == nil {
+		t.Fatal("Expected ConvertedTable to return non-nil")
+	}
+	if same := to.ConvertedTable("utf8mb4", "utf8mb4_general_ci", true, FlavorMySQL57); same != nil {
+		t.Errorf("Expected ConvertedTable to return nil for an already-converted table, instead found %+v", *same)
+	}
+	for n, col := range to.Columns {
+		if from.Columns[n].CharSet != "" && (col.CharSet != "utf8mb4" || from.Columns[n].CharSet != "utf8") {
+			t.Errorf("Unexpected character set for column %s: from %s to %s", col.Name, from.Columns[n].CharSet, col.CharSet)
+		}
+	}
+
+	td := NewAlterTable(&from, to)
+	stmt, err := td.Statement(StatementModifiers{})
+	if err != nil {
+		t.Fatalf("Unexpected error from Statement: %v", err)
+	}
+	expected := "ALTER TABLE `actor` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci"
+	if stmt != expected {
+		t.Errorf("Unexpected statement:\n  expected: %s\n  found:    %s", expected, stmt)
+	}
+
+	// Converting to latin1 is not lossless, so it's unsafe
+	to = from.ConvertedTable("latin1", "latin1_swedish_ci", true, FlavorMySQL57)
+	td = NewAlterTable(&from, to)
+	if stmt, err := td.Statement(StatementModifiers{}); !IsForbiddenDiff(err) || !strings.Contains(stmt, "CONVERT TO CHARACTER SET latin1") {
+		t.Errorf("Expected forbidden CONVERT TO statement, instead found %q, %v", stmt, err)
+	}
+
+	// If any column keeps its old character set, or is also repositioned, fall
+	// back to ChangeCharSet and individual MODIFY COLUMN clauses
+	to = from.ConvertedTable("utf8mb4", "utf8mb4_general_ci", true, FlavorMySQL57)
+	to.Columns[1] = from.Columns[1]
+	to.CreateStatement = to.GeneratedCreateStatement(FlavorMySQL57)
+	td = NewAlterTable(&from, to)
+	if stmt, err := td.Statement(StatementModifiers{}); err != nil || strings.Contains(stmt, "CONVERT") || !strings.Contains(stmt, "DEFAULT CHARACTER SET = utf8mb4") || strings.Count(stmt, "MODIFY COLUMN") != 2 {
+		t.Errorf("Unexpected return from Statement: %s, %v", stmt, err)
+	}
+	to = from.ConvertedTable("utf8mb4", "utf8mb4_general_ci", true, FlavorMySQL57)
+	to.Columns[1], to.Columns[2] = to.Columns[2], to.Columns[1]
+	to.CreateStatement = to.GeneratedCreateStatement(FlavorMySQL57)
+	td = NewAlterTable(&from, to)
+	if stmt, err := td.Statement(StatementModifiers{}); err != nil || strings.Contains(stmt, "CONVERT") {
+		t.Errorf("Unexpected return from Statement: %s, %v", stmt, err)
+	}
+}
+
+func TestTableKeyLengthOverflows(t *testing.T) {
+	table := Table{
+		Name:   "foo",
+		Engine: "InnoDB",
+		Columns: []*Column{
+			{Name: "id", TypeInDB: "int unsigned"},
+			{Name: "name", TypeInDB: "varchar(255)", CharSet: "utf8", Collation: "utf8_general_ci"},
+			{Name: "descr", TypeInDB: "text", CharSet: "utf8", Collation: "utf8_general_ci"},
+			{Name: "code", TypeInDB: "varbinary(1000)"},
+		},
+		SecondaryIndexes: []*Index{
+			{Name: "name", Type: "BTREE", Parts: []IndexPart{{ColumnName: "name"}}},
+			{Name: "descr", Type: "BTREE", Parts: []IndexPart{{ColumnName: "descr", PrefixLength: 200}}},
+			{Name: "code", Type: "BTREE", Parts: []IndexPart{{ColumnName: "code"}, {ColumnName: "id"}}},
+			{Name: "ft", Type: "FULLTEXT", Parts: []IndexPart{{ColumnName: "descr"}}},
+		},
+	}
+	table.PrimaryKey = primaryKey(table.Columns[0])
+	if overflows := table.KeyLengthOverflows(FlavorMySQL56); len(overflows) != 1 || overflows[0].Index.Name != "code" || overflows[0].Bytes != 1000 || overflows[0].Limit != 767 {
+		t.Errorf("Unexpected overflows in MySQL 5.6: %+v", overflows)
+	}
+	if overflows := table.KeyLengthOverflows(FlavorMySQL57); len(overflows) != 0 {
+		t.Errorf("Unexpected overflows in MySQL 5.7: %+v", overflows)
+	}
+
+	converted := table.ConvertedTable("utf8mb4", "utf8mb4_general_ci", true, FlavorMySQL56)
+	overflows := converted.KeyLengthOverflows(FlavorMySQL56)
+	if len(overflows) != 3 {
+		t.Fatalf("Expected 3 overflows in MySQL 5.6 after conversion, instead found %+v", overflows)
+	}
+	if o := overflows[0]; o.Index.Name != "name" || o.Column.Name != "name" || o.Bytes != 1020 {
+		t.Errorf("Unexpected overflow: %s", o)
+	}
+	if o := overflows[1]; o.Index.Name != "descr" || o.Bytes != 800 {
+		t.Errorf("Unexpected overflow: %s", o)
+	}
+
+	// Specifying a row format supporting large prefixes avoids the problem in 5.7,
+	// but COMPACT does not
+	converted.CreateOptions = "ROW_FORMAT=COMPACT"
+	if overflows := converted.KeyLengthOverflows(FlavorMySQL57); len(overflows) != 3 {
+		t.Errorf("Expected 3 overflows in MySQL 5.7 with ROW_FORMAT=COMPACT, instead found %+v", overflows)
+	}
+	converted.CreateOptions = "ROW_FORMAT=DYNAMIC"
+	if overflows := converted.KeyLengthOverflows(FlavorMySQL57); len(overflows) != 0 {
+		t.Errorf("Expected no overflows in MySQL 5.7 with ROW_FORMAT=DYNAMIC, instead found %+v", overflows)
+	}
+
+	// Total index length limit applies regardless of row format
+	converted.Columns[1].TypeInDB = "varchar(800)"
+	if overflows := converted.KeyLengthOverflows(FlavorMySQL57); len(overflows) != 2 || overflows[1].Column != nil || overflows[1].Bytes != 3200 {
+		t.Errorf("Unexpected overflows: %+v", overflows)
+	}
+
+	converted.Engine = "MyISAM"
+	if overflows := converted.KeyLengthOverflows(FlavorMySQL57); overflows != nil {
+		t.Errorf("Expected no overflows for MyISAM table, instead found %+v", overflows)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstancePlanCharSetConversion(t *testing.T) {
+	schema := s.GetSchema(t, "testing")
+	if _, err := s.d.PlanCharSetConversion(schema, "doesnt_exist", ""); err == nil {
+		t.Error("Expected error for invalid character set, but err was nil")
+	}
+	if _, err := s.d.PlanCharSetConversion(schema, "utf8mb4", "latin1_swedish_ci"); err == nil {
+		t.Error("Expected error for mismatched collation, but err was nil")
+	}
+	plan, err := s.d.PlanCharSetConversion(schema, "utf8mb4", "utf8mb4_general_ci")
+	if err != nil {
+		t.Fatalf("Unexpected error from PlanCharSetConversion: %v", err)
+	} else if len(plan.Tables) == 0 {
+		t.Fatal("Expected at least one table requiring conversion, but found none")
+	}
+
+	db, err := s.d.ConnectionPool("testing", "foreign_key_checks=0")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer db.Close()
+	for _, conv := range plan.Tables {
+		if len(conv.Overflows) > 0 {
+			continue
+		}
+		stmt, err := conv.Diff.Statement(StatementModifiers{Flavor: s.d.Flavor(), AllowUnsafe: true})
+		if err != nil {
+			t.Errorf("Unexpected error from Statement for %s: %v", conv.From.Name, err)
+			continue
+		} else if stmt == "" {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			t.Errorf("Unexpected error executing %s: %v", stmt, err)
+			continue
+		}
+		actual, err := s.d.Schema("testing")
+		if err != nil {
+			t.Fatalf("Unexpected error introspecting: %v", err)
+		}
+		table := actual.Table(conv.From.Name)
+		if table.CharSet != "utf8mb4" || table.Collation != "utf8mb4_general_ci" {
+			t.Errorf("Expected %s to be converted, instead found %s / %s", table.Name, table.CharSet, table.Collation)
+		}
+		for _, col := range table.Columns {
+			if col.CharSet != "" && col.CharSet != "utf8mb4" {
+				t.Errorf("Expected %s.%s to be converted, instead found %s", table.Name, col.Name, col.CharSet)
+			}
+		}
+	}
+}
@@ -24,6 +24,39 @@ type Column struct {
 	CheckClause        string `json:"check,omitempty"`     // Only non-empty for MariaDB inline check constraint clause
 }
 
+// MaxIntegerValue returns the largest value that may be stored in the column,
+// if it has an integer type, along with true. If the column does not have an
+// integer type, 0 and false are returned.
+func (c *Column) MaxIntegerValue() (uint64, bool) {
+	typ := strings.ToLower(c.TypeInDB)
+	baseType := typ
+	if pos := strings.IndexAny(typ, "( "); pos > -1 {
+		baseType = typ[0:pos]
+	}
+	var bits uint
+	switch baseType {
+	case "tinyint":
+		bits = 8
+	case "smallint":
+		bits = 16
+	case "mediumint":
+		bits = 24
+	case "int", "integer":
+		bits = 32
+	case "bigint":
+		bits = 64
+	default:
+		return 0, false
+	}
+	if !strings.Contains(typ, " unsigned") {
+		bits--
+	}
+	if bits == 64 {
+		return ^uint64(0), true
+	}
+	return (uint64(1) << bits) - 1, true
+}
+
 // Definition returns this column's definition clause, for use as part of a DDL
 // statement. A table may optionally be supplied, which simply causes CHARACTER
 // SET clause to be omitted if the table and column have the same *collation*
@@ -0,0 +1,217 @@
+package tengo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Incompatibility describes use of a feature in a schema which is unsupported
+// by, or problematic in, a particular flavor.
+type Incompatibility struct {
+	ObjectKey ObjectKey
+	Message   string
+	Warning   bool // true if the feature still works in the flavor, but is deprecated or may break queries
+}
+
+func (inc Incompatibility) String() string {
+	if inc.Warning {
+		return fmt.Sprintf("%s: %s (warning)", inc.ObjectKey, inc.Message)
+	}
+	return fmt.Sprintf("%s: %s", inc.ObjectKey, inc.Message)
+}
+
+// CheckCompatibility examines the schema for features which are incompatible
+// with flavor, for example prior to upgrading or migrating to a different
+// database server. The schema may have been introspected from any flavor.
+// Results are ordered by object, with the schema's tables first, followed by
+// its routines. An empty result does not guarantee compatibility, as some
+// differences (for example, in routine bodies or SQL modes) are not detected.
+func (s *Schema) CheckCompatibility(flavor Flavor) []Incompatibility {
+	var result []Incompatibility
+	schemaKey := ObjectKey{Type: ObjectTypeDatabase, Name: s.Name}
+	if problem := charSetIncompatibility(s.CharSet, s.Collation, flavor); problem != nil {
+		problem.ObjectKey = schemaKey
+		result = append(result, *problem)
+	}
+	for _, t := range s.Tables {
+		result = append(result, t.checkCompatibility(flavor)...)
+	}
+	for _, r := range s.Routines {
+		if word := reservedWord(r.Name, flavor); word != "" {
+			result = append(result, Incompatibility{
+				ObjectKey: ObjectKey{Type: r.Type, Name: r.Name},
+				Message:   fmt.Sprintf("name %s is a reserved word", word),
+				Warning:   true,
+			})
+		}
+	}
+	return result
+}
+
+// checkCompatibility returns incompatibilities between the table and flavor.
+func (t *Table) checkCompatibility(flavor Flavor) []Incompatibility {
+	key := ObjectKey{Type: ObjectTypeTable, Name: t.Name}
+	var result []Incompatibility
+	add := func(warning bool, format string, a ...interface{}) {
+		result = append(result, Incompatibility{
+			ObjectKey: key,
+			Message:   fmt.Sprintf(format, a...),
+			Warning:   warning,
+		})
+	}
+	if t.UnsupportedDDL {
+		add(true, "table uses features unsupported by this package; additional incompatibilities may not be detected")
+	}
+	if word := reservedWord(t.Name, flavor); word != "" {
+		add(true, "table name %s is a reserved word", word)
+	}
+	if problem := charSetIncompatibility(t.CharSet, t.Collation, flavor); problem != nil {
+		add(problem.Warning, "default %s", problem.Message)
+	}
+	if t.Partitioning != nil && flavor.HasDataDictionary() && t.Engine != "InnoDB" && t.Engine != "ndbcluster" {
+		add(false, "partitioning is not supported by storage engine %s", t.Engine)
+	}
+
+	for _, col := range t.Columns {
+		name := EscapeIdentifier(col.Name)
+		if word := reservedWord(col.Name, flavor); word != "" {
+			add(true, "column name %s is a reserved word", word)
+		}
+		if problem := charSetIncompatibility(col.CharSet, col.Collation, flavor); problem != nil {
+			add(problem.Warning, "column %s %s", name, problem.Message)
+		}
+		if col.GenerationExpr != "" && !flavor.GeneratedColumns() {
+			add(false, "column %s is a generated column, which is not supported", name)
+		}
+		if col.Invisible && !flavor.InvisibleColumns() {
+			add(false, "column %s is an invisible column, which is not supported", name)
+		}
+		if col.Compression != "" && !flavor.ColumnCompression() {
+			add(false, "column %s uses column compression, which is not supported", name)
+		}
+		if col.CheckClause != "" && !flavor.HasCheckConstraints() {
+			add(false, "column %s has an inline check constraint, which is not supported", name)
+		}
+		if !flavor.FractionalTimestamps() && strings.Contains(col.TypeInDB, "(") && (strings.HasPrefix(col.TypeInDB, "timestamp") || strings.HasPrefix(col.TypeInDB, "datetime") || strings.HasPrefix(col.TypeInDB, "time(")) {
+			add(false, "column %s type %s uses fractional seconds, which are not supported", name, col.TypeInDB)
+		}
+		if strings.HasPrefix(col.Default, "(") && !flavor.MySQLishMinVersion(8, 0, 13) && !flavor.VendorMinVersion(VendorMariaDB, 10, 2) {
+			add(false, "column %s default expression %s is not supported", name, col.Default)
+		} else if col.Default != "" && col.Default != "NULL" && !strings.HasPrefix(col.Default, "(") && !flavor.AllowBlobDefaults() && (strings.HasSuffix(col.TypeInDB, "blob") || strings.HasSuffix(col.TypeInDB, "text")) {
+			add(false, "column %s has a literal default, which is not supported for type %s", name, col.TypeInDB)
+		}
+	}
+
+	indexes := t.SecondaryIndexes
+	if t.PrimaryKey != nil {
+		indexes = append([]*Index{t.PrimaryKey}, indexes...)
+	}
+	for _, idx := range indexes {
+		name := EscapeIdentifier(idx.Name)
+		if word := reservedWord(idx.Name, flavor); word != "" && !idx.PrimaryKey {
+			add(true, "index name %s is a reserved word", word)
+		}
+		if idx.Invisible && !flavor.InvisibleIndexes() {
+			add(false, "index %s is an invisible index, which is not supported", name)
+		}
+		for _, part := range idx.Parts {
+			if part.Expression != "" && !flavor.FunctionalIndexes() {
+				add(false, "index %s has functional part %s, which is not supported", name, part.Expression)
+			}
+			if part.Descending && !flavor.DescendingIndexes() {
+				// Other flavors accept the syntax but silently store the part in
+				// ascending order
+				add(true, "index %s has a descending part, which will be ignored", name)
+			}
+		}
+	}
+
+	for _, fk := range t.ForeignKeys {
+		if word := reservedWord(fk.Name, flavor); word != "" {
+			add(true, "foreign key name %s is a reserved word", word)
+		}
+	}
+	for _, cc := range t.Checks {
+		if !flavor.HasCheckConstraints() {
+			add(false, "check constraint %s is not supported", EscapeIdentifier(cc.Name))
+		} else if !cc.Enforced && flavor.Vendor == VendorMariaDB {
+			add(false, "check constraint %s is NOT ENFORCED, which is not supported", EscapeIdentifier(cc.Name))
+		}
+	}
+	return result
+}
+
+// charSetIncompatibility returns a non-nil Incompatibility, lacking an
+// ObjectKey, if the character set or collation is problematic in flavor.
+func charSetIncompatibility(charSet, collation string, flavor Flavor) *Incompatibility {
+	if strings.HasPrefix(collation, "utf8mb4_0900_") && !flavor.HasDataDictionary() {
+		return &Incompatibility{Message: fmt.Sprintf("collation %s is not supported", collation)}
+	}
+	if (charSet == "utf8" || charSet == "utf8mb3") && flavor.MySQLishMinVersion(8, 0) {
+		return &Incompatibility{
+			Message: fmt.Sprintf("character set %s is a deprecated alias for utf8mb3, and may be removed in a future release", charSet),
+			Warning: true,
+		}
+	}
+	return nil
+}
+
+// reservedWords maps keywords which became reserved in relatively recent
+// flavors, to the minimum MySQL and MariaDB versions in which they are
+// reserved. A nil version indicates the word is not reserved in that vendor's
+// releases. Words which have been reserved in all supported flavors are not
+// included, since schemas cannot newly begin using them as identifiers.
+var reservedWords = map[string][2][]int{
+	"get":             {{5, 6}, nil},
+	"io_after_gtids":  {{5, 6}, nil},
+	"io_before_gtids": {{5, 6}, nil},
+	"master_bind":     {{5, 6}, nil},
+	"partition":       {{5, 6}, nil},
+	"generated":       {{5, 7}, nil},
+	"optimizer_costs": {{5, 7}, nil},
+	"stored":          {{5, 7}, nil},
+	"virtual":         {{5, 7}, nil},
+	"cube":            {{8, 0}, nil},
+	"cume_dist":       {{8, 0}, nil},
+	"dense_rank":      {{8, 0}, nil},
+	"empty":           {{8, 0}, nil},
+	"except":          {{8, 0}, {10, 3}},
+	"first_value":     {{8, 0}, nil},
+	"function":        {{8, 0}, nil},
+	"grouping":        {{8, 0}, nil},
+	"groups":          {{8, 0}, nil},
+	"json_table":      {{8, 0}, nil},
+	"lag":             {{8, 0}, nil},
+	"last_value":      {{8, 0}, nil},
+	"lateral":         {{8, 0, 14}, nil},
+	"lead":            {{8, 0}, nil},
+	"nth_value":       {{8, 0}, nil},
+	"ntile":           {{8, 0}, nil},
+	"of":              {{8, 0}, nil},
+	"over":            {{8, 0}, {10, 2}},
+	"percent_rank":    {{8, 0}, nil},
+	"rank":            {{8, 0}, nil},
+	"recursive":       {{8, 0}, {10, 2}},
+	"row":             {{8, 0}, nil},
+	"rows":            {{8, 0}, {10, 2}},
+	"row_number":      {{8, 0}, nil},
+	"system":          {{8, 0}, nil},
+	"window":          {{8, 0}, nil},
+	"array":           {{8, 0, 17}, nil},
+	"member":          {{8, 0, 17}, nil},
+	"intersect":       {{8, 0, 31}, {10, 3}},
+}
+
+// reservedWord returns the escaped identifier name if it is a reserved word in
+// flavor, or an empty string otherwise. Since tengo always escapes identifiers,
+// this only affects application queries which fail to do so.
+func reservedWord(name string, flavor Flavor) string {
+	versions, ok := reservedWords[strings.ToLower(name)]
+	if !ok {
+		return ""
+	}
+	if (versions[0] != nil && flavor.MySQLishMinVersion(versions[0]...)) || (versions[1] != nil && flavor.VendorMinVersion(VendorMariaDB, versions[1]...)) {
+		return EscapeIdentifier(name)
+	}
+	return ""
+}
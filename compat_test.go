@@ -0,0 +1,100 @@
+package tengo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaCheckCompatibility(t *testing.T) {
+	table := aTableForFlavor(FlavorMySQL80, 1)
+	table.Columns[0].Invisible = true
+	table.Columns[1].Name = "rank"
+	table.Columns[2].Compression = "COMPRESSED"
+	table.Columns = append(table.Columns, &Column{
+		Name:           "ssn_upper",
+		TypeInDB:       "char(10)",
+		GenerationExpr: "upper(`ssn`)",
+		Nullable:       true,
+		Default:        "NULL",
+	})
+	table.SecondaryIndexes[0].Invisible = true
+	table.SecondaryIndexes[1].Parts[0].Descending = true
+	table.SecondaryIndexes = append(table.SecondaryIndexes, &Index{
+		Name:  "idx_expr",
+		Parts: []IndexPart{{Expression: "(lower(`ssn`))"}},
+		Type:  "BTREE",
+	})
+	table.Checks = []*Check{{Name: "alivecheck", Clause: "`alive` != 0", Enforced: false}}
+	schema := aSchema("compat", &table)
+	schema.CharSet, schema.Collation = "utf8mb4", "utf8mb4_0900_ai_ci"
+
+	// Compatible with the flavor the schema was "introspected" from, aside from
+	// some warnings
+	flavor := Flavor{VendorPercona, 8, 0, 31}
+	for _, inc := range schema.CheckCompatibility(flavor) {
+		if !inc.Warning {
+			t.Errorf("Unexpected non-warning incompatibility with %s: %s", flavor, inc)
+		}
+	}
+
+	expectMessages := func(flavor Flavor, substrings ...string) {
+		t.Helper()
+		incs := schema.CheckCompatibility(flavor)
+		if len(incs) != len(substrings) {
+			t.Errorf("Expected %d incompatibilities with %s, instead found %d: %v", len(substrings), flavor, len(incs), incs)
+			return
+		}
+		for n, inc := range incs {
+			if !strings.Contains(inc.String(), substrings[n]) {
+				t.Errorf("Expected incompatibility %d with %s to contain %q, instead found %q", n, flavor, substrings[n], inc)
+			}
+		}
+	}
+	expectMessages(FlavorMySQL57,
+		"database `compat`: collation utf8mb4_0900_ai_ci is not supported",
+		"`actor_id` is an invisible column",
+		"`last_name` uses column compression",
+		"`idx_ssn` is an invisible index",
+		"`idx_actor_name` has a descending part, which will be ignored (warning)",
+		"`idx_expr` has functional part",
+		"`alivecheck` is not supported",
+	)
+	expectMessages(FlavorMariaDB105,
+		"collation utf8mb4_0900_ai_ci is not supported",
+		"`idx_ssn` is an invisible index",
+		"`idx_actor_name` has a descending part",
+		"`idx_expr` has functional part",
+		"`alivecheck` is NOT ENFORCED",
+	)
+	expectMessages(FlavorMySQL56,
+		"collation utf8mb4_0900_ai_ci is not supported",
+		"`actor_id` is an invisible column",
+		"`last_name` uses column compression",
+		"`ssn_upper` is a generated column",
+		"`idx_ssn` is an invisible index",
+		"`idx_actor_name` has a descending part",
+		"`idx_expr` has functional part",
+		"`alivecheck` is not supported",
+	)
+}
+
+func TestReservedWord(t *testing.T) {
+	cases := []struct {
+		name     string
+		flavor   Flavor
+		expected string
+	}{
+		{"rank", FlavorMySQL57, ""},
+		{"Rank", FlavorMySQL80, "`Rank`"},
+		{"rank", FlavorMariaDB105, ""},
+		{"rows", FlavorMariaDB102, "`rows`"},
+		{"intersect", FlavorMySQL80, ""},
+		{"intersect", Flavor{VendorMySQL, 8, 0, 31}, "`intersect`"},
+		{"actor", FlavorMySQL80, ""},
+	}
+	for _, tc := range cases {
+		if actual := reservedWord(tc.name, tc.flavor); actual != tc.expected {
+			t.Errorf("Expected reservedWord(%q, %s) to return %q, instead found %q", tc.name, tc.flavor, tc.expected, actual)
+		}
+	}
+}
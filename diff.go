@@ -67,13 +67,27 @@ const (
 	PartitioningKeep                               // negate REMOVE PARTITIONING clauses from ALTERs
 )
 
+// VitessMode enumerates ways of handling features which are not supported by
+// Vitess: foreign keys, stored routines, and changes to the partitioning of
+// existing tables.
+type VitessMode int
+
+// Constants for how to handle features unsupported by Vitess.
+const (
+	VitessPermissive VitessMode = iota // don't restrict any features
+	VitessReject                       // return a ForbiddenDiffError for statements using unsupported features
+	VitessStrip                        // omit unsupported clauses and statements from DDL
+)
+
 // StatementModifiers are options that may be applied to adjust the DDL emitted
 // for a particular table, and/or generate errors if certain clauses are
 // present.
 type StatementModifiers struct {
 	NextAutoInc            NextAutoIncMode  // How to handle differences in next-auto-inc values
 	Partitioning           PartitioningMode // How to handle differences in partitioning status
+	Vitess                 VitessMode       // How to handle features which Vitess does not support
 	AllowUnsafe            bool             // Whether to allow potentially-destructive DDL (drop table, drop column, modify col type, etc)
+	SafeBySize             *SizeGate        // If non-nil and AllowUnsafe is false, allow unsafe DDL on tables that are small or empty
 	LockClause             string           // Include a LOCK=[value] clause in generated ALTER TABLE
 	AlgorithmClause        string           // Include an ALGORITHM=[value] clause in generated ALTER TABLE
 	IgnoreTable            *regexp.Regexp   // Generate blank DDL if table name matches this regexp
@@ -86,6 +100,58 @@ type StatementModifiers struct {
 	Flavor                 Flavor           // Adjust generated DDL to match vendor/version. Zero value is FlavorUnknown which makes no adjustments.
 }
 
+// SizeGate permits unsafe table DDL based on the live size of the table, as
+// queried from an instance. It is used via StatementModifiers.SafeBySize. A
+// table qualifies if its size is below MaxBytes, or if AllowEmpty is true and
+// it has no rows. If querying fails, the table does not qualify.
+type SizeGate struct {
+	Instance   *Instance
+	Schema     string // name of the schema on Instance containing the tables
+	MaxBytes   int64  // permit unsafe DDL on tables smaller than this; 0 to disable size check
+	AllowEmpty bool   // permit unsafe DDL on tables with no rows, regardless of size
+}
+
+// permits returns true if unsafe DDL is permitted on table t. If not, a
+// description of why is also returned.
+func (gate *SizeGate) permits(t *Table) (bool, string) {
+	if gate.MaxBytes > 0 {
+		size, err := gate.Instance.TableSize(gate.Schema, t.Name)
+		if err != nil {
+			return false, fmt.Sprintf("unable to determine size of table %s: %s", EscapeIdentifier(t.Name), err)
+		} else if size < gate.MaxBytes {
+			return true, ""
+		}
+		if !gate.AllowEmpty {
+			return false, fmt.Sprintf("table %s size %d bytes is not below threshold of %d bytes", EscapeIdentifier(t.Name), size, gate.MaxBytes)
+		}
+	}
+	if gate.AllowEmpty {
+		hasRows, err := gate.Instance.TableHasRows(gate.Schema, t.Name)
+		if err != nil {
+			return false, fmt.Sprintf("unable to determine if table %s has rows: %s", EscapeIdentifier(t.Name), err)
+		} else if !hasRows {
+			return true, ""
+		}
+		return false, fmt.Sprintf("table %s has rows", EscapeIdentifier(t.Name))
+	}
+	return false, ""
+}
+
+// allowUnsafe returns true if mods permit unsafe DDL on table t. If not, a
+// reason suffix for ForbiddenDiffError is also returned.
+func (mods StatementModifiers) allowUnsafe(t *Table) (bool, string) {
+	if mods.AllowUnsafe {
+		return true, ""
+	} else if mods.SafeBySize == nil {
+		return false, ""
+	}
+	ok, why := mods.SafeBySize.permits(t)
+	if why != "" {
+		why = " (" + why + ")"
+	}
+	return ok, why
+}
+
 ///// SchemaDiff ///////////////////////////////////////////////////////////////
 
 // SchemaDiff represents a set of differences between two database schemas,
@@ -539,15 +605,35 @@ func (td *TableDiff) Statement(mods StatementModifiers) (string, error) {
 		if td.To.HasAutoIncrement() && (mods.NextAutoInc == NextAutoIncIgnore || mods.NextAutoInc == NextAutoIncIfAlready) {
 			stmt, _ = ParseCreateAutoInc(stmt)
 		}
-		return stmt, nil
+		if len(td.To.ForeignKeys) > 0 && mods.Vitess == VitessStrip {
+			stmt = stripForeignKeys(stmt)
+		} else if len(td.To.ForeignKeys) > 0 && mods.Vitess == VitessReject {
+			clauses := make([]UnsafeClause, len(td.To.ForeignKeys))
+			for n, fk := range td.To.ForeignKeys {
+				clauses[n] = UnsafeClause{
+					Clause: fk.Definition(mods.Flavor),
+					Reason: vitessForeignKeyReason(fk),
+				}
+			}
+			err = &ForbiddenDiffError{
+				Reason:    "Foreign keys not supported by Vitess",
+				Statement: stmt,
+				Clauses:   clauses,
+			}
+		}
+		return stmt, err
 	case DiffTypeAlter:
 		return td.alterStatement(mods)
 	case DiffTypeDrop:
 		stmt := td.From.DropStatement()
-		if !mods.AllowUnsafe {
+		if ok, why := mods.allowUnsafe(td.From); !ok {
 			err = &ForbiddenDiffError{
-				Reason:    "DROP TABLE not permitted",
+				Reason:    "DROP TABLE not permitted" + why,
 				Statement: stmt,
+				Clauses: []UnsafeClause{{
+					Clause: stmt,
+					Reason: fmt.Sprintf("table %s is being dropped; all of its rows would be lost", EscapeIdentifier(td.From.Name)),
+				}},
 			}
 		}
 		return stmt, err
@@ -610,17 +696,22 @@ func (td *TableDiff) alterStatement(mods StatementModifiers) (string, error) {
 
 	clauseStrings := make([]string, 0, len(td.alterClauses))
 	var partitionClauseString string
-	var err error
+	var unsafeClauses, vitessClauses []UnsafeClause
 	for _, clause := range td.alterClauses {
-		if err == nil && !mods.AllowUnsafe {
-			if clause, ok := clause.(Unsafer); ok && clause.Unsafe() {
-				err = &ForbiddenDiffError{
-					Reason:    "Unsafe or potentially destructive ALTER TABLE not permitted",
-					Statement: "",
-				}
-			}
+		clauseString := clause.Clause(mods)
+		if reason := vitessUnsupportedReason(clause); reason != "" && clauseString != "" && mods.Vitess == VitessReject {
+			vitessClauses = append(vitessClauses, UnsafeClause{
+				Clause: clauseString,
+				Reason: reason,
+			})
+		}
+		if unsafer, ok := clause.(Unsafer); ok && unsafer.Unsafe() {
+			unsafeClauses = append(unsafeClauses, UnsafeClause{
+				Clause: clauseString,
+				Reason: unsafer.UnsafeReason(),
+			})
 		}
-		if clauseString := clause.Clause(mods); clauseString != "" {
+		if clauseString != "" {
 			switch clause.(type) {
 			case PartitionBy, RemovePartitioning:
 				// Adding or removing partitioning must occur at the end of the ALTER
@@ -668,10 +759,23 @@ func (td *TableDiff) alterStatement(mods StatementModifiers) (string, error) {
 		partitionClauseString = fmt.Sprintf(" %s", partitionClauseString)
 	}
 	stmt := fmt.Sprintf("%s %s%s", td.From.AlterStatement(), strings.Join(clauseStrings, ", "), partitionClauseString)
-	if fde, isForbiddenDiff := err.(*ForbiddenDiffError); isForbiddenDiff {
-		fde.Statement = stmt
+	if len(vitessClauses) > 0 {
+		return stmt, &ForbiddenDiffError{
+			Reason:    "ALTER TABLE uses features not supported by Vitess",
+			Statement: stmt,
+			Clauses:   vitessClauses,
+		}
+	}
+	if len(unsafeClauses) > 0 {
+		if ok, why := mods.allowUnsafe(td.From); !ok {
+			return stmt, &ForbiddenDiffError{
+				Reason:    "Unsafe or potentially destructive ALTER TABLE not permitted" + why,
+				Statement: stmt,
+				Clauses:   unsafeClauses,
+			}
+		}
 	}
-	return stmt, err
+	return stmt, nil
 }
 
 ///// RoutineDiff //////////////////////////////////////////////////////////////
@@ -721,6 +825,15 @@ func (rd *RoutineDiff) Statement(mods StatementModifiers) (string, error) {
 	if rd != nil && rd.ForMetadata && !mods.CompareMetadata {
 		return "", nil
 	}
+	// Vitess does not support stored routines at all
+	if rd != nil && rd.DiffType() != DiffTypeNone && mods.Vitess == VitessStrip {
+		return "", nil
+	} else if rd != nil && rd.DiffType() == DiffTypeCreate && mods.Vitess == VitessReject {
+		return rd.To.CreateStatement, &ForbiddenDiffError{
+			Reason:    fmt.Sprintf("%s not supported by Vitess", rd.To.Type.Caps()),
+			Statement: rd.To.CreateStatement,
+		}
+	}
 	switch rd.DiffType() {
 	case DiffTypeNone:
 		return "", nil
@@ -753,6 +866,14 @@ func (rd *RoutineDiff) Statement(mods StatementModifiers) (string, error) {
 type ForbiddenDiffError struct {
 	Reason    string
 	Statement string
+	Clauses   []UnsafeClause // offending clauses, if the diff is for a table
+}
+
+// UnsafeClause describes one potentially-destructive part of a forbidden
+// statement.
+type UnsafeClause struct {
+	Clause string // text of the clause, or "" if it has no text with the supplied modifiers
+	Reason string // why the clause is unsafe, and what data could be lost
 }
 
 // Error satisfies the builtin error interface.
@@ -760,6 +881,20 @@ func (e *ForbiddenDiffError) Error() string {
 	return e.Reason
 }
 
+// ExtendedError returns a string listing each offending clause and why it is
+// unsafe, one per line.
+func (e *ForbiddenDiffError) ExtendedError() string {
+	lines := make([]string, len(e.Clauses))
+	for n, uc := range e.Clauses {
+		if uc.Clause == "" {
+			lines[n] = uc.Reason
+		} else {
+			lines[n] = fmt.Sprintf("%s: %s", uc.Clause, uc.Reason)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
 // IsForbiddenDiff returns true if err represents an "unsafe" alteration that
 // has not explicitly been permitted by the supplied StatementModifiers.
 func IsForbiddenDiff(err error) bool {
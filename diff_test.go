@@ -734,6 +734,100 @@ func TestAlterTableStatementAllowUnsafeMods(t *testing.T) {
 	assertUnsafe(&s1, &s2)
 }
 
+func TestForbiddenDiffErrorClauses(t *testing.T) {
+	t1 := aTable(1)
+	t2 := aTable(1)
+	t2.Columns[1].TypeInDB = "varchar(40)"
+	t2.Columns = t2.Columns[0 : len(t2.Columns)-1]
+	t2.CreateStatement = t2.GeneratedCreateStatement(FlavorUnknown)
+	s1 := aSchema("s1", &t1)
+	s2 := aSchema("s2", &t2)
+	sd := NewSchemaDiff(&s1, &s2)
+	if len(sd.TableDiffs) != 1 {
+		t.Fatalf("Incorrect number of table diffs: expected 1, found %d", len(sd.TableDiffs))
+	}
+	stmt, err := sd.TableDiffs[0].Statement(StatementModifiers{})
+	fde, ok := err.(*ForbiddenDiffError)
+	if !ok {
+		t.Fatalf("Expected ForbiddenDiffError, instead found %v", err)
+	} else if fde.Statement != stmt || stmt == "" {
+		t.Errorf("Expected error's statement to match returned statement %q, instead found %q", stmt, fde.Statement)
+	}
+	if len(fde.Clauses) != 2 {
+		t.Fatalf("Expected 2 unsafe clauses, instead found %+v", fde.Clauses)
+	}
+	for _, uc := range fde.Clauses {
+		if uc.Clause == "" || uc.Reason == "" || !strings.Contains(stmt, uc.Clause) {
+			t.Errorf("Unexpected unsafe clause %+v", uc)
+		}
+	}
+	if !strings.Contains(fde.Clauses[0].Reason, "dropped") || !strings.Contains(fde.Clauses[1].Reason, "truncated") {
+		t.Errorf("Unexpected reasons in unsafe clauses: %+v", fde.Clauses)
+	}
+	if lines := strings.Split(fde.ExtendedError(), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], fde.Clauses[0].Clause) {
+		t.Errorf("Unexpected return from ExtendedError: %s", fde.ExtendedError())
+	}
+
+	drop := NewDropTable(&t1)
+	if _, err := drop.Statement(StatementModifiers{}); err == nil {
+		t.Error("Expected DROP TABLE to be forbidden, but err was nil")
+	} else if fde := err.(*ForbiddenDiffError); len(fde.Clauses) != 1 || fde.Clauses[0].Clause != fde.Statement {
+		t.Errorf("Unexpected clauses for DROP TABLE: %+v", fde.Clauses)
+	}
+}
+
+func (s TengoIntegrationSuite) TestStatementModifiersSafeBySize(t *testing.T) {
+	from := s.GetSchema(t, "testing")
+	to := s.GetSchema(t, "testing")
+	for _, name := range []string{"has_rows", "no_rows"} {
+		table := to.Table(name)
+		table.Columns = table.Columns[0 : len(table.Columns)-1]
+		table.CreateStatement = table.GeneratedCreateStatement(s.d.Flavor())
+	}
+	alters := make(map[string]*TableDiff)
+	for _, td := range from.Diff(to).TableDiffs {
+		alters[td.ObjectKey().Name] = td
+	}
+	if len(alters) != 2 || alters["has_rows"] == nil || alters["no_rows"] == nil {
+		t.Fatalf("Unexpected table diffs: %+v", alters)
+	}
+	hasRowsSize, err := s.d.TableSize("testing", "has_rows")
+	if err != nil {
+		t.Fatalf("Unexpected error from TableSize: %v", err)
+	}
+
+	assertForbidden := func(gate *SizeGate, tableName string, expectForbidden bool) {
+		t.Helper()
+		mods := StatementModifiers{SafeBySize: gate}
+		if _, err := alters[tableName].Statement(mods); IsForbiddenDiff(err) != expectForbidden {
+			t.Errorf("With gate %+v on table %s, expected forbidden=%t, instead found err=%v", *gate, tableName, expectForbidden, err)
+		}
+	}
+	gate := &SizeGate{Instance: s.d.Instance, Schema: "testing", AllowEmpty: true}
+	assertForbidden(gate, "has_rows", true)
+	assertForbidden(gate, "no_rows", false)
+	gate.MaxBytes = hasRowsSize
+	assertForbidden(gate, "has_rows", true)
+	gate.MaxBytes = hasRowsSize + 1
+	assertForbidden(gate, "has_rows", false)
+	gate.MaxBytes, gate.AllowEmpty = 1, false
+	assertForbidden(gate, "no_rows", true)
+
+	// DROP TABLE is subject to the same gate
+	drop := NewDropTable(from.Table("no_rows"))
+	if _, err := drop.Statement(StatementModifiers{SafeBySize: gate}); !IsForbiddenDiff(err) {
+		t.Errorf("Expected DROP TABLE to be forbidden, instead err=%v", err)
+	}
+	gate.AllowEmpty = true
+	if _, err := drop.Statement(StatementModifiers{SafeBySize: gate}); err != nil {
+		t.Errorf("Unexpected error from DROP TABLE statement: %v", err)
+	}
+
+	// Querying a nonexistent table never qualifies
+	gate.Schema = "doesnt_exist"
+	assertForbidden(gate, "no_rows", true)
+}
+
 func TestAlterTableStatementOnlineMods(t *testing.T) {
 	from := anotherTable()
 	to := anotherTable()
@@ -1,6 +1,11 @@
 package tengo
 
 import (
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+
 	"github.com/VividCortex/mysqlerr"
 	"github.com/go-sql-driver/mysql"
 )
@@ -8,9 +13,11 @@ import (
 // IsDatabaseError returns true if err came from a database server, typically
 // as a response to a query or connection attempt.
 // If one or more specificErrors are supplied, IsDatabaseError only returns true
-// if the database error code matched one of those numbers.
+// if the database error code matched one of those numbers. Wrapped errors are
+// unwrapped as needed.
 func IsDatabaseError(err error, specificErrors ...uint16) bool {
-	merr, ok := err.(*mysql.MySQLError)
+	var merr *mysql.MySQLError
+	ok := errors.As(err, &merr)
 	if !ok || len(specificErrors) == 0 {
 		return ok
 	}
@@ -41,6 +48,82 @@ func IsAccessError(err error) bool {
 		mysqlerr.ER_HOST_NOT_PRIVILEGED,
 		mysqlerr.ER_HOST_IS_BLOCKED,
 		mysqlerr.ER_SPECIFIC_ACCESS_DENIED_ERROR,
+		mysqlerr.ER_KILL_DENIED_ERROR,
 	}
 	return IsDatabaseError(err, authErrors...)
 }
+
+// ErrorClass categorizes errors based on whether, and why, an operation
+// encountering the error may succeed if retried.
+type ErrorClass int
+
+// Constants enumerating valid ErrorClass values
+const (
+	ErrorClassFatal              ErrorClass = iota // Retrying will not help, or error is not from the database
+	ErrorClassDeadlock                             // Transaction or DDL was rolled back due to a deadlock
+	ErrorClassLockWaitTimeout                      // Row lock or metadata lock wait timed out
+	ErrorClassConnectionLost                       // Connection was lost or could not be established, e.g. server restart
+	ErrorClassTooManyConnections                   // Server or per-user connection limit reached
+	ErrorClassReadOnly                             // Server is read-only, e.g. a former primary after failover
+)
+
+func (class ErrorClass) String() string {
+	switch class {
+	case ErrorClassDeadlock:
+		return "deadlock"
+	case ErrorClassLockWaitTimeout:
+		return "lock wait timeout"
+	case ErrorClassConnectionLost:
+		return "connection lost"
+	case ErrorClassTooManyConnections:
+		return "too many connections"
+	case ErrorClassReadOnly:
+		return "read-only"
+	default:
+		return "fatal"
+	}
+}
+
+// ClassifyError returns the ErrorClass of err. Errors wrapped using
+// fmt.Errorf's %w verb are unwrapped. A nil err is considered fatal, since
+// there is nothing to retry.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassFatal
+	}
+	var merr *mysql.MySQLError
+	if errors.As(err, &merr) {
+		switch merr.Number {
+		case mysqlerr.ER_LOCK_DEADLOCK:
+			return ErrorClassDeadlock
+		case mysqlerr.ER_LOCK_WAIT_TIMEOUT:
+			return ErrorClassLockWaitTimeout
+		case mysqlerr.ER_CON_COUNT_ERROR, mysqlerr.ER_TOO_MANY_USER_CONNECTIONS:
+			return ErrorClassTooManyConnections
+		case mysqlerr.ER_SERVER_SHUTDOWN:
+			return ErrorClassConnectionLost
+		case mysqlerr.ER_READ_ONLY_MODE:
+			return ErrorClassReadOnly
+		case mysqlerr.ER_OPTION_PREVENTS_STATEMENT:
+			// This error is also used for other server options, such as
+			// secure_file_priv, which are not transient
+			if msg := strings.ToLower(merr.Message); strings.Contains(msg, "read-only") || strings.Contains(msg, "read_only") {
+				return ErrorClassReadOnly
+			}
+		}
+		return ErrorClassFatal
+	}
+	var netErr net.Error
+	if errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, driver.ErrBadConn) || errors.As(err, &netErr) {
+		return ErrorClassConnectionLost
+	}
+	return ErrorClassFatal
+}
+
+// IsRetryableError returns true if err is transient, meaning that the
+// operation which encountered it may succeed if retried. Note that retrying
+// is not necessarily safe for non-idempotent operations if the connection was
+// lost, since the server may have executed the statement.
+func IsRetryableError(err error) bool {
+	return ClassifyError(err) != ErrorClassFatal
+}
@@ -1,9 +1,14 @@
 package tengo
 
 import (
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"net"
 	"testing"
+
+	"github.com/VividCortex/mysqlerr"
+	"github.com/go-sql-driver/mysql"
 )
 
 func (s TengoIntegrationSuite) TestIsDatabaseError(t *testing.T) {
@@ -69,3 +74,48 @@ func (s TengoIntegrationSuite) TestIsAccessError(t *testing.T) {
 		t.Errorf("Error of type %T %+v unexpectedly considered access error", err, err)
 	}
 }
+
+func TestIsDatabaseErrorWrapped(t *testing.T) {
+	merr := &mysql.MySQLError{Number: mysqlerr.ER_SPECIFIC_ACCESS_DENIED_ERROR}
+	err := fmt.Errorf("Error executing statement: %w", merr)
+	if !IsDatabaseError(err) || !IsDatabaseError(err, mysqlerr.ER_SPECIFIC_ACCESS_DENIED_ERROR) || !IsAccessError(err) {
+		t.Errorf("Expected wrapped error %v to be recognized as a database access error", err)
+	}
+	if IsDatabaseError(err, mysqlerr.ER_LOCK_DEADLOCK) {
+		t.Errorf("Expected wrapped error %v to not match a different error number", err)
+	}
+	if IsDatabaseError(fmt.Errorf("Error executing statement: %s", merr)) {
+		t.Error("Expected error formatted without wrapping to not be recognized as a database error")
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err   error
+		class ErrorClass
+	}{
+		{nil, ErrorClassFatal},
+		{errors.New("non-db error"), ErrorClassFatal},
+		{&mysql.MySQLError{Number: mysqlerr.ER_PARSE_ERROR}, ErrorClassFatal},
+		{&mysql.MySQLError{Number: mysqlerr.ER_LOCK_DEADLOCK}, ErrorClassDeadlock},
+		{fmt.Errorf("Error executing statement: %w", &mysql.MySQLError{Number: mysqlerr.ER_LOCK_DEADLOCK}), ErrorClassDeadlock},
+		{&mysql.MySQLError{Number: mysqlerr.ER_LOCK_WAIT_TIMEOUT}, ErrorClassLockWaitTimeout},
+		{&mysql.MySQLError{Number: mysqlerr.ER_CON_COUNT_ERROR}, ErrorClassTooManyConnections},
+		{&mysql.MySQLError{Number: mysqlerr.ER_TOO_MANY_USER_CONNECTIONS}, ErrorClassTooManyConnections},
+		{&mysql.MySQLError{Number: mysqlerr.ER_SERVER_SHUTDOWN}, ErrorClassConnectionLost},
+		{mysql.ErrInvalidConn, ErrorClassConnectionLost},
+		{driver.ErrBadConn, ErrorClassConnectionLost},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, ErrorClassConnectionLost},
+		{&mysql.MySQLError{Number: mysqlerr.ER_READ_ONLY_MODE}, ErrorClassReadOnly},
+		{&mysql.MySQLError{Number: mysqlerr.ER_OPTION_PREVENTS_STATEMENT, Message: "The MySQL server is running with the --read-only option so it cannot execute this statement"}, ErrorClassReadOnly},
+		{&mysql.MySQLError{Number: mysqlerr.ER_OPTION_PREVENTS_STATEMENT, Message: "The MySQL server is running with the --secure-file-priv option so it cannot execute this statement"}, ErrorClassFatal},
+	}
+	for n, c := range cases {
+		if actual := ClassifyError(c.err); actual != c.class {
+			t.Errorf("Case %d: expected ClassifyError(%v) to return %s, instead found %s", n, c.err, c.class, actual)
+		}
+		if expected := (c.class != ErrorClassFatal); IsRetryableError(c.err) != expected {
+			t.Errorf("Case %d: expected IsRetryableError(%v) to return %t", n, c.err, expected)
+		}
+	}
+}
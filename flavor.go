@@ -16,6 +16,8 @@ const (
 	VendorMySQL
 	VendorPercona
 	VendorMariaDB
+	VendorAurora
+	VendorVitess
 )
 
 func (v Vendor) String() string {
@@ -26,6 +28,10 @@ func (v Vendor) String() string {
 		return "percona"
 	case VendorMariaDB:
 		return "mariadb"
+	case VendorAurora:
+		return "aurora"
+	case VendorVitess:
+		return "vitess"
 	default:
 		return "unknown"
 	}
@@ -137,6 +143,24 @@ var FlavorMariaDB104 = Flavor{VendorMariaDB, 10, 4, 0}
 // number; avoid direct equality comparisons and ideally only use this in tests.
 var FlavorMariaDB105 = Flavor{VendorMariaDB, 10, 5, 0}
 
+// FlavorMariaDB106 represents MariaDB 10.6.x. This constant omits a patch
+// number; avoid direct equality comparisons and ideally only use this in tests.
+var FlavorMariaDB106 = Flavor{VendorMariaDB, 10, 6, 0}
+
+// FlavorMariaDB1011 represents MariaDB 10.11.x. This constant omits a patch
+// number; avoid direct equality comparisons and ideally only use this in tests.
+var FlavorMariaDB1011 = Flavor{VendorMariaDB, 10, 11, 0}
+
+// FlavorAurora57 represents Amazon Aurora MySQL 2.x, which is compatible with
+// MySQL 5.7. This constant omits a patch number; avoid direct equality
+// comparisons and ideally only use this in tests.
+var FlavorAurora57 = Flavor{VendorAurora, 5, 7, 0}
+
+// FlavorAurora80 represents Amazon Aurora MySQL 3.x, which is compatible with
+// MySQL 8.0. This constant omits a patch number; avoid direct equality
+// comparisons and ideally only use this in tests.
+var FlavorAurora80 = Flavor{VendorAurora, 8, 0, 0}
+
 // NewFlavor returns a Flavor value based on its inputs, which should be
 // supplied in one of these forms:
 // NewFlavor("vendor", major, minor)
@@ -161,15 +185,52 @@ func NewFlavor(base string, versionParts ...int) Flavor {
 	return Flavor{ParseVendor(base), versionParts[0], versionParts[1], versionParts[2]}
 }
 
+// auroraMySQLPatch maps Aurora MySQL 3.x minor versions to the MySQL 8.0 patch
+// release they are based on. Aurora MySQL 3 reports @@version in a form such as
+// "8.0.mysql_aurora.3.04.0", which lacks the MySQL patch number. Minor versions
+// missing from this map use the mapping of the closest earlier minor version.
+var auroraMySQLPatch = map[int]int{
+	1: 23,
+	2: 23,
+	3: 26,
+	4: 28,
+	5: 32,
+	6: 34,
+	7: 36,
+	8: 39,
+}
+
+var reAuroraVersion = regexp.MustCompile(`^(\d+)\.(\d+)\.mysql_aurora\.(\d+)\.(\d+)`)
+
 // ParseFlavor returns a Flavor value based on inputs obtained from server vars
 // @@global.version and @@global.version_comment. It accounts for how some
-// distributions and/or cloud platforms manipulate those values.
+// distributions and/or cloud platforms manipulate those values. Vitess vtgate
+// is detected from its version string, which has a suffix such as "-Vitess";
+// in this case the version is that of the MySQL servers backing it, as
+// configured in vtgate. Aurora MySQL is only detected if its version string or
+// comment mentions Aurora; otherwise, see Instance.Flavor, which also checks
+// @@aurora_version.
 func ParseFlavor(versionString, versionComment string) Flavor {
 	version := ParseVersion(versionString)
 	vendor := VendorUnknown
 	versionString = strings.ToLower(versionString)
 	versionComment = strings.ToLower(versionComment)
-	for _, attempt := range []Vendor{VendorMariaDB, VendorPercona, VendorMySQL} {
+	if matches := reAuroraVersion.FindStringSubmatch(versionString); matches != nil {
+		major, _ := strconv.Atoi(matches[1])
+		minor, _ := strconv.Atoi(matches[2])
+		auroraMajor, _ := strconv.Atoi(matches[3])
+		auroraMinor, _ := strconv.Atoi(matches[4])
+		var patch int
+		if auroraMajor == 2 {
+			patch = 12 // all Aurora MySQL 2.x releases are compatible with MySQL 5.7.12+
+		} else if auroraMajor == 3 {
+			for n := auroraMinor; n > 0 && patch == 0; n-- {
+				patch = auroraMySQLPatch[n]
+			}
+		}
+		return Flavor{VendorAurora, major, minor, patch}
+	}
+	for _, attempt := range []Vendor{VendorMariaDB, VendorPercona, VendorAurora, VendorVitess, VendorMySQL} {
 		if strings.Contains(versionComment, attempt.String()) || strings.Contains(versionString, attempt.String()) {
 			vendor = attempt
 			break
@@ -178,12 +239,12 @@ func ParseFlavor(versionString, versionComment string) Flavor {
 
 	// If the vendor is still unknown after the above checks, it may be because
 	// various distribution methods adjust one or both of those strings. Fall
-	// back to sane defaults for known major versions.
-	// This logic will need to change whenever MySQL 9+ or MariaDB 11+ exists.
+	// back to sane defaults for known major versions. MySQL skipped from 5 to 8,
+	// and MariaDB from 5 to 10, so their major versions only overlap at 5.
 	if vendor == VendorUnknown {
-		if version[0] == 10 {
+		if version[0] == 10 || version[0] == 11 {
 			vendor = VendorMariaDB
-		} else if version[0] == 5 || version[0] == 8 {
+		} else if version[0] == 5 || version[0] == 8 || version[0] == 9 {
 			vendor = VendorMySQL
 		}
 	}
@@ -245,9 +306,15 @@ func (fl Flavor) Supported() bool {
 	case VendorMySQL, VendorPercona:
 		// Currently support 5.5.0 through 8.0.x
 		return fl.MySQLishMinVersion(5, 5) && !fl.MySQLishMinVersion(8, 1)
+	case VendorAurora:
+		// Currently support Aurora MySQL 2.x through 3.x (MySQL 5.7 through 8.0)
+		return fl.MySQLishMinVersion(5, 7) && !fl.MySQLishMinVersion(8, 1)
+	case VendorVitess:
+		// Currently support Vitess backed by MySQL 5.7 through 8.0
+		return fl.MySQLishMinVersion(5, 7) && !fl.MySQLishMinVersion(8, 1)
 	case VendorMariaDB:
-		// Currently support 10.1.0 through 10.5.x
-		return fl.Major == 10 && fl.Minor >= 1 && fl.Minor <= 5
+		// Currently support 10.1.0 through 10.11.x
+		return fl.Major == 10 && fl.Minor >= 1 && fl.Minor <= 11
 	}
 	return false
 }
@@ -334,3 +401,57 @@ func (fl Flavor) HasCheckConstraints() bool {
 	}
 	return fl.Family() == FlavorMariaDB102 && fl.VendorMinVersion(VendorMariaDB, 10, 2, 22)
 }
+
+// ReplicaTerminology returns true if the flavor supports the newer REPLICA
+// forms of replication commands, such as SHOW REPLICA STATUS instead of SHOW
+// SLAVE STATUS.
+func (fl Flavor) ReplicaTerminology() bool {
+	return fl.MySQLishMinVersion(8, 0, 22) || fl.VendorMinVersion(VendorMariaDB, 10, 5, 1)
+}
+
+// InvisibleColumns returns true if the flavor supports invisible columns.
+func (fl Flavor) InvisibleColumns() bool {
+	return fl.MySQLishMinVersion(8, 0, 23) || fl.VendorMinVersion(VendorMariaDB, 10, 3)
+}
+
+// InvisibleIndexes returns true if the flavor supports invisible indexes. In
+// MariaDB 10.6+ these are called ignored indexes, and use different syntax;
+// see Index.Definition.
+func (fl Flavor) InvisibleIndexes() bool {
+	return fl.MySQLishMinVersion(8, 0) || fl.VendorMinVersion(VendorMariaDB, 10, 6)
+}
+
+// GeneratedInvisiblePrimaryKeys returns true if the flavor can automatically
+// add an invisible primary key to InnoDB tables which are created without one,
+// when sql_generate_invisible_primary_key is enabled. See
+// Table.HasGeneratedInvisiblePrimaryKey.
+func (fl Flavor) GeneratedInvisiblePrimaryKeys() bool {
+	return fl.MySQLishMinVersion(8, 0, 30)
+}
+
+// ShowUtf8mb3 returns true if the flavor displays the utf8 character set as
+// utf8mb3, along with its collations (e.g. utf8mb3_general_ci), in both SHOW
+// CREATE TABLE and information_schema.
+func (fl Flavor) ShowUtf8mb3() bool {
+	return fl.MySQLishMinVersion(8, 0, 30) || fl.VendorMinVersion(VendorMariaDB, 10, 6, 1)
+}
+
+// DescendingIndexes returns true if the flavor actually stores index parts in
+// descending order when requested. Other flavors parse but ignore the DESC
+// modifier in index definitions.
+func (fl Flavor) DescendingIndexes() bool {
+	return fl.MySQLishMinVersion(8, 0) || fl.VendorMinVersion(VendorMariaDB, 10, 8)
+}
+
+// FunctionalIndexes returns true if the flavor supports index parts consisting
+// of expressions rather than columns.
+func (fl Flavor) FunctionalIndexes() bool {
+	return fl.MySQLishMinVersion(8, 0, 13)
+}
+
+// ColumnCompression returns true if the flavor supports per-column
+// compression. Percona Server and MariaDB use different syntax for this
+// feature; see Column.Definition.
+func (fl Flavor) ColumnCompression() bool {
+	return fl.VendorMinVersion(VendorPercona, 5, 6, 33) || fl.VendorMinVersion(VendorMariaDB, 10, 3, 1)
+}
@@ -11,6 +11,8 @@ func TestParseVendor(t *testing.T) {
 		"Percona Server (GPL), Release 84.0, Revision 47234b3":   VendorPercona,
 		"Percona Server (GPL), Release '22', Revision 'f62d93c'": VendorPercona,
 		"mariadb.org binary distribution":                        VendorMariaDB,
+		"Aurora MySQL":                                           VendorMySQL,
+		"aurora":                                                 VendorAurora,
 		"Source distribution":                                    VendorUnknown,
 	}
 	for input, expected := range cases {
@@ -64,6 +66,16 @@ func TestParseFlavor(t *testing.T) {
 		{"8.0.13", "Homebrew", Flavor{VendorMySQL, 8, 0, 13}},                    // due to major version 8 --> MySQL
 		{"webscalesql", "webscalesql", FlavorUnknown},
 		{"6.0.3", "Source distribution", Flavor{VendorUnknown, 6, 0, 3}},
+		{"9.0.1", "Homebrew", Flavor{VendorMySQL, 9, 0, 1}},                      // due to major version 9 --> MySQL
+		{"11.4.2-0ubuntu0.24.04.1", "(Ubuntu)", Flavor{VendorMariaDB, 11, 4, 2}}, // due to major version 11 --> MariaDB
+		{"10.11.6-MariaDB-0+deb12u1-log", "Debian 12", Flavor{VendorMariaDB, 10, 11, 6}},
+		{"8.0.mysql_aurora.3.04.0", "Source distribution", Flavor{VendorAurora, 8, 0, 28}},
+		{"8.0.mysql_aurora.3.99.0", "Source distribution", Flavor{VendorAurora, 8, 0, 39}},
+		{"5.7.mysql_aurora.2.11.2", "Source distribution", Flavor{VendorAurora, 5, 7, 12}},
+		{"5.7.12", "Aurora MySQL distribution", Flavor{VendorAurora, 5, 7, 12}},
+		{"5.7.12-log", "MySQL Community Server (GPL)", Flavor{VendorMySQL, 5, 7, 12}}, // Aurora 2 requires checking @@aurora_version
+		{"8.0.30-Vitess", "", Flavor{VendorVitess, 8, 0, 30}},
+		{"5.7.9-vitess-12.0.0", "Version: 12.0.0 (Git revision 2e1e0a1)", Flavor{VendorVitess, 5, 7, 9}},
 	}
 	for _, tc := range cases {
 		fl := ParseFlavor(tc.versionString, tc.versionComment)
@@ -98,6 +110,14 @@ func TestNewFlavor(t *testing.T) {
 		{"mariadb", []int{10, 3}, FlavorMariaDB103, "mariadb:10.3", true, true},
 		{"10.3.8-MariaDB-log", []int{10, 3}, FlavorMariaDB103, "mariadb:10.3", true, true},
 		{"mariadb", []int{10}, Flavor{VendorMariaDB, 10, 0, 0}, "mariadb:10.0", false, true},
+		{"mariadb:10.6", []int{}, FlavorMariaDB106, "mariadb:10.6", true, true},
+		{"mariadb", []int{10, 11, 6}, Flavor{VendorMariaDB, 10, 11, 6}, "mariadb:10.11.6", true, true},
+		{"mariadb:11.4", []int{}, Flavor{VendorMariaDB, 11, 4, 0}, "mariadb:11.4", false, true},
+		{"aurora:5.7", []int{}, FlavorAurora57, "aurora:5.7", true, true},
+		{"aurora", []int{8, 0, 28}, Flavor{VendorAurora, 8, 0, 28}, "aurora:8.0.28", true, true},
+		{"aurora:5.6", []int{}, Flavor{VendorAurora, 5, 6, 0}, "aurora:5.6", false, true},
+		{"vitess:8.0.30", []int{}, Flavor{VendorVitess, 8, 0, 30}, "vitess:8.0.30", true, true},
+		{"vitess", []int{5, 6}, Flavor{VendorVitess, 5, 6, 0}, "vitess:5.6", false, true},
 		{"webscalesql", []int{}, FlavorUnknown, "unknown:0.0", false, false},
 		{"webscalesql", []int{5, 6}, Flavor{VendorUnknown, 5, 6, 0}, "unknown:5.6", false, false},
 	}
@@ -315,3 +335,98 @@ func TestOmitIntDisplayWidth(t *testing.T) {
 	}
 
 }
+
+func TestFlavorReplicaTerminology(t *testing.T) {
+	type testcase struct {
+		receiver Flavor
+		expected bool
+	}
+	cases := []testcase{
+		{FlavorMySQL57, false},
+		{FlavorMySQL80, false},
+		{Flavor{VendorMySQL, 8, 0, 21}, false},
+		{Flavor{VendorMySQL, 8, 0, 22}, true},
+		{Flavor{VendorPercona, 8, 0, 23}, true},
+		{FlavorMariaDB104, false},
+		{FlavorMariaDB105, false},
+		{Flavor{VendorMariaDB, 10, 5, 1}, true},
+		{FlavorUnknown, false},
+	}
+	for _, tc := range cases {
+		actual := tc.receiver.ReplicaTerminology()
+		if actual != tc.expected {
+			t.Errorf("Expected %s.ReplicaTerminology() to return %t, instead found %t", tc.receiver, tc.expected, actual)
+		}
+	}
+}
+
+func TestFlavorIndexAndColumnFeatures(t *testing.T) {
+	type testcase struct {
+		receiver                                                   Flavor
+		invisibleCols, invisibleIdx, descending, functional, compr bool
+	}
+	cases := []testcase{
+		{FlavorMySQL57, false, false, false, false, false},
+		{FlavorMySQL80, false, true, true, false, false},
+		{Flavor{VendorMySQL, 8, 0, 23}, true, true, true, true, false},
+		{FlavorPercona56, false, false, false, false, false},
+		{Flavor{VendorPercona, 5, 7, 26}, false, false, false, false, true},
+		{FlavorMariaDB102, false, false, false, false, false},
+		{FlavorMariaDB105, true, false, false, false, true},
+		{FlavorMariaDB106, true, true, false, false, true},
+		{Flavor{VendorMariaDB, 10, 8, 3}, true, true, true, false, true},
+	}
+	for _, tc := range cases {
+		if actual := tc.receiver.InvisibleColumns(); actual != tc.invisibleCols {
+			t.Errorf("Expected %s.InvisibleColumns() to return %t, instead found %t", tc.receiver, tc.invisibleCols, actual)
+		}
+		if actual := tc.receiver.InvisibleIndexes(); actual != tc.invisibleIdx {
+			t.Errorf("Expected %s.InvisibleIndexes() to return %t, instead found %t", tc.receiver, tc.invisibleIdx, actual)
+		}
+		if actual := tc.receiver.DescendingIndexes(); actual != tc.descending {
+			t.Errorf("Expected %s.DescendingIndexes() to return %t, instead found %t", tc.receiver, tc.descending, actual)
+		}
+		if actual := tc.receiver.FunctionalIndexes(); actual != tc.functional {
+			t.Errorf("Expected %s.FunctionalIndexes() to return %t, instead found %t", tc.receiver, tc.functional, actual)
+		}
+		if actual := tc.receiver.ColumnCompression(); actual != tc.compr {
+			t.Errorf("Expected %s.ColumnCompression() to return %t, instead found %t", tc.receiver, tc.compr, actual)
+		}
+	}
+}
+
+func TestFlavorNewerReleaseFeatures(t *testing.T) {
+	type testcase struct {
+		receiver      Flavor
+		gipk, utf8mb3 bool
+		hasDataDict   bool
+		omitIntWidths bool
+	}
+	cases := []testcase{
+		{FlavorMySQL80, false, false, true, false},
+		{Flavor{VendorMySQL, 8, 0, 30}, true, true, true, true},
+		{Flavor{VendorPercona, 8, 0, 32}, true, true, true, true},
+		{Flavor{VendorMySQL, 9, 1, 0}, true, true, true, true},
+		{FlavorAurora57, false, false, false, false},
+		{Flavor{VendorAurora, 8, 0, 28}, false, false, true, true},
+		{Flavor{VendorAurora, 8, 0, 32}, true, true, true, true},
+		{FlavorMariaDB105, false, false, false, false},
+		{FlavorMariaDB106, false, false, false, false},
+		{Flavor{VendorMariaDB, 10, 6, 1}, false, true, false, false},
+		{Flavor{VendorMariaDB, 11, 4, 2}, false, true, false, false},
+	}
+	for _, tc := range cases {
+		if actual := tc.receiver.GeneratedInvisiblePrimaryKeys(); actual != tc.gipk {
+			t.Errorf("Expected %s.GeneratedInvisiblePrimaryKeys() to return %t, instead found %t", tc.receiver, tc.gipk, actual)
+		}
+		if actual := tc.receiver.ShowUtf8mb3(); actual != tc.utf8mb3 {
+			t.Errorf("Expected %s.ShowUtf8mb3() to return %t, instead found %t", tc.receiver, tc.utf8mb3, actual)
+		}
+		if actual := tc.receiver.HasDataDictionary(); actual != tc.hasDataDict {
+			t.Errorf("Expected %s.HasDataDictionary() to return %t, instead found %t", tc.receiver, tc.hasDataDict, actual)
+		}
+		if actual := tc.receiver.OmitIntDisplayWidth(); actual != tc.omitIntWidths {
+			t.Errorf("Expected %s.OmitIntDisplayWidth() to return %t, instead found %t", tc.receiver, tc.omitIntWidths, actual)
+		}
+	}
+}
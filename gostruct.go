@@ -0,0 +1,304 @@
+package tengo
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GoStructOptions controls the output of Table.GoStruct and GoSource.
+type GoStructOptions struct {
+	StructName        string // Name of the generated struct; if empty, derived from the table name. Ignored by GoSource.
+	PrimaryKeyHelpers bool   // If true, also generate a function for looking up a row by primary key
+}
+
+// goInitialisms lists words which Go naming conventions keep fully upper-case
+// in identifiers.
+var goInitialisms = map[string]bool{
+	"acl": true, "api": true, "ascii": true, "cpu": true, "css": true,
+	"dns": true, "eof": true, "guid": true, "html": true, "http": true,
+	"https": true, "id": true, "ip": true, "json": true, "lhs": true,
+	"qps": true, "ram": true, "rhs": true, "rpc": true, "sla": true,
+	"smtp": true, "sql": true, "ssh": true, "tcp": true, "tls": true,
+	"ttl": true, "udp": true, "ui": true, "uid": true, "uuid": true,
+	"uri": true, "url": true, "utf8": true, "vm": true, "xml": true,
+}
+
+// GoIdentifier converts a table or column name to an exported Go identifier,
+// for example "customer_id" to "CustomerID". Characters which are not valid in
+// Go identifiers are treated as word separators.
+func GoIdentifier(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, word := range words {
+		if goInitialisms[strings.ToLower(word)] {
+			b.WriteString(strings.ToUpper(word))
+		} else {
+			runes := []rune(word)
+			b.WriteRune(unicode.ToUpper(runes[0]))
+			b.WriteString(string(runes[1:]))
+		}
+	}
+	ident := b.String()
+	if ident == "" {
+		return "X"
+	} else if first := []rune(ident)[0]; !unicode.IsLetter(first) {
+		return "X" + ident
+	}
+	return ident
+}
+
+// GoType returns the Go type which should be used for scanning values of the
+// column, along with the import path of the package containing that type (or
+// an empty string if no import is needed). NULL-able columns use sql.NullString
+// and similar types from database/sql. Binary and other types which map to
+// byte slices use a nil slice to represent NULL, while NULL-able json maps to
+// *json.RawMessage, with a nil pointer representing NULL. Temporal types other
+// than time map to time.Time, which requires the DSN parameter parseTime=true.
+func (c *Column) GoType() (goType, importPath string) {
+	typ := strings.ToLower(c.TypeInDB)
+	baseType := typ
+	if pos := strings.IndexAny(typ, "( "); pos > -1 {
+		baseType = typ[0:pos]
+	}
+	unsigned := strings.Contains(typ, " unsigned")
+
+	// nullType returns goType if the column is NOT NULL, or sqlType from the
+	// database/sql package otherwise
+	nullType := func(goType, sqlType string) (string, string) {
+		if c.Nullable {
+			return "sql." + sqlType, "database/sql"
+		}
+		return goType, ""
+	}
+
+	switch baseType {
+	case "tinyint":
+		if typ == "tinyint(1)" {
+			return nullType("bool", "NullBool")
+		} else if unsigned {
+			return nullType("uint8", "NullInt32")
+		}
+		return nullType("int8", "NullInt32")
+	case "smallint":
+		if unsigned {
+			return nullType("uint16", "NullInt32")
+		}
+		return nullType("int16", "NullInt32")
+	case "mediumint", "int", "integer":
+		if unsigned {
+			return nullType("uint32", "NullInt64")
+		}
+		return nullType("int32", "NullInt32")
+	case "bigint":
+		if !unsigned {
+			return nullType("int64", "NullInt64")
+		} else if c.Nullable {
+			// database/sql has no null type which can hold the full range
+			return "*uint64", ""
+		}
+		return "uint64", ""
+	case "year":
+		return nullType("int16", "NullInt32")
+	case "float":
+		return nullType("float32", "NullFloat64")
+	case "double", "real":
+		return nullType("float64", "NullFloat64")
+	case "date", "datetime", "timestamp":
+		if c.Nullable {
+			return "sql.NullTime", "database/sql"
+		}
+		return "time.Time", "time"
+	case "json":
+		if c.Nullable {
+			return "*json.RawMessage", "encoding/json"
+		}
+		return "json.RawMessage", "encoding/json"
+	case "decimal", "numeric", "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set", "time":
+		// decimal is kept as a string to avoid loss of precision; time is kept as a
+		// string since its range exceeds a single day
+		return nullType("string", "NullString")
+	default:
+		// binary, varbinary, blobs, bit, spatial types, and anything unrecognized
+		return "[]byte", ""
+	}
+}
+
+// GoStruct returns Go source code for a struct type representing a row of the
+// table, with db struct tags for use with sqlx, along with the sorted import
+// paths which the code requires. Column comments are included as field doc
+// comments. If opts.PrimaryKeyHelpers is true and the table has a primary key
+// which does not contain any expressions, a lookup function is also generated.
+// The returned code is gofmt'ed, but does not include a package clause or
+// imports; see GoSource to generate a complete file.
+func (t *Table) GoStruct(opts GoStructOptions) (code string, imports []string) {
+	structName := opts.StructName
+	if structName == "" {
+		structName = GoIdentifier(t.Name)
+	}
+	importSet := make(map[string]bool)
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "// %s represents a row of table %s.\n", structName, EscapeIdentifier(t.Name))
+	if t.Comment != "" {
+		b.WriteString("//\n")
+		writeGoComment(&b, t.Comment)
+	}
+	fmt.Fprintf(&b, "type %s struct {\n", structName)
+	fieldNames := make(map[string]bool, len(t.Columns))
+	fieldTypes := make(map[string]string, len(t.Columns))
+	for _, col := range t.Columns {
+		fieldName := GoIdentifier(col.Name)
+		for n := 2; fieldNames[fieldName]; n++ {
+			fieldName = fmt.Sprintf("%s%d", GoIdentifier(col.Name), n)
+		}
+		fieldNames[fieldName] = true
+		goType, importPath := col.GoType()
+		if importPath != "" {
+			importSet[importPath] = true
+		}
+		fieldTypes[col.Name] = goType
+		if col.Comment != "" {
+			writeGoComment(&b, col.Comment)
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", fieldName, goType, goStructTag(col.Name))
+	}
+	b.WriteString("}\n")
+
+	if opts.PrimaryKeyHelpers && t.PrimaryKey != nil {
+		functional := false
+		for _, part := range t.PrimaryKey.Parts {
+			functional = functional || part.Expression != ""
+		}
+		if !functional {
+			importSet["github.com/jmoiron/sqlx"] = true
+			t.writeGoPrimaryKeyHelper(&b, structName, fieldTypes)
+		}
+	}
+
+	// format.Source mangles doc comments when given a fragment lacking a package
+	// clause, so format a temporary complete file instead
+	const pkgClause = "package p\n\n"
+	code = b.String()
+	if formatted, err := format.Source([]byte(pkgClause + code)); err == nil {
+		code = strings.TrimPrefix(string(formatted), pkgClause)
+	}
+	for importPath := range importSet {
+		imports = append(imports, importPath)
+	}
+	sort.Strings(imports)
+	return code, imports
+}
+
+// writeGoPrimaryKeyHelper writes a function which looks up a row of the table
+// by its primary key. Columns are listed explicitly, rather than using
+// SELECT *, so that invisible columns are also returned.
+func (t *Table) writeGoPrimaryKeyHelper(b *bytes.Buffer, structName string, fieldTypes map[string]string) {
+	colNames := make([]string, len(t.Columns))
+	for n, col := range t.Columns {
+		colNames[n] = EscapeIdentifier(col.Name)
+	}
+	params := make([]string, len(t.PrimaryKey.Parts))
+	args := make([]string, len(t.PrimaryKey.Parts))
+	where := make([]string, len(t.PrimaryKey.Parts))
+	usedArgs := map[string]bool{"db": true, "row": true, "err": true}
+	for n, part := range t.PrimaryKey.Parts {
+		arg := goParamName(part.ColumnName)
+		if token.IsKeyword(arg) || usedArgs[arg] {
+			arg += "Value"
+		}
+		usedArgs[arg] = true
+		args[n] = arg
+		params[n] = fmt.Sprintf("%s %s", arg, fieldTypes[part.ColumnName])
+		where[n] = fmt.Sprintf("%s = ?", EscapeIdentifier(part.ColumnName))
+	}
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s", strings.Join(colNames, ", "), EscapeIdentifier(t.Name), strings.Join(where, " AND "))
+
+	fmt.Fprintf(b, "\n// Get%s returns the row of table %s with the supplied primary key.\n", structName, EscapeIdentifier(t.Name))
+	fmt.Fprintf(b, "func Get%s(db sqlx.Queryer, %s) (*%s, error) {\n", structName, strings.Join(params, ", "), structName)
+	fmt.Fprintf(b, "var row %s\n", structName)
+	fmt.Fprintf(b, "if err := sqlx.Get(db, &row, %#v, %s); err != nil {\n", query, strings.Join(args, ", "))
+	b.WriteString("return nil, err\n}\nreturn &row, nil\n}\n")
+}
+
+// GoSource returns the source code of a complete Go file in package pkgName,
+// containing structs for each of the supplied tables, along with any needed
+// imports. Struct names are derived from the table names, and opts.StructName
+// is ignored.
+func GoSource(pkgName string, tables []*Table, opts GoStructOptions) string {
+	opts.StructName = ""
+	importSet := make(map[string]bool)
+	codes := make([]string, len(tables))
+	for n, t := range tables {
+		var imports []string
+		codes[n], imports = t.GoStruct(opts)
+		for _, importPath := range imports {
+			importSet[importPath] = true
+		}
+	}
+	var b bytes.Buffer
+	b.WriteString("// Code generated by tengo. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkgName)
+	if len(importSet) > 0 {
+		// Standard library imports are grouped before third-party ones
+		b.WriteString("import (\n")
+		for _, importPath := range []string{"database/sql", "encoding/json", "time"} {
+			if importSet[importPath] {
+				fmt.Fprintf(&b, "%q\n", importPath)
+			}
+		}
+		if importSet["github.com/jmoiron/sqlx"] {
+			fmt.Fprintf(&b, "\n%q\n", "github.com/jmoiron/sqlx")
+		}
+		b.WriteString(")\n\n")
+	}
+	b.WriteString(strings.Join(codes, "\n"))
+	formatted, err := format.Source(b.Bytes())
+	if err != nil {
+		return b.String()
+	}
+	return string(formatted)
+}
+
+// writeGoComment writes comment as one or more lines of a Go comment.
+func writeGoComment(b *bytes.Buffer, comment string) {
+	for _, line := range strings.Split(comment, "\n") {
+		fmt.Fprintf(b, "// %s\n", strings.TrimRight(line, " \r\t"))
+	}
+}
+
+// goParamName converts a column name to an unexported Go identifier, for
+// example "URL_path" to "urlPath".
+func goParamName(name string) string {
+	runes := []rune(GoIdentifier(name))
+	var upper int
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// In a run of upper-case letters followed by lower-case ones, the last
+	// upper-case letter begins the next word
+	if upper > 1 && upper < len(runes) && unicode.IsLower(runes[upper]) {
+		upper--
+	}
+	for n := 0; n < upper; n++ {
+		runes[n] = unicode.ToLower(runes[n])
+	}
+	return string(runes)
+}
+
+// goStructTag returns a struct tag literal mapping a field to the named column.
+// A raw string literal is used unless the name contains a backtick.
+func goStructTag(name string) string {
+	tag := `db:"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
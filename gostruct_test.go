@@ -0,0 +1,205 @@
+package tengo
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoIdentifier(t *testing.T) {
+	cases := map[string]string{
+		"actor":          "Actor",
+		"actor_id":       "ActorID",
+		"first_name":     "FirstName",
+		"URL_path":       "URLPath",
+		"homepage_url":   "HomepageURL",
+		"camelCase":      "CamelCase",
+		"has space-dash": "HasSpaceDash",
+		"2fa_enabled":    "X2faEnabled",
+		"_":              "X",
+		"é_clair":        "ÉClair",
+	}
+	for input, expected := range cases {
+		if actual := GoIdentifier(input); actual != expected {
+			t.Errorf("Expected GoIdentifier(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+
+	paramCases := map[string]string{
+		"actor_id":     "actorID",
+		"id":           "id",
+		"URL_path":     "urlPath",
+		"homepage_url": "homepageURL",
+		"2fa":          "x2fa",
+	}
+	for input, expected := range paramCases {
+		if actual := goParamName(input); actual != expected {
+			t.Errorf("Expected goParamName(%q) to return %q, instead found %q", input, expected, actual)
+		}
+	}
+}
+
+func TestColumnGoType(t *testing.T) {
+	cases := []struct {
+		typeInDB   string
+		nullable   bool
+		goType     string
+		importPath string
+	}{
+		{"tinyint(1)", false, "bool", ""},
+		{"tinyint(1)", true, "sql.NullBool", "database/sql"},
+		{"tinyint(1) unsigned", false, "uint8", ""},
+		{"tinyint", false, "int8", ""},
+		{"smallint(5) unsigned", false, "uint16", ""},
+		{"smallint", true, "sql.NullInt32", "database/sql"},
+		{"mediumint(8) unsigned", true, "sql.NullInt64", "database/sql"},
+		{"int(10) unsigned", false, "uint32", ""},
+		{"int", false, "int32", ""},
+		{"int(11)", true, "sql.NullInt32", "database/sql"},
+		{"bigint(20) unsigned", false, "uint64", ""},
+		{"bigint unsigned", true, "*uint64", ""},
+		{"bigint", true, "sql.NullInt64", "database/sql"},
+		{"year(4)", false, "int16", ""},
+		{"float", false, "float32", ""},
+		{"double", true, "sql.NullFloat64", "database/sql"},
+		{"decimal(10,2)", false, "string", ""},
+		{"varchar(45)", false, "string", ""},
+		{"text", true, "sql.NullString", "database/sql"},
+		{"enum('a','b')", false, "string", ""},
+		{"time(3)", false, "string", ""},
+		{"timestamp(2)", false, "time.Time", "time"},
+		{"datetime", true, "sql.NullTime", "database/sql"},
+		{"date", false, "time.Time", "time"},
+		{"json", false, "json.RawMessage", "encoding/json"},
+		{"json", true, "*json.RawMessage", "encoding/json"},
+		{"varbinary(16)", true, "[]byte", ""},
+		{"blob", false, "[]byte", ""},
+		{"bit(1)", false, "[]byte", ""},
+		{"geometry", false, "[]byte", ""},
+	}
+	for _, c := range cases {
+		col := &Column{Name: "col", TypeInDB: c.typeInDB, Nullable: c.nullable}
+		goType, importPath := col.GoType()
+		if goType != c.goType || importPath != c.importPath {
+			t.Errorf("Unexpected result from GoType() for %s (nullable=%t): expected %s, %q; found %s, %q", c.typeInDB, c.nullable, c.goType, c.importPath, goType, importPath)
+		}
+	}
+}
+
+func TestTableGoStruct(t *testing.T) {
+	table := aTable(1)
+	table.Comment = "Actors appearing\nin films"
+	table.Columns[1].Comment = "Given name"
+	code, imports := table.GoStruct(GoStructOptions{})
+	expected := "// Actor represents a row of table `actor`.\n" +
+		"//\n" +
+		"// Actors appearing\n" +
+		"// in films\n" +
+		"type Actor struct {\n" +
+		"\tActorID uint16 `db:\"actor_id\"`\n" +
+		"\t// Given name\n" +
+		"\tFirstName  string         `db:\"first_name\"`\n" +
+		"\tLastName   sql.NullString `db:\"last_name\"`\n" +
+		"\tLastUpdate time.Time      `db:\"last_update\"`\n" +
+		"\tSsn        string         `db:\"ssn\"`\n" +
+		"\tAlive      uint8          `db:\"alive\"`\n" +
+		"\tAliveBit   []byte         `db:\"alive_bit\"`\n" +
+		"}\n"
+	if code != expected {
+		t.Errorf("Unexpected result from GoStruct(); expected:\n%s\nfound:\n%s", expected, code)
+	}
+	if expectedImports := []string{"database/sql", "time"}; !reflect.DeepEqual(imports, expectedImports) {
+		t.Errorf("Expected imports %v, instead found %v", expectedImports, imports)
+	}
+
+	// Confirm StructName is used, and primary key helper generated
+	code, imports = table.GoStruct(GoStructOptions{StructName: "ActorRow", PrimaryKeyHelpers: true})
+	if !strings.Contains(code, "type ActorRow struct {") {
+		t.Errorf("Expected struct to be named ActorRow, but found:\n%s", code)
+	}
+	if !strings.Contains(code, "func GetActorRow(db sqlx.Queryer, actorID uint16) (*ActorRow, error) {") {
+		t.Errorf("Primary key helper missing or incorrect:\n%s", code)
+	}
+	if !strings.Contains(code, "FROM `actor` WHERE `actor_id` = ?\", actorID)") {
+		t.Errorf("Primary key helper query incorrect:\n%s", code)
+	}
+	if expectedImports := []string{"database/sql", "github.com/jmoiron/sqlx", "time"}; !reflect.DeepEqual(imports, expectedImports) {
+		t.Errorf("Expected imports %v, instead found %v", expectedImports, imports)
+	}
+
+	// Composite primary key with column names needing adjustment as params, as
+	// well as duplicate field names and a backtick in a column name
+	table = Table{
+		Name: "order_items",
+		Columns: []*Column{
+			{Name: "type", TypeInDB: "varchar(10)"},
+			{Name: "row", TypeInDB: "int(10) unsigned"},
+			{Name: "Row", TypeInDB: "json", Nullable: true},
+			{Name: "odd`name", TypeInDB: "int"},
+		},
+	}
+	table.PrimaryKey = &Index{
+		Name:       "PRIMARY",
+		PrimaryKey: true,
+		Unique:     true,
+		Parts:      []IndexPart{{ColumnName: "type"}, {ColumnName: "row"}},
+	}
+	code, _ = table.GoStruct(GoStructOptions{PrimaryKeyHelpers: true})
+	for _, expectLine := range []string{
+		"Row2 *json.RawMessage `db:\"Row\"`",
+		"OddName int32 \"db:\\\"odd`name\\\"\"",
+		"func GetOrderItems(db sqlx.Queryer, typeValue string, rowValue uint32) (*OrderItems, error) {",
+		"WHERE `type` = ? AND `row` = ?\", typeValue, rowValue)",
+	} {
+		if !strings.Contains(strings.Join(strings.Fields(code), " "), expectLine) {
+			t.Errorf("Expected generated code to contain %s, but it did not:\n%s", expectLine, code)
+		}
+	}
+
+	// No helper for tables lacking a primary key
+	table.PrimaryKey = nil
+	if code, imports = table.GoStruct(GoStructOptions{PrimaryKeyHelpers: true}); strings.Contains(code, "func ") {
+		t.Errorf("Expected no primary key helper, but found:\n%s", code)
+	} else if expectedImports := []string{"encoding/json"}; !reflect.DeepEqual(imports, expectedImports) {
+		t.Errorf("Expected imports %v, instead found %v", expectedImports, imports)
+	}
+}
+
+func TestGoSource(t *testing.T) {
+	actor := aTable(1)
+	warranties := foreignKeyTable()
+	src := GoSource("models", []*Table{&actor, &warranties}, GoStructOptions{StructName: "Ignored", PrimaryKeyHelpers: true})
+	file, err := parser.ParseFile(token.NewFileSet(), "models.go", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Unexpected error parsing generated source: %v\n%s", err, src)
+	}
+	if file.Name.Name != "models" {
+		t.Errorf("Expected package models, instead found %s", file.Name.Name)
+	}
+	var imports []string
+	for _, spec := range file.Imports {
+		imports = append(imports, spec.Path.Value)
+	}
+	if expected := []string{`"database/sql"`, `"time"`, `"github.com/jmoiron/sqlx"`}; !reflect.DeepEqual(imports, expected) {
+		t.Errorf("Expected imports %v, instead found %v", expected, imports)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "models.go", src, 0); err != nil {
+		t.Errorf("Unexpected error parsing generated source: %v\n%s", err, src)
+	}
+	for _, expected := range []string{"type Actor struct {", "type Warranties struct {", "func GetActor(", "func GetWarranties("} {
+		if !strings.Contains(src, expected) {
+			t.Errorf("Expected generated source to contain %q, but it did not:\n%s", expected, src)
+		}
+	}
+
+	// No imports needed for a table using only basic types
+	table := Table{Name: "t", Columns: []*Column{{Name: "x", TypeInDB: "int"}}}
+	src = GoSource("models", []*Table{&table}, GoStructOptions{})
+	if strings.Contains(src, "import") {
+		t.Errorf("Expected no imports, but found:\n%s", src)
+	} else if _, err := parser.ParseFile(token.NewFileSet(), "models.go", src, 0); err != nil {
+		t.Errorf("Unexpected error parsing generated source: %v\n%s", err, src)
+	}
+}
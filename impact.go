@@ -0,0 +1,328 @@
+package tengo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DDLAlgorithm represents the predicted means by which the server executes an
+// ALTER TABLE, which determines how expensive it is on a large table.
+type DDLAlgorithm int
+
+// Constants enumerating predicted DDL algorithms, in increasing order of cost
+const (
+	AlgorithmInstant DDLAlgorithm = iota // Metadata-only change
+	AlgorithmInplace                     // In-place change without rebuilding the table, e.g. building a secondary index
+	AlgorithmRebuild                     // In-place change which rewrites the table in its entirety
+	AlgorithmCopy                        // Table is copied row-by-row, blocking writes throughout
+)
+
+func (algo DDLAlgorithm) String() string {
+	switch algo {
+	case AlgorithmInstant:
+		return "INSTANT"
+	case AlgorithmInplace:
+		return "INPLACE"
+	case AlgorithmRebuild:
+		return "INPLACE (rebuild)"
+	default:
+		return "COPY"
+	}
+}
+
+// PredictAlgorithm returns the algorithm that the supplied flavor is expected
+// to use for the ALTER TABLE represented by td, based on the most expensive
+// clause of the statement. This is only a prediction: the actual algorithm may
+// also depend on server settings, and on table properties which are not
+// introspected. Predictions err on the side of the more expensive algorithm.
+// CREATE TABLE and DROP TABLE are always considered AlgorithmInstant, since
+// they do not rewrite any data.
+func (td *TableDiff) PredictAlgorithm(flavor Flavor) DDLAlgorithm {
+	if td.Type != DiffTypeAlter {
+		return AlgorithmInstant
+	} else if !td.supported {
+		return AlgorithmCopy
+	}
+	mods := StatementModifiers{Flavor: flavor}
+	result := AlgorithmInstant
+	var dropPK, addPK bool
+	for _, clause := range td.alterClauses {
+		if clause.Clause(mods) == "" {
+			continue
+		}
+		if algo := predictClauseAlgorithm(clause, td.From, flavor); algo > result {
+			result = algo
+		}
+		switch clause := clause.(type) {
+		case DropIndex:
+			dropPK = dropPK || clause.Index.PrimaryKey
+		case AddIndex:
+			addPK = addPK || clause.Index.PrimaryKey
+		}
+	}
+	// Dropping a primary key without adding a new one requires a copy
+	if dropPK && !addPK {
+		result = AlgorithmCopy
+	}
+	return result
+}
+
+// predictClauseAlgorithm returns the expected algorithm for a single clause of
+// an ALTER TABLE on table t. Online DDL is only predicted for InnoDB tables;
+// any change to a table using another storage engine is considered a copy.
+func predictClauseAlgorithm(clause TableAlterClause, t *Table, flavor Flavor) DDLAlgorithm {
+	if t.Engine != "InnoDB" {
+		return AlgorithmCopy
+	}
+	switch clause := clause.(type) {
+	case AddColumn:
+		if clause.Column.Virtual {
+			return AlgorithmInstant
+		} else if tableHasFullText(t) {
+			return AlgorithmRebuild
+		}
+		positioned := clause.PositionFirst || clause.PositionAfter != nil
+		if flavor.MySQLishMinVersion(8, 0, 29) || flavor.VendorMinVersion(VendorMariaDB, 10, 4) {
+			return AlgorithmInstant
+		} else if !positioned && (flavor.MySQLishMinVersion(8, 0, 12) || flavor.VendorMinVersion(VendorMariaDB, 10, 3)) {
+			return AlgorithmInstant
+		}
+		return AlgorithmRebuild
+	case DropColumn:
+		if clause.Column.Virtual {
+			return AlgorithmInstant
+		} else if !tableHasFullText(t) && (flavor.MySQLishMinVersion(8, 0, 29) || flavor.VendorMinVersion(VendorMariaDB, 10, 4)) {
+			return AlgorithmInstant
+		}
+		return AlgorithmRebuild
+	case AddIndex:
+		if clause.Index.PrimaryKey {
+			return AlgorithmRebuild
+		} else if clause.Index.Type == "FULLTEXT" && !tableHasFullText(t) && t.ColumnsByName()["FTS_DOC_ID"] == nil {
+			// The first FULLTEXT index adds a hidden FTS_DOC_ID column
+			return AlgorithmRebuild
+		}
+		return AlgorithmInplace
+	case DropIndex:
+		if clause.Index.PrimaryKey {
+			return AlgorithmRebuild
+		}
+		return AlgorithmInstant
+	case AddForeignKey:
+		// Adding a foreign key is only in-place with foreign_key_checks disabled,
+		// which cannot be assumed here
+		return AlgorithmCopy
+	case DropForeignKey, ModifyPartitions:
+		return AlgorithmInplace
+	case AddCheck:
+		return AlgorithmCopy
+	case AlterCheck:
+		if clause.NewEnforcement {
+			return AlgorithmCopy
+		}
+		return AlgorithmInstant
+	case ModifyColumn:
+		return predictModifyColumnAlgorithm(clause)
+	case ChangeCreateOptions:
+		for _, opt := range []string{"ROW_FORMAT=", "KEY_BLOCK_SIZE=", "COMPRESSION=", "PAGE_COMPRESSED="} {
+			if strings.Contains(clause.OldCreateOptions, opt) || strings.Contains(clause.NewCreateOptions, opt) {
+				return AlgorithmRebuild
+			}
+		}
+		return AlgorithmInstant
+	case ChangeStorageEngine, ConvertCharSet, PartitionBy, RemovePartitioning:
+		return AlgorithmCopy
+	case DropCheck, AlterIndex, RenameColumn, ChangeAutoIncrement, ChangeCharSet, ChangeComment:
+		return AlgorithmInstant
+	}
+	return AlgorithmCopy
+}
+
+// predictModifyColumnAlgorithm returns the expected algorithm for a MODIFY
+// COLUMN clause.
+func predictModifyColumnAlgorithm(mc ModifyColumn) DDLAlgorithm {
+	oldCol, newCol := mc.OldColumn, mc.NewColumn
+	if oldCol.Virtual && newCol.Virtual && oldCol.TypeInDB == newCol.TypeInDB {
+		return AlgorithmInplace
+	}
+	if oldCol.Nullable != newCol.Nullable || oldCol.GenerationExpr != newCol.GenerationExpr || oldCol.Virtual != newCol.Virtual || mc.PositionFirst || mc.PositionAfter != nil {
+		return AlgorithmRebuild
+	}
+	if oldCol.CharSet != newCol.CharSet || oldCol.Collation != newCol.Collation || oldCol.Compression != newCol.Compression {
+		return AlgorithmCopy
+	}
+	oldType, newType := strings.ToLower(oldCol.TypeInDB), strings.ToLower(newCol.TypeInDB)
+	if oldType == newType || ((reDisplayWidth.MatchString(oldType) || reDisplayWidth.MatchString(newType)) && StripDisplayWidth(oldType) == StripDisplayWidth(newType)) {
+		// Only default, comment, or other metadata changing
+		return AlgorithmInstant
+	}
+
+	// Appending values to the end of an enum or set is metadata-only, as long as
+	// the storage size does not change; we don't attempt to check the latter
+	if (strings.HasPrefix(oldType, "enum(") || strings.HasPrefix(oldType, "set(")) && strings.HasPrefix(newType, oldType[0:len(oldType)-1]) {
+		return AlgorithmInstant
+	}
+
+	// Increasing the length of a varchar is in-place, as long as the number of
+	// length bytes does not change
+	if oldLen, ok := varcharLength(oldType); ok {
+		if newLen, ok := varcharLength(newType); ok && newLen >= oldLen {
+			maxBytes := charSetMaxBytes(oldCol.CharSet)
+			if (oldLen*maxBytes < 256) == (newLen*maxBytes < 256) {
+				return AlgorithmInplace
+			}
+		}
+	}
+	return AlgorithmCopy
+}
+
+var reVarcharLength = regexp.MustCompile(`^varchar\((\d+)\)`)
+
+// varcharLength returns the length of a varchar column type, and true; or 0 and
+// false if typ is not a varchar.
+func varcharLength(typ string) (int, bool) {
+	matches := reVarcharLength.FindStringSubmatch(typ)
+	if matches == nil {
+		return 0, false
+	}
+	length, err := strconv.Atoi(matches[1])
+	return length, err == nil
+}
+
+// tableHasFullText returns true if t has any FULLTEXT indexes.
+func tableHasFullText(t *Table) bool {
+	for _, idx := range t.SecondaryIndexes {
+		if idx.Type == "FULLTEXT" {
+			return true
+		}
+	}
+	return false
+}
+
+// ImpactOptions controls how EstimateImpact converts predicted work into an
+// estimated duration. Zero-value rates are replaced with defaults, which are
+// deliberately conservative; callers should calibrate them for their hardware.
+type ImpactOptions struct {
+	Modifiers          StatementModifiers // Used to generate each statement; Flavor defaults to the instance's flavor
+	RebuildBytesPerSec int64              // Throughput for rebuilding or copying a table; 32 MiB/s if 0
+	IndexBytesPerSec   int64              // Throughput for building a secondary index; 64 MiB/s if 0
+}
+
+// StatementImpact is the estimated impact of a single table's DDL statement.
+type StatementImpact struct {
+	Diff              *TableDiff
+	Statement         string
+	Err               error // non-nil if the statement is forbidden or unsupported with the supplied modifiers
+	Algorithm         DDLAlgorithm
+	TableBytes        int64 // current data and index size of the table, or 0 if not yet created
+	RewriteBytes      int64 // estimated bytes written by the statement
+	EstimatedDuration time.Duration
+}
+
+func (si *StatementImpact) String() string {
+	return fmt.Sprintf("%s: %s, rewriting ~%d bytes in ~%s", si.Diff.ObjectKey(), si.Algorithm, si.RewriteBytes, si.EstimatedDuration)
+}
+
+// ImpactEstimate is the estimated impact of all table statements in a
+// SchemaDiff.
+type ImpactEstimate struct {
+	Statements        []*StatementImpact
+	RewriteBytes      int64 // total across all statements
+	EstimatedDuration time.Duration
+}
+
+// Exceeding returns the statements whose estimated rewrite bytes exceed
+// maxBytes, or whose estimated duration exceeds maxDuration. A threshold of 0
+// is ignored. This is useful for requiring human approval of expensive
+// statements.
+func (ie *ImpactEstimate) Exceeding(maxBytes int64, maxDuration time.Duration) (result []*StatementImpact) {
+	for _, si := range ie.Statements {
+		if (maxBytes > 0 && si.RewriteBytes > maxBytes) || (maxDuration > 0 && si.EstimatedDuration > maxDuration) {
+			result = append(result, si)
+		}
+	}
+	return result
+}
+
+// EstimateImpact combines the table diffs of diff with live table statistics
+// from the instance, to estimate the bytes rewritten by each statement and a
+// rough duration. The diff's FromSchema should have been introspected from
+// this instance. Statements which COPY or rebuild the table are assumed to
+// rewrite all of its data and indexes; in-place secondary index builds are
+// assumed to write an amount proportional to the size of the table's existing
+// indexes; instant changes are free. Table diffs which generate no DDL with
+// the supplied modifiers are omitted from the result.
+func (instance *Instance) EstimateImpact(diff *SchemaDiff, opts ImpactOptions) (*ImpactEstimate, error) {
+	if opts.Modifiers.Flavor == FlavorUnknown {
+		opts.Modifiers.Flavor = instance.Flavor()
+	}
+	if opts.RebuildBytesPerSec <= 0 {
+		opts.RebuildBytesPerSec = 32 * 1024 * 1024
+	}
+	if opts.IndexBytesPerSec <= 0 {
+		opts.IndexBytesPerSec = 64 * 1024 * 1024
+	}
+
+	statsByName := make(map[string]*TableStats)
+	if diff.FromSchema != nil && len(diff.FromSchema.Tables) > 0 {
+		stats, err := instance.TableStatistics(diff.FromSchema.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ts := range stats {
+			statsByName[ts.Name] = ts
+		}
+	}
+
+	result := &ImpactEstimate{Statements: []*StatementImpact{}}
+	for _, td := range diff.TableDiffs {
+		stmt, err := td.Statement(opts.Modifiers)
+		if stmt == "" && err == nil {
+			continue
+		}
+		si := &StatementImpact{
+			Diff:      td,
+			Statement: stmt,
+			Err:       err,
+			Algorithm: td.PredictAlgorithm(opts.Modifiers.Flavor),
+		}
+		if ts := statsByName[td.ObjectKey().Name]; ts != nil && td.Type == DiffTypeAlter {
+			si.TableBytes = ts.DataBytes + ts.IndexBytes
+			si.RewriteBytes, si.EstimatedDuration = estimateRewrite(td, si.Algorithm, ts, opts)
+		}
+		result.Statements = append(result.Statements, si)
+		result.RewriteBytes += si.RewriteBytes
+		result.EstimatedDuration += si.EstimatedDuration
+	}
+	return result, nil
+}
+
+// estimateRewrite returns the estimated bytes written, and duration, for
+// altering a table with the supplied stats using the predicted algorithm.
+func estimateRewrite(td *TableDiff, algo DDLAlgorithm, ts *TableStats, opts ImpactOptions) (int64, time.Duration) {
+	var bytes, perSec int64
+	switch algo {
+	case AlgorithmRebuild, AlgorithmCopy:
+		bytes, perSec = ts.DataBytes+ts.IndexBytes, opts.RebuildBytesPerSec
+	case AlgorithmInplace:
+		// Estimate each new secondary index as the average size of the table's
+		// existing ones, or if there are none, as a fraction of the data size
+		// proportional to the index's share of the table's columns
+		for _, clause := range td.alterClauses {
+			if ai, ok := clause.(AddIndex); ok && ai.Clause(opts.Modifiers) != "" {
+				if n := len(td.From.SecondaryIndexes); n > 0 && ts.IndexBytes > 0 {
+					bytes += ts.IndexBytes / int64(n)
+				} else if cols := len(td.From.Columns); cols > 0 {
+					bytes += ts.DataBytes * int64(len(ai.Index.Parts)) / int64(cols)
+				}
+			}
+		}
+		perSec = opts.IndexBytesPerSec
+	default:
+		return 0, 0
+	}
+	return bytes, time.Duration(float64(bytes) / float64(perSec) * float64(time.Second))
+}
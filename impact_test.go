@@ -0,0 +1,156 @@
+package tengo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTableDiffPredictAlgorithm(t *testing.T) {
+	from := aTable(1)
+	newCol := &Column{Name: "nickname", TypeInDB: "varchar(20)", CharSet: "utf8", Collation: "utf8_general_ci", Nullable: true, Default: "NULL"}
+	alterWith := func(clauses ...TableAlterClause) *TableDiff {
+		return &TableDiff{Type: DiffTypeAlter, From: &from, To: &from, alterClauses: clauses, supported: true}
+	}
+	modify := func(colIndex int, newType string) ModifyColumn {
+		col := *from.Columns[colIndex]
+		col.TypeInDB = newType
+		return ModifyColumn{Table: &from, OldColumn: from.Columns[colIndex], NewColumn: &col}
+	}
+	mysql8029 := NewFlavor("mysql:8.0.29")
+	fk := foreignKeyTable().ForeignKeys[0]
+	cases := []struct {
+		td       *TableDiff
+		flavor   Flavor
+		expected DDLAlgorithm
+	}{
+		{NewCreateTable(&from), FlavorMySQL57, AlgorithmInstant},
+		{NewDropTable(&from), FlavorMySQL57, AlgorithmInstant},
+		{alterWith(AddColumn{Table: &from, Column: newCol}), FlavorMySQL57, AlgorithmRebuild},
+		{alterWith(AddColumn{Table: &from, Column: newCol}), FlavorMySQL80, AlgorithmRebuild},
+		{alterWith(AddColumn{Table: &from, Column: newCol}), NewFlavor("mysql:8.0.12"), AlgorithmInstant},
+		{alterWith(AddColumn{Table: &from, Column: newCol, PositionFirst: true}), NewFlavor("mysql:8.0.12"), AlgorithmRebuild},
+		{alterWith(AddColumn{Table: &from, Column: newCol, PositionFirst: true}), mysql8029, AlgorithmInstant},
+		{alterWith(AddColumn{Table: &from, Column: newCol, PositionFirst: true}), FlavorMariaDB104, AlgorithmInstant},
+		{alterWith(DropColumn{Column: from.Columns[2]}), FlavorMySQL57, AlgorithmRebuild},
+		{alterWith(DropColumn{Column: from.Columns[2]}), mysql8029, AlgorithmInstant},
+		{alterWith(AddIndex{Index: from.SecondaryIndexes[0]}), FlavorMySQL57, AlgorithmInplace},
+		{alterWith(DropIndex{Index: from.SecondaryIndexes[0]}), FlavorMySQL57, AlgorithmInstant},
+		{alterWith(DropIndex{Index: from.PrimaryKey}), FlavorMySQL57, AlgorithmCopy},
+		{alterWith(DropIndex{Index: from.PrimaryKey}, AddIndex{Index: from.PrimaryKey}), FlavorMySQL57, AlgorithmRebuild},
+		{alterWith(ChangeComment{NewComment: "hello"}, ChangeAutoIncrement{OldNextAutoIncrement: 1, NewNextAutoIncrement: 5}), FlavorMySQL57, AlgorithmInstant},
+		{alterWith(ChangeStorageEngine{NewStorageEngine: "MyISAM"}), FlavorMySQL80, AlgorithmCopy},
+		{alterWith(AddForeignKey{ForeignKey: fk}), FlavorMySQL80, AlgorithmCopy},
+		{alterWith(DropForeignKey{ForeignKey: fk}), FlavorMySQL80, AlgorithmInplace},
+		{alterWith(ChangeCreateOptions{NewCreateOptions: "ROW_FORMAT=COMPRESSED"}), FlavorMySQL80, AlgorithmRebuild},
+		{alterWith(ChangeCreateOptions{NewCreateOptions: "STATS_PERSISTENT=1"}), FlavorMySQL80, AlgorithmInstant},
+		{alterWith(modify(1, "varchar(80)")), FlavorMySQL57, AlgorithmInplace},
+		{alterWith(modify(1, "varchar(100)")), FlavorMySQL57, AlgorithmCopy},
+		{alterWith(modify(1, "varchar(40)")), FlavorMySQL57, AlgorithmCopy},
+		{alterWith(modify(0, "int(10) unsigned")), FlavorMySQL57, AlgorithmCopy},
+		{alterWith(modify(1, "varchar(45)"), AddIndex{Index: from.SecondaryIndexes[0]}), FlavorMySQL57, AlgorithmInplace},
+		{&TableDiff{Type: DiffTypeAlter, From: &from, To: &from}, FlavorMySQL80, AlgorithmCopy},
+	}
+	for n, c := range cases {
+		if actual := c.td.PredictAlgorithm(c.flavor); actual != c.expected {
+			t.Errorf("cases[%d]: Expected PredictAlgorithm to return %s, instead found %s", n, c.expected, actual)
+		}
+	}
+
+	// The first FULLTEXT index requires a rebuild, but subsequent ones do not
+	ftIndex := &Index{Name: "ft_name", Parts: []IndexPart{{ColumnName: "first_name"}}, Type: "FULLTEXT"}
+	td := alterWith(AddIndex{Index: ftIndex})
+	if actual := td.PredictAlgorithm(FlavorMySQL80); actual != AlgorithmRebuild {
+		t.Errorf("Expected PredictAlgorithm to return %s, instead found %s", AlgorithmRebuild, actual)
+	}
+	ftFrom := aTable(1)
+	ftFrom.SecondaryIndexes = append(ftFrom.SecondaryIndexes, &Index{Name: "ft_last", Parts: []IndexPart{{ColumnName: "last_name"}}, Type: "FULLTEXT"})
+	td = &TableDiff{Type: DiffTypeAlter, From: &ftFrom, To: &ftFrom, alterClauses: []TableAlterClause{AddIndex{Index: ftIndex}}, supported: true}
+	if actual := td.PredictAlgorithm(FlavorMySQL80); actual != AlgorithmInplace {
+		t.Errorf("Expected PredictAlgorithm to return %s, instead found %s", AlgorithmInplace, actual)
+	}
+
+	// Any change to a non-InnoDB table requires a copy
+	myisam := aTable(1)
+	myisam.Engine = "MyISAM"
+	td = &TableDiff{Type: DiffTypeAlter, From: &myisam, To: &myisam, alterClauses: []TableAlterClause{DropIndex{Index: myisam.SecondaryIndexes[0]}}, supported: true}
+	if actual := td.PredictAlgorithm(FlavorMySQL80); actual != AlgorithmCopy {
+		t.Errorf("Expected PredictAlgorithm to return %s, instead found %s", AlgorithmCopy, actual)
+	}
+
+	// Changing nullability requires a rebuild
+	col := *from.Columns[2]
+	col.Nullable = false
+	td = alterWith(ModifyColumn{Table: &from, OldColumn: from.Columns[2], NewColumn: &col})
+	if actual := td.PredictAlgorithm(FlavorMySQL80); actual != AlgorithmRebuild {
+		t.Errorf("Expected PredictAlgorithm to return %s, instead found %s", AlgorithmRebuild, actual)
+	}
+}
+
+func TestEstimateRewrite(t *testing.T) {
+	from := aTable(1)
+	ts := &TableStats{DataBytes: 1000 * 1024 * 1024, IndexBytes: 200 * 1024 * 1024}
+	opts := ImpactOptions{RebuildBytesPerSec: 100 * 1024 * 1024, IndexBytesPerSec: 100 * 1024 * 1024}
+	td := &TableDiff{Type: DiffTypeAlter, From: &from, To: &from, supported: true}
+	if bytes, dur := estimateRewrite(td, AlgorithmCopy, ts, opts); bytes != 1200*1024*1024 || dur != 12*time.Second {
+		t.Errorf("Unexpected estimate for copy: %d bytes, %s", bytes, dur)
+	}
+	if bytes, dur := estimateRewrite(td, AlgorithmInstant, ts, opts); bytes != 0 || dur != 0 {
+		t.Errorf("Unexpected estimate for instant: %d bytes, %s", bytes, dur)
+	}
+
+	// aTable has 2 secondary indexes, so a new index is estimated at half of the
+	// existing index size
+	td.alterClauses = []TableAlterClause{AddIndex{Index: from.SecondaryIndexes[0]}}
+	if bytes, dur := estimateRewrite(td, AlgorithmInplace, ts, opts); bytes != 100*1024*1024 || dur != time.Second {
+		t.Errorf("Unexpected estimate for inplace index build: %d bytes, %s", bytes, dur)
+	}
+
+	ie := &ImpactEstimate{Statements: []*StatementImpact{
+		{RewriteBytes: 100, EstimatedDuration: time.Minute},
+		{RewriteBytes: 5000, EstimatedDuration: time.Second},
+		{RewriteBytes: 0},
+	}}
+	if exceeding := ie.Exceeding(1000, 0); len(exceeding) != 1 || exceeding[0] != ie.Statements[1] {
+		t.Errorf("Unexpected result from Exceeding: %+v", exceeding)
+	}
+	if exceeding := ie.Exceeding(1000, 30*time.Second); len(exceeding) != 2 {
+		t.Errorf("Unexpected result from Exceeding: %+v", exceeding)
+	}
+	if exceeding := ie.Exceeding(0, 0); len(exceeding) != 0 {
+		t.Errorf("Unexpected result from Exceeding: %+v", exceeding)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceEstimateImpact(t *testing.T) {
+	from := s.GetSchema(t, "testing")
+	to := s.GetSchema(t, "testing")
+	hasRows := to.Table("has_rows")
+	hasRows.Columns[0].TypeInDB = "smallint unsigned"
+	hasRows.CreateStatement = hasRows.GeneratedCreateStatement(s.d.Flavor())
+	diff := from.Diff(to)
+
+	estimate, err := s.d.EstimateImpact(diff, ImpactOptions{Modifiers: StatementModifiers{AllowUnsafe: true}})
+	if err != nil {
+		t.Fatalf("Unexpected error from EstimateImpact: %v", err)
+	} else if len(estimate.Statements) != 1 {
+		t.Fatalf("Expected 1 statement, instead found %d", len(estimate.Statements))
+	}
+	si := estimate.Statements[0]
+	if si.Algorithm != AlgorithmCopy || si.TableBytes <= 0 || si.RewriteBytes != si.TableBytes || si.Statement == "" || si.Err != nil {
+		t.Errorf("Unexpected impact: %+v", *si)
+	}
+	if estimate.RewriteBytes != si.RewriteBytes || estimate.EstimatedDuration != si.EstimatedDuration {
+		t.Errorf("Unexpected totals in estimate: %+v", *estimate)
+	}
+	if exceeding := estimate.Exceeding(1, 0); len(exceeding) != 1 {
+		t.Errorf("Expected statement to exceed threshold, instead found %+v", exceeding)
+	}
+
+	// Without AllowUnsafe, the statement is forbidden, but still estimated
+	estimate, err = s.d.EstimateImpact(diff, ImpactOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error from EstimateImpact: %v", err)
+	} else if len(estimate.Statements) != 1 || !IsForbiddenDiff(estimate.Statements[0].Err) || estimate.Statements[0].RewriteBytes <= 0 {
+		t.Errorf("Unexpected result from EstimateImpact: %+v", estimate.Statements)
+	}
+}
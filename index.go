@@ -51,7 +51,9 @@ func (idx *Index) Definition(flavor Flavor) string {
 	if idx.Comment != "" {
 		comment = fmt.Sprintf(" COMMENT '%s'", EscapeValueForCreateTable(idx.Comment))
 	}
-	if idx.Invisible {
+	if idx.Invisible && flavor.Vendor == VendorMariaDB {
+		invis = " IGNORED"
+	} else if idx.Invisible {
 		invis = " /*!80000 INVISIBLE */"
 	}
 	if idx.Type == "FULLTEXT" && idx.FullTextParser != "" {
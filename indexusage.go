@@ -0,0 +1,219 @@
+package tengo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// IndexUsage combines an introspected secondary index with runtime statistics
+// from performance_schema, which reflect activity since the server was last
+// restarted (or the statistics were truncated).
+type IndexUsage struct {
+	Table                *Table
+	Index                *Index
+	CountRead            uint64 // rows read via the index
+	CountFetch           uint64 // rows fetched via the index
+	CountInsert          uint64
+	CountUpdate          uint64
+	CountDelete          uint64
+	CountStar            uint64 // total I/O operations using the index
+	Cardinality          int64  // estimated distinct values, from information_schema.statistics; -1 if unknown
+	RequiredByForeignKey bool   // true if the index is the only one able to support a foreign key
+}
+
+// Unused returns true if no I/O operations have used the index since
+// statistics collection began.
+func (iu *IndexUsage) Unused() bool {
+	return iu.CountStar == 0
+}
+
+// IndexUsageReport contains usage statistics for all secondary indexes in a
+// schema.
+type IndexUsageReport struct {
+	Schema  string
+	Uptime  time.Duration // how long the server has been collecting statistics
+	Indexes []*IndexUsage // all secondary indexes, sorted by table name and then index position
+	Unused  []*IndexUsage // subset of Indexes which have never been used
+}
+
+// IndexUsage returns runtime usage statistics for the secondary indexes of
+// schema, which should have been introspected from this instance. Statistics
+// come from performance_schema.table_io_waits_summary_by_index_usage, which
+// must be enabled; otherwise an error is returned. If the sys schema is
+// present, its schema_unused_indexes view determines which indexes are
+// unused; otherwise, indexes with no recorded I/O are considered unused. In
+// either case, indexes lacking any performance_schema row, such as those of
+// tables not opened since restart, are considered unused.
+// Note that usage statistics are reset whenever the server restarts, so
+// results from a recently-restarted server should be interpreted cautiously;
+// see the report's Uptime.
+func (instance *Instance) IndexUsage(schema *Schema) (*IndexUsageReport, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	report := &IndexUsageReport{
+		Schema:  schema.Name,
+		Indexes: []*IndexUsage{},
+		Unused:  []*IndexUsage{},
+	}
+
+	var rawUsage []struct {
+		TableName   string `db:"object_name"`
+		IndexName   string `db:"index_name"`
+		CountRead   uint64 `db:"count_read"`
+		CountFetch  uint64 `db:"count_fetch"`
+		CountInsert uint64 `db:"count_insert"`
+		CountUpdate uint64 `db:"count_update"`
+		CountDelete uint64 `db:"count_delete"`
+		CountStar   uint64 `db:"count_star"`
+	}
+	var rawCardinality []struct {
+		TableName   string        `db:"table_name"`
+		IndexName   string        `db:"index_name"`
+		Cardinality sql.NullInt64 `db:"cardinality"`
+	}
+	var rawUnused []struct {
+		TableName string `db:"object_name"`
+		IndexName string `db:"index_name"`
+	}
+	var haveSys bool
+	err = instance.retry(context.Background(), true, func() error {
+		rawUsage, rawCardinality, rawUnused = nil, nil, nil
+		var enabled, uptime string
+		if err := db.QueryRow("SELECT @@global.performance_schema").Scan(&enabled); err != nil {
+			return err
+		} else if !showBool(enabled) {
+			return fmt.Errorf("Index usage statistics unavailable on %s: performance_schema is not enabled", instance)
+		}
+		if err := db.QueryRow("SHOW GLOBAL STATUS LIKE 'Uptime'").Scan(new(string), &uptime); err != nil {
+			return err
+		}
+		seconds, _ := strconv.ParseInt(uptime, 10, 64)
+		report.Uptime = time.Duration(seconds) * time.Second
+
+		query := `
+			SELECT object_name AS object_name, index_name AS index_name,
+			       count_read AS count_read, count_fetch AS count_fetch,
+			       count_insert AS count_insert, count_update AS count_update,
+			       count_delete AS count_delete, count_star AS count_star
+			FROM   performance_schema.table_io_waits_summary_by_index_usage
+			WHERE  object_schema = ? AND index_name IS NOT NULL`
+		if err := db.Select(&rawUsage, query, schema.Name); err != nil {
+			return err
+		}
+		query = `
+			SELECT   table_name AS table_name, index_name AS index_name,
+			         cardinality AS cardinality
+			FROM     information_schema.statistics
+			WHERE    table_schema = ?
+			ORDER BY table_name, index_name, seq_in_index`
+		if err := db.Select(&rawCardinality, query, schema.Name); err != nil {
+			return err
+		}
+		query = `
+			SELECT object_name AS object_name, index_name AS index_name
+			FROM   sys.schema_unused_indexes
+			WHERE  object_schema = ?`
+		haveSys = (db.Select(&rawUnused, query, schema.Name) == nil)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Index cardinality is that of its final column, i.e. the last row for each
+	// index in the ordered result
+	cardinality := make(map[string]int64)
+	for _, rc := range rawCardinality {
+		key := rc.TableName + "." + rc.IndexName
+		if rc.Cardinality.Valid {
+			cardinality[key] = rc.Cardinality.Int64
+		} else {
+			cardinality[key] = -1
+		}
+	}
+	unused := make(map[string]bool, len(rawUnused))
+	for _, ru := range rawUnused {
+		unused[ru.TableName+"."+ru.IndexName] = true
+	}
+	usage := make(map[string]*IndexUsage, len(rawUsage))
+	for _, ru := range rawUsage {
+		usage[ru.TableName+"."+ru.IndexName] = &IndexUsage{
+			CountRead:   ru.CountRead,
+			CountFetch:  ru.CountFetch,
+			CountInsert: ru.CountInsert,
+			CountUpdate: ru.CountUpdate,
+			CountDelete: ru.CountDelete,
+			CountStar:   ru.CountStar,
+		}
+	}
+
+	tables := make([]*Table, len(schema.Tables))
+	copy(tables, schema.Tables)
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	for _, t := range tables {
+		for _, idx := range t.SecondaryIndexes {
+			key := t.Name + "." + idx.Name
+			iu, haveRow := usage[key]
+			if !haveRow {
+				// Tables which have never been opened since restart have no rows in
+				// performance_schema, and are therefore also absent from the sys
+				// schema's view
+				iu = &IndexUsage{}
+			}
+			iu.Table, iu.Index = t, idx
+			iu.Cardinality = -1
+			if c, ok := cardinality[key]; ok {
+				iu.Cardinality = c
+			}
+			iu.RequiredByForeignKey = indexRequiredByForeignKey(schema, t, idx)
+			report.Indexes = append(report.Indexes, iu)
+			if !haveRow || (haveSys && unused[key]) || (!haveSys && iu.Unused()) {
+				report.Unused = append(report.Unused, iu)
+			}
+		}
+	}
+	return report, nil
+}
+
+// indexRequiredByForeignKey returns true if idx is the only index of t which
+// supports a foreign key, either one defined on t, or one defined on another
+// table in schema which references t. Such an index cannot be dropped without
+// first adding another suitable index.
+func indexRequiredByForeignKey(schema *Schema, t *Table, idx *Index) bool {
+	onlySupport := func(colNames []string) bool {
+		if !indexSupportsColumns(idx, colNames) {
+			return false
+		}
+		if t.PrimaryKey != nil && indexSupportsColumns(t.PrimaryKey, colNames) {
+			return false
+		}
+		for _, other := range t.SecondaryIndexes {
+			if other != idx && indexSupportsColumns(other, colNames) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, fk := range t.ForeignKeys {
+		if onlySupport(fk.ColumnNames) {
+			return true
+		}
+	}
+	for _, child := range schema.Tables {
+		for _, fk := range child.ForeignKeys {
+			if fk.ReferencedTableName == t.Name && (fk.ReferencedSchemaName == "" || fk.ReferencedSchemaName == schema.Name) && onlySupport(fk.ReferencedColumnNames) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package tengo
+
+import (
+	"testing"
+)
+
+func TestIndexRequiredByForeignKey(t *testing.T) {
+	warranties := foreignKeyTable()
+	products := &Table{
+		Name:       "products",
+		Columns:    []*Column{{Name: "line"}, {Name: "model"}, {Name: "name"}},
+		PrimaryKey: &Index{Name: "PRIMARY", PrimaryKey: true, Parts: []IndexPart{{ColumnName: "name"}}, Type: "BTREE"},
+		SecondaryIndexes: []*Index{
+			{Name: "line_model", Parts: []IndexPart{{ColumnName: "line"}, {ColumnName: "model"}}, Type: "BTREE"},
+			{Name: "model", Parts: []IndexPart{{ColumnName: "model"}}, Type: "BTREE"},
+		},
+	}
+	schema := aSchema("purchasing", &warranties, products)
+
+	// Each FK of warranties is supported by exactly one secondary index
+	for _, idx := range warranties.SecondaryIndexes {
+		if !indexRequiredByForeignKey(&schema, &warranties, idx) {
+			t.Errorf("Expected index %s to be required by a foreign key, but it was not", idx.Name)
+		}
+	}
+
+	// products.line_model is required since the product_fk references it, but
+	// products.model is not
+	if !indexRequiredByForeignKey(&schema, products, products.SecondaryIndexes[0]) {
+		t.Error("Expected products.line_model to be required by a foreign key, but it was not")
+	}
+	if indexRequiredByForeignKey(&schema, products, products.SecondaryIndexes[1]) {
+		t.Error("Expected products.model to not be required by a foreign key, but it was")
+	}
+
+	// Once another index supports the same columns, neither is required
+	products.SecondaryIndexes = append(products.SecondaryIndexes, &Index{
+		Name:  "line_model_name",
+		Parts: []IndexPart{{ColumnName: "line"}, {ColumnName: "model"}, {ColumnName: "name"}},
+		Type:  "BTREE",
+	})
+	if indexRequiredByForeignKey(&schema, products, products.SecondaryIndexes[0]) {
+		t.Error("Expected products.line_model to no longer be required by a foreign key, but it was")
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceIndexUsage(t *testing.T) {
+	var enabled string
+	db, err := s.d.ConnectionPool("testing", "")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer db.Close()
+	if err := db.QueryRow("SELECT @@global.performance_schema").Scan(&enabled); err != nil {
+		t.Fatalf("Unexpected error querying performance_schema: %v", err)
+	}
+	schema := s.GetSchema(t, "testing")
+	if !showBool(enabled) {
+		if _, err := s.d.IndexUsage(schema); err == nil {
+			t.Error("Expected error from IndexUsage with performance_schema disabled, but err was nil")
+		}
+		t.Skipf("Skipping remainder of test: performance_schema not enabled on %s", s.d.Image)
+	}
+
+	// Use the actor.idx_ssn index, so that it is known to be used
+	if _, err := db.Exec("SELECT actor_id FROM actor FORCE INDEX (idx_ssn) WHERE ssn = '123'"); err != nil {
+		t.Fatalf("Unexpected error querying actor: %v", err)
+	}
+
+	report, err := s.d.IndexUsage(schema)
+	if err != nil {
+		t.Fatalf("Unexpected error from IndexUsage: %v", err)
+	}
+	var expectCount int
+	for _, table := range schema.Tables {
+		expectCount += len(table.SecondaryIndexes)
+	}
+	if len(report.Indexes) != expectCount {
+		t.Errorf("Expected %d indexes in report, instead found %d", expectCount, len(report.Indexes))
+	}
+	if report.Uptime <= 0 {
+		t.Errorf("Expected positive uptime, instead found %s", report.Uptime)
+	}
+	var foundSSN, foundName bool
+	for _, iu := range report.Indexes {
+		if iu.Table.Name != "actor" {
+			continue
+		}
+		switch iu.Index.Name {
+		case "idx_ssn":
+			foundSSN = true
+			if iu.Unused() || iu.CountStar == 0 {
+				t.Errorf("Expected actor.idx_ssn to be used, instead found %+v", *iu)
+			}
+		case "idx_actor_name":
+			foundName = true
+			if iu.RequiredByForeignKey {
+				t.Error("Expected actor.idx_actor_name to not be required by a foreign key")
+			}
+		}
+	}
+	if !foundSSN || !foundName {
+		t.Errorf("Expected report to contain actor's secondary indexes; found idx_ssn=%t idx_actor_name=%t", foundSSN, foundName)
+	}
+	unused := make(map[*IndexUsage]bool, len(report.Unused))
+	for _, iu := range report.Unused {
+		if iu.Table.Name == "actor" && iu.Index.Name == "idx_ssn" {
+			t.Error("Expected actor.idx_ssn to not be listed as unused")
+		}
+		unused[iu] = true
+	}
+	for _, iu := range report.Indexes {
+		if iu.Unused() && !unused[iu] {
+			t.Errorf("Expected %s.%s to be listed as unused, since it has no recorded I/O", iu.Table.Name, iu.Index.Name)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tengo
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
 	"net/url"
@@ -10,7 +11,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/VividCortex/mysqlerr"
 	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/nozzle/throttler"
@@ -37,6 +37,15 @@ type Instance struct {
 	bufferPoolSize int64
 	sqlMode        []string
 	valid          bool // true if any conn has ever successfully been made yet
+	retryPolicy    RetryPolicy
+
+	passwordProvider PasswordProvider     // if non-nil, overrides Password for each new conn
+	poolPolicy       PoolPolicy           // limits on cached connection pools
+	poolLastUsed     map[string]time.Time // key is same as connectionPool; lazily initialized
+	poolLeases       map[string]int       // key is same as connectionPool; count of leases preventing eviction
+	tracer           QueryTracer          // if non-nil, used by all subsequently-created pools
+	tlsConfig        *tls.Config          // if non-nil, registered with the driver as tlsConfigName
+	tlsConfigName    string
 }
 
 // NewInstance returns a pointer to a new Instance corresponding to the
@@ -137,15 +146,45 @@ func (instance *Instance) ConnectionPool(defaultSchema, params string) (*sqlx.DB
 func (instance *Instance) CachedConnectionPool(defaultSchema, params string) (*sqlx.DB, error) {
 	fullParams := instance.buildParamString(params)
 	key := fmt.Sprintf("%s?%s", defaultSchema, fullParams)
+	instance.m.Lock()
+	defer instance.m.Unlock()
+	return instance.cachedConnectionPool(key, defaultSchema, fullParams)
+}
 
+// leasedConnectionPool operates like CachedConnectionPool, but also protects
+// the pool from eviction by the pool policy until the returned release function
+// is called. Methods of Instance should use this instead of
+// CachedConnectionPool, since a pool has no connections in use before its first
+// query or between queries, and could otherwise be closed by another goroutine
+// requesting a different pool.
+func (instance *Instance) leasedConnectionPool(defaultSchema, params string) (*sqlx.DB, func(), error) {
+	fullParams := instance.buildParamString(params)
+	key := fmt.Sprintf("%s?%s", defaultSchema, fullParams)
 	instance.m.Lock()
 	defer instance.m.Unlock()
+	db, err := instance.cachedConnectionPool(key, defaultSchema, fullParams)
+	if err != nil {
+		return nil, nil, err
+	}
+	return db, instance.leasePool(key), nil
+}
+
+// cachedConnectionPool returns the cached pool with the supplied key, creating
+// it if it does not exist yet. The caller must hold instance.m.
+func (instance *Instance) cachedConnectionPool(key, defaultSchema, fullParams string) (*sqlx.DB, error) {
 	if pool, ok := instance.connectionPool[key]; ok {
+		instance.touchPool(key)
+		instance.enforcePoolPolicy(key)
 		return pool, nil
 	}
 	db, err := instance.rawConnectionPool(defaultSchema, fullParams, true)
 	if err == nil {
 		instance.connectionPool[key] = db
+		instance.touchPool(key)
+		if instance.poolPolicy.OnOpen != nil {
+			instance.poolPolicy.OnOpen(key)
+		}
+		instance.enforcePoolPolicy(key)
 	}
 	return db, err
 }
@@ -157,10 +196,23 @@ func (instance *Instance) Connect(defaultSchema string, params string) (*sqlx.DB
 
 func (instance *Instance) rawConnectionPool(defaultSchema, fullParams string, alreadyLocked bool) (*sqlx.DB, error) {
 	fullDSN := fmt.Sprintf("%s%s?%s", instance.BaseDSN, defaultSchema, fullParams)
-	db, err := sqlx.Connect(instance.Driver, fullDSN)
+	var tracer QueryTracer
+	if alreadyLocked {
+		tracer = instance.tracer
+	} else {
+		instance.m.Lock()
+		tracer = instance.tracer
+		instance.m.Unlock()
+	}
+	connector, err := instance.connector(fullDSN, tracer)
 	if err != nil {
 		return nil, err
 	}
+	db := sqlx.NewDb(sql.OpenDB(connector), instance.Driver)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
 	if !instance.valid {
 		instance.hydrateVars(db, !alreadyLocked)
 	}
@@ -168,13 +220,9 @@ func (instance *Instance) rawConnectionPool(defaultSchema, fullParams string, al
 	// Set max concurrent connections, ensuring it is less than any limit set on
 	// the database side either globally or for this user. This does not completely
 	// eliminate max-conn problems, because each Instance can have many separate
-	// connection pools, but it may help.
-	if instance.maxUserConns > 0 {
-		if instance.maxUserConns < 12 {
-			db.SetMaxOpenConns(2)
-		} else {
-			db.SetMaxOpenConns(instance.maxUserConns - 10)
-		}
+	// connection pools, but it may help. See also PoolPolicy.MaxOpenConns.
+	if limit := instance.maxPoolConns(); limit > 0 {
+		db.SetMaxOpenConns(limit)
 	}
 
 	// Determine max conn lifetime, ensuring it is less than wait_timeout, and no
@@ -216,14 +264,18 @@ func (instance *Instance) Valid() (bool, error) {
 
 // CloseAll closes all of instance's cached connection pools. This can be
 // useful for graceful shutdown, to avoid aborted-connection counters/logging
-// in some versions of MySQL.
+// in some versions of MySQL. If the instance registered a TLS config with the
+// driver, it is deregistered; it will be registered again automatically if the
+// instance is subsequently used.
 func (instance *Instance) CloseAll() {
 	instance.m.Lock()
-	for key, db := range instance.connectionPool {
-		db.Close()
-		delete(instance.connectionPool, key)
+	for key := range instance.connectionPool {
+		instance.closePool(key)
 	}
 	instance.m.Unlock()
+	if instance.tlsConfig != nil {
+		mysql.DeregisterTLSConfig(instance.tlsConfigName)
+	}
 }
 
 // Flavor returns this instance's flavor value, representing the database
@@ -291,11 +343,32 @@ func (instance *Instance) hydrateVars(db *sqlx.DB, lock bool) {
 		       @@session.max_user_connections AS maxuserconns,
 		       @@global.max_connections AS maxconns`
 	if err = db.Get(&result, query); err != nil {
-		return
+		// Vitess vtgate does not support querying some of these variables. Fall
+		// back to just the version vars, which it does support, so that its flavor
+		// can still be detected. The other vars are left at their zero values,
+		// which cause default behaviors: sql_mode is not overridden in
+		// introspectionParams, pools use the default max conn lifetime and have
+		// no max open conns limit, and buffer pool size is treated as unknown.
+		query = "SELECT @@version_comment AS versioncomment, @@version AS version"
+		if db.Get(&result, query) != nil || ParseFlavor(result.Version, result.VersionComment).Vendor != VendorVitess {
+			return
+		}
 	}
 	instance.valid = true
 	instance.version = ParseVersion(result.Version)
 	instance.flavor = ParseFlavor(result.Version, result.VersionComment)
+	if instance.flavor.Vendor == VendorMySQL {
+		// Aurora MySQL may be indistinguishable from MySQL in its version and
+		// version_comment, but only Aurora has @@aurora_version
+		var auroraVersion string
+		if db.QueryRow("SELECT @@aurora_version").Scan(&auroraVersion) == nil {
+			instance.flavor.Vendor = VendorAurora
+		}
+	}
+	if instance.flavor.Vendor == VendorAurora {
+		// Aurora MySQL 3's version string lacks a MySQL patch number
+		instance.version = [3]int{instance.flavor.Major, instance.flavor.Minor, instance.flavor.Patch}
+	}
 	instance.sqlMode = strings.Split(result.SQLMode, ",")
 	instance.waitTimeout = result.WaitTimeout
 	instance.bufferPoolSize = result.BufferPoolSize
@@ -330,10 +403,11 @@ func (instance *Instance) CanSkipBinlog() bool {
 }
 
 func (instance *Instance) hydrateGrants() {
-	db, err := instance.CachedConnectionPool("", "")
+	db, release, err := instance.leasedConnectionPool("", "")
 	if err != nil {
 		return
 	}
+	defer release()
 	instance.m.Lock()
 	defer instance.m.Unlock()
 	db.Select(&instance.grants, "SHOW GRANTS")
@@ -342,16 +416,21 @@ func (instance *Instance) hydrateGrants() {
 // SchemaNames returns a slice of all schema name strings on the instance
 // visible to the user. System schemas are excluded.
 func (instance *Instance) SchemaNames() ([]string, error) {
-	db, err := instance.CachedConnectionPool("", "")
+	db, release, err := instance.leasedConnectionPool("", "")
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 	var result []string
 	query := `
 		SELECT schema_name
 		FROM   information_schema.schemata
 		WHERE  schema_name NOT IN ('information_schema', 'performance_schema', 'mysql', 'test', 'sys')`
-	if err := db.Select(&result, query); err != nil {
+	err = instance.retry(context.Background(), true, func() error {
+		result = nil
+		return db.Select(&result, query)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return result, nil
@@ -362,10 +441,107 @@ func (instance *Instance) SchemaNames() ([]string, error) {
 // more schema names as args to filter the result to just those schemas.
 // Note that the ordering of the resulting slice is not guaranteed.
 func (instance *Instance) Schemas(onlyNames ...string) ([]*Schema, error) {
-	db, err := instance.CachedConnectionPool("", "")
+	var schemas []*Schema
+	err := instance.ForEachSchema(func(s *Schema) error {
+		schemas = append(schemas, s)
+		return nil
+	}, onlyNames...)
 	if err != nil {
 		return nil, err
 	}
+	if schemas == nil {
+		schemas = []*Schema{}
+	}
+	return schemas, nil
+}
+
+// ForEachSchema introspects schemas on the instance visible to the user, and
+// calls fn once per schema as soon as that schema has been fully introspected.
+// Multiple schemas are introspected concurrently, sharing a single budget of
+// connections that respects the user's max_user_connections limit, so callers
+// may begin processing the first schema while others are still loading. If
+// called with no onlyNames, all non-system schemas will be processed; otherwise
+// only the specified schemas are processed.
+// fn is never called concurrently, so it need not be safe for concurrent use.
+// If fn returns an error, remaining introspection is cancelled and that error
+// is returned. The order in which schemas are supplied to fn is not guaranteed.
+func (instance *Instance) ForEachSchema(fn func(*Schema) error, onlyNames ...string) error {
+	return instance.ForEachSchemaWithOptions(fn, IntrospectionOptions{}, onlyNames...)
+}
+
+// ForEachSchemaWithOptions behaves like ForEachSchema, but only introspects
+// the tables and routines permitted by opts.
+func (instance *Instance) ForEachSchemaWithOptions(fn func(*Schema) error, opts IntrospectionOptions, onlyNames ...string) error {
+	var schemas []*Schema
+	err := instance.retry(context.Background(), true, func() (err error) {
+		schemas, err = instance.querySchemata(onlyNames...)
+		return err
+	})
+	if err != nil || len(schemas) == 0 {
+		return err
+	}
+	flavor := instance.Flavor()
+	workers, connsPerSchema := instance.introspectionConcurrency(len(schemas))
+
+	pending := make(chan *Schema, len(schemas))
+	for _, s := range schemas {
+		pending <- s
+	}
+	close(pending)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g, ctx := errgroup.WithContext(ctx)
+	results := make(chan *Schema)
+	for n := 0; n < workers; n++ {
+		g.Go(func() error {
+			for s := range pending {
+				err := instance.retry(ctx, true, func() error {
+					return instance.introspectSchema(ctx, s, flavor, connsPerSchema, opts)
+				})
+				if err != nil {
+					return err
+				}
+				select {
+				case results <- s:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			return nil
+		})
+	}
+	waitErr := make(chan error, 1)
+	go func() {
+		waitErr <- g.Wait()
+		close(results)
+	}()
+
+	// Invoke the callback from this goroutine only. If it fails, cancel the
+	// workers but keep draining results until they have all exited.
+	var callbackErr error
+	for s := range results {
+		if callbackErr == nil {
+			if callbackErr = fn(s); callbackErr != nil {
+				cancel()
+			}
+		}
+	}
+	if err := <-waitErr; callbackErr == nil {
+		return err
+	}
+	return callbackErr
+}
+
+// querySchemata returns Schema values with only their name, default character
+// set, and default collation populated. If no names are supplied, all non-
+// system schemas are returned.
+func (instance *Instance) querySchemata(onlyNames ...string) ([]*Schema, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 	var rawSchemas []struct {
 		Name      string `db:"schema_name"`
 		CharSet   string `db:"default_character_set_name"`
@@ -403,37 +579,69 @@ func (instance *Instance) Schemas(onlyNames ...string) ([]*Schema, error) {
 			CharSet:   rawSchema.CharSet,
 			Collation: rawSchema.Collation,
 		}
-		// Create a non-cached connection pool with this schema as the default
-		// database. The instance.querySchemaX calls below can establish a lot of
-		// connections, so we will explicitly close the pool afterwards, to avoid
-		// keeping a very large number of conns open. (Although idle conns eventually
-		// get closed automatically, this may take too long.)
-		schemaDB, err := instance.ConnectionPool(rawSchema.Name, instance.introspectionParams())
-		if err != nil {
-			return nil, err
-		}
-		flavor := instance.Flavor()
-		if instance.maxUserConns >= 30 {
-			// Limit concurrency to 20, unless limit is already lower than this due to
-			// having a low maxUserConns (see logic in Instance.rawConnectionPool)
-			schemaDB.SetMaxOpenConns(20)
-		}
-		g, ctx := errgroup.WithContext(context.Background())
+	}
+	return schemas, nil
+}
+
+// introspectSchema populates the tables and routines of s, which should
+// already have its name, charset, and collation populated. Only objects
+// permitted by opts are introspected. A non-cached
+// connection pool is created with s as the default database, limited to
+// maxConns open connections. The introspection queries can establish a lot of
+// connections, so the pool is explicitly closed afterwards, to avoid keeping a
+// very large number of conns open. (Although idle conns eventually get closed
+// automatically, this may take too long.)
+func (instance *Instance) introspectSchema(ctx context.Context, s *Schema, flavor Flavor, maxConns int, opts IntrospectionOptions) error {
+	schemaDB, err := instance.ConnectionPool(s.Name, instance.introspectionParams())
+	if err != nil {
+		return err
+	}
+	defer schemaDB.Close()
+	schemaDB.SetMaxOpenConns(maxConns)
+	s.Tables, s.Routines = []*Table{}, []*Routine{}
+	g, subCtx := errgroup.WithContext(ctx)
+	if opts.wantsType(ObjectTypeTable) {
 		g.Go(func() (err error) {
-			schemas[n].Tables, err = querySchemaTables(ctx, schemaDB, rawSchema.Name, "", flavor)
+			s.Tables, err = querySchemaTables(subCtx, schemaDB, s.Name, flavor, opts.tableFilter())
 			return err
 		})
+	}
+	if opts.wantsType(ObjectTypeProc) || opts.wantsType(ObjectTypeFunc) {
 		g.Go(func() (err error) {
-			schemas[n].Routines, err = querySchemaRoutines(ctx, schemaDB, rawSchema.Name, flavor)
+			s.Routines, err = querySchemaRoutines(subCtx, schemaDB, s.Name, flavor, opts.routineTypes()...)
 			return err
 		})
-		err = g.Wait()
-		schemaDB.Close()
-		if err != nil {
-			return nil, err
-		}
 	}
-	return schemas, nil
+	return g.Wait()
+}
+
+// Constants controlling how many connections may be used for introspection.
+// maxIntrospectionConns is the total budget shared by all schemas being
+// introspected at once; minConnsPerSchema prevents that budget from being
+// spread so thinly that each individual schema loads slowly.
+const (
+	maxIntrospectionConns = 20
+	minConnsPerSchema     = 4
+)
+
+// introspectionConcurrency returns the number of schemas that may be
+// introspected at once, along with the max number of open connections each
+// one may use, such that the product of the two never exceeds the overall
+// connection budget. The budget is lowered if the instance has a low
+// maxUserConns (see Instance.maxPoolConns).
+func (instance *Instance) introspectionConcurrency(schemaCount int) (workers, connsPerSchema int) {
+	budget := maxIntrospectionConns
+	if limit := instance.maxPoolConns(); limit > 0 && limit < budget {
+		budget = limit
+	}
+	workers = budget / minConnsPerSchema
+	if workers > schemaCount {
+		workers = schemaCount
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers, budget / workers
 }
 
 // SchemasByName returns a map of schema name string to *Schema.  If
@@ -463,21 +671,89 @@ func (instance *Instance) Schema(name string) (*Schema, error) {
 	return schemas[0], nil
 }
 
+// RefreshSchema introspects the schema with the same name as prior, which
+// should have been previously introspected (or loaded from a Snapshot) as of
+// time since. Only tables which are new or have changed since then are fully
+// re-introspected; unchanged *Table values from prior are reused in the result,
+// so callers must not modify them. Routines are always re-introspected. If
+// since is the zero time, change detection relies solely on comparing SHOW
+// CREATE TABLE output, which is slower but does not depend on timestamps.
+// If the schema no longer exists, nil will be returned along with a
+// sql.ErrNoRows error.
+func (instance *Instance) RefreshSchema(prior *Schema, since time.Time) (s *Schema, err error) {
+	err = instance.retry(context.Background(), true, func() error {
+		s, err = instance.refreshSchema(prior, since)
+		return err
+	})
+	return s, err
+}
+
+func (instance *Instance) refreshSchema(prior *Schema, since time.Time) (*Schema, error) {
+	schemas, err := instance.querySchemata(prior.Name)
+	if err != nil {
+		return nil, err
+	} else if len(schemas) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	s := schemas[0]
+	flavor := instance.Flavor()
+	_, maxConns := instance.introspectionConcurrency(1)
+	schemaDB, err := instance.ConnectionPool(s.Name, instance.introspectionParams())
+	if err != nil {
+		return nil, err
+	}
+	defer schemaDB.Close()
+	schemaDB.SetMaxOpenConns(maxConns)
+	g, ctx := errgroup.WithContext(context.Background())
+	g.Go(func() (err error) {
+		s.Tables, err = refreshSchemaTables(ctx, schemaDB, prior, since, flavor)
+		return err
+	})
+	g.Go(func() (err error) {
+		s.Routines, err = querySchemaRoutines(ctx, schemaDB, s.Name, flavor)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// SchemaWithOptions returns a single schema by name, only introspecting the
+// tables and routines permitted by opts. If the schema does not exist, nil will
+// be returned along with a sql.ErrNoRows error.
+func (instance *Instance) SchemaWithOptions(name string, opts IntrospectionOptions) (*Schema, error) {
+	var schema *Schema
+	err := instance.ForEachSchemaWithOptions(func(s *Schema) error {
+		schema = s
+		return nil
+	}, opts, name)
+	if err != nil {
+		return nil, err
+	} else if schema == nil {
+		return nil, sql.ErrNoRows
+	}
+	return schema, nil
+}
+
 // HasSchema returns true if this instance has a schema with the supplied name
 // visible to the user, or false otherwise. An error result will only be
 // returned if a connection or query failed entirely and we weren't able to
 // determine whether the schema exists.
 func (instance *Instance) HasSchema(name string) (bool, error) {
-	db, err := instance.CachedConnectionPool("", "")
+	db, release, err := instance.leasedConnectionPool("", "")
 	if err != nil {
 		return false, err
 	}
+	defer release()
 	var exists int
 	query := `
 		SELECT 1
 		FROM   information_schema.schemata
 		WHERE  schema_name = ?`
-	err = db.Get(&exists, query, name)
+	err = instance.retry(context.Background(), true, func() error {
+		return db.Get(&exists, query, name)
+	})
 	if err == nil {
 		return true, nil
 	} else if err == sql.ErrNoRows {
@@ -490,11 +766,17 @@ func (instance *Instance) HasSchema(name string) (bool, error) {
 // ShowCreateTable returns a string with a CREATE TABLE statement, representing
 // how the instance views the specified table as having been created.
 func (instance *Instance) ShowCreateTable(schema, table string) (string, error) {
-	db, err := instance.CachedConnectionPool(schema, instance.introspectionParams())
+	db, release, err := instance.leasedConnectionPool(schema, instance.introspectionParams())
 	if err != nil {
 		return "", err
 	}
-	return showCreateTable(context.Background(), db, table)
+	defer release()
+	var create string
+	err = instance.retry(context.Background(), true, func() (err error) {
+		create, err = showCreateTable(context.Background(), db, table)
+		return err
+	})
+	return create, err
 }
 
 // introspectionParams returns a params string which ensures safe session
@@ -504,8 +786,8 @@ func (instance *Instance) introspectionParams() string {
 	v.Set("sql_quote_show_create", "1")
 
 	// In MySQL 8, ensure we get up-to-date values for table sizes as well as next
-	// auto_increment value
-	if instance.Flavor().HasDataDictionary() {
+	// auto_increment value. (Vitess vtgate does not permit setting this.)
+	if flavor := instance.Flavor(); flavor.HasDataDictionary() && flavor.Vendor != VendorVitess {
 		v.Set("information_schema_stats_expiry", "0")
 	}
 
@@ -543,15 +825,18 @@ func showCreateTable(ctx context.Context, db *sqlx.DB, table string) (string, er
 // accuracy. For example, see https://bugs.mysql.com/bug.php?id=75428.
 func (instance *Instance) TableSize(schema, table string) (int64, error) {
 	var result int64
-	db, err := instance.CachedConnectionPool("", instance.introspectionParams())
+	db, release, err := instance.leasedConnectionPool("", instance.introspectionParams())
 	if err != nil {
 		return 0, err
 	}
-	err = db.Get(&result, `
-		SELECT  data_length + index_length + data_free
-		FROM    information_schema.tables
-		WHERE   table_schema = ? and table_name = ?`,
-		schema, table)
+	defer release()
+	err = instance.retry(context.Background(), true, func() error {
+		return db.Get(&result, `
+			SELECT  data_length + index_length + data_free
+			FROM    information_schema.tables
+			WHERE   table_schema = ? and table_name = ?`,
+			schema, table)
+	})
 	return result, err
 }
 
@@ -559,11 +844,17 @@ func (instance *Instance) TableSize(schema, table string) (int64, error) {
 // occurs in querying, also returns true (along with the error) since a false
 // positive is generally less dangerous in this case than a false negative.
 func (instance *Instance) TableHasRows(schema, table string) (bool, error) {
-	db, err := instance.CachedConnectionPool(schema, "")
+	db, release, err := instance.leasedConnectionPool(schema, "")
 	if err != nil {
 		return true, err
 	}
-	return tableHasRows(db, table)
+	defer release()
+	hasRows := true
+	err = instance.retry(context.Background(), true, func() (err error) {
+		hasRows, err = tableHasRows(db, table)
+		return err
+	})
+	return hasRows, err
 }
 
 func tableHasRows(db *sqlx.DB, table string) (bool, error) {
@@ -611,10 +902,11 @@ func (opts SchemaCreationOptions) params() string {
 // optionally the supplied default CharSet and Collation. (Leave these fields
 // blank to use server defaults.)
 func (instance *Instance) CreateSchema(name string, opts SchemaCreationOptions) (*Schema, error) {
-	db, err := instance.CachedConnectionPool("", opts.params())
+	db, release, err := instance.leasedConnectionPool("", opts.params())
 	if err != nil {
 		return nil, err
 	}
+	defer release()
 	// Technically the server defaults would be used anyway if these are left
 	// blank, but we need the returned Schema value to reflect the correct values,
 	// and we can avoid re-querying this way
@@ -636,8 +928,7 @@ func (instance *Instance) CreateSchema(name string, opts SchemaCreationOptions)
 		Collation: opts.DefaultCollation,
 		Tables:    []*Table{},
 	}
-	_, err = db.Exec(schema.CreateStatement())
-	if err != nil {
+	if err := instance.exec(db, schema.CreateStatement()); err != nil {
 		return nil, err
 	}
 	return schema, nil
@@ -658,22 +949,21 @@ func (instance *Instance) DropSchema(schema string, opts BulkDropOptions) error
 	s := &Schema{
 		Name: schema,
 	}
-	db, err := instance.CachedConnectionPool("", opts.params())
+	db, release, err := instance.leasedConnectionPool("", opts.params())
 	if err != nil {
 		return err
 	}
-	_, err = db.Exec(s.DropStatement())
-	if err != nil {
+	defer release()
+	if err := instance.exec(db, s.DropStatement()); err != nil {
 		return err
 	}
 
 	prefix := fmt.Sprintf("%s?", schema)
 	instance.m.Lock()
 	defer instance.m.Unlock()
-	for key, connPool := range instance.connectionPool {
+	for key := range instance.connectionPool {
 		if strings.HasPrefix(key, prefix) {
-			connPool.Close()
-			delete(instance.connectionPool, key)
+			instance.closePool(key)
 		}
 	}
 	return nil
@@ -693,22 +983,21 @@ func (instance *Instance) AlterSchema(schema string, opts SchemaCreationOptions)
 	if statement == "" {
 		return nil
 	}
-	db, err := instance.CachedConnectionPool("", opts.params())
+	db, release, err := instance.leasedConnectionPool("", opts.params())
 	if err != nil {
 		return err
 	}
-	if _, err = db.Exec(statement); err != nil {
-		return err
-	}
-	return nil
+	defer release()
+	return instance.exec(db, statement)
 }
 
 // BulkDropOptions controls how objects are dropped in bulk.
 type BulkDropOptions struct {
-	OnlyIfEmpty     bool // If true, when dropping tables, error if any have rows
-	MaxConcurrency  int  // Max objects to drop at once
-	SkipBinlog      bool // If true, use session sql_log_bin=0 (requires superuser)
-	PartitionsFirst bool // If true, drop RANGE/LIST partitioned tables one partition at a time
+	OnlyIfEmpty     bool      // If true, when dropping tables, error if any have rows
+	MaxConcurrency  int       // Max objects to drop at once
+	SkipBinlog      bool      // If true, use session sql_log_bin=0 (requires superuser)
+	PartitionsFirst bool      // If true, drop RANGE/LIST partitioned tables one partition at a time
+	Throttler       Throttler // If non-nil, Wait is called before each DROP statement
 }
 
 func (opts BulkDropOptions) params() string {
@@ -726,16 +1015,29 @@ func (opts BulkDropOptions) Concurrency() int {
 	return opts.MaxConcurrency
 }
 
+// throttle waits on opts.Throttler, if one was supplied.
+func (opts BulkDropOptions) throttle() error {
+	if opts.Throttler == nil {
+		return nil
+	}
+	return opts.Throttler.Wait(context.Background())
+}
+
 // DropTablesInSchema drops all tables in a schema. If opts.OnlyIfEmpty==true,
 // returns an error if any of the tables have any rows.
 func (instance *Instance) DropTablesInSchema(schema string, opts BulkDropOptions) error {
-	db, err := instance.CachedConnectionPool(schema, opts.params())
+	db, release, err := instance.leasedConnectionPool(schema, opts.params())
 	if err != nil {
 		return err
 	}
+	defer release()
 
 	// Obtain table and partition names
-	tableMap, err := tablesToPartitions(db, schema)
+	var tableMap map[string][]string
+	err = instance.retry(context.Background(), true, func() (err error) {
+		tableMap, err = tablesToPartitions(db, schema)
+		return err
+	})
 	if err != nil {
 		return err
 	} else if len(tableMap) == 0 {
@@ -759,46 +1061,64 @@ func (instance *Instance) DropTablesInSchema(schema string, opts BulkDropOptions
 	if instance.bufferPoolSize >= (32*1024*1024*1024) && !instance.flavor.MySQLishMinVersion(8, 0, 23) {
 		concurrency = 1
 	}
+	// With the new data dictionary added in MySQL 8.0, attempting to
+	// concurrently drop two tables that have a foreign key constraint between
+	// them can deadlock. Deadlocks are always retried here, even if the
+	// instance's retry policy does not otherwise permit it. Tables which still
+	// deadlock are dropped sequentially afterwards, once the concurrent drops
+	// have finished.
+	dropPolicy := instance.retryPolicy.withClass(ErrorClassDeadlock, dropDeadlockAttempts)
+	dropTable := func(name string) error {
+		return dropPolicy.do(context.Background(), false, func() error {
+			_, err := db.Exec(fmt.Sprintf("DROP TABLE %s", EscapeIdentifier(name)))
+			return err
+		})
+	}
 	th := throttler.New(concurrency, len(tableMap))
 	retries := make(chan string, len(tableMap))
+	var throttleErr error
 	for name, partitions := range tableMap {
-		go func(name string, partitions []string) {
-			var err error
-			if len(partitions) > 1 && opts.PartitionsFirst {
-				err = dropPartitions(db, name, partitions[0:len(partitions)-1])
+		// Once the Throttler has returned an error, skip all remaining tables
+		if throttleErr == nil {
+			throttleErr = opts.throttle()
+		}
+		go func(name string, partitions []string, err error) {
+			if err == nil && len(partitions) > 1 && opts.PartitionsFirst {
+				err = instance.dropPartitions(db, name, partitions[0:len(partitions)-1], opts)
 			}
 			if err == nil {
-				_, err := db.Exec(fmt.Sprintf("DROP TABLE %s", EscapeIdentifier(name)))
-				// With the new data dictionary added in MySQL 8.0, attempting to
-				// concurrently drop two tables that have a foreign key constraint between
-				// them can deadlock.
-				if IsDatabaseError(err, mysqlerr.ER_LOCK_DEADLOCK) {
+				err = dropTable(name)
+				if ClassifyError(err) == ErrorClassDeadlock {
 					retries <- name
 					err = nil
 				}
 			}
 			th.Done(err)
-		}(name, partitions)
+		}(name, partitions, throttleErr)
 		th.Throttle()
 	}
 	close(retries)
+	if errs := th.Errs(); len(errs) > 0 {
+		return errs[0]
+	}
 	for name := range retries {
-		if _, err := db.Exec(fmt.Sprintf("DROP TABLE %s", EscapeIdentifier(name))); err != nil {
+		if err := opts.throttle(); err != nil {
+			return err
+		}
+		if err := dropTable(name); err != nil {
 			return err
 		}
-	}
-	if errs := th.Errs(); len(errs) > 0 {
-		return errs[0]
 	}
 	return nil
 }
 
 // DropRoutinesInSchema drops all stored procedures and functions in a schema.
 func (instance *Instance) DropRoutinesInSchema(schema string, opts BulkDropOptions) error {
-	db, err := instance.CachedConnectionPool(schema, opts.params())
+	db, release, err := instance.leasedConnectionPool(schema, opts.params())
 	if err != nil {
 		return err
 	}
+	defer release()
 
 	// Obtain names and types directly; faster than going through
 	// instance.Schema(schema) since we don't need other introspection
@@ -810,18 +1130,29 @@ func (instance *Instance) DropRoutinesInSchema(schema string, opts BulkDropOptio
 		SELECT routine_name AS routine_name, UPPER(routine_type) AS routine_type
 		FROM   information_schema.routines
 		WHERE  routine_schema = ?`
-	if err := db.Select(&routineInfo, query, schema); err != nil {
+	err = instance.retry(context.Background(), true, func() error {
+		routineInfo = nil
+		return db.Select(&routineInfo, query, schema)
+	})
+	if err != nil {
 		return err
 	} else if len(routineInfo) == 0 {
 		return nil
 	}
 
 	th := throttler.New(opts.Concurrency(), len(routineInfo))
+	var throttleErr error
 	for _, ri := range routineInfo {
-		go func(name, typ string) {
-			_, err := db.Exec(fmt.Sprintf("DROP %s %s", typ, EscapeIdentifier(name)))
+		// Once the Throttler has returned an error, skip all remaining routines
+		if throttleErr == nil {
+			throttleErr = opts.throttle()
+		}
+		go func(name, typ string, err error) {
+			if err == nil {
+				err = instance.exec(db, fmt.Sprintf("DROP %s %s", typ, EscapeIdentifier(name)))
+			}
 			th.Done(err)
-		}(ri.Name, ri.Type)
+		}(ri.Name, ri.Type, throttleErr)
 		th.Throttle()
 	}
 	if errs := th.Errs(); len(errs) > 0 {
@@ -873,9 +1204,12 @@ func tablesToPartitions(db *sqlx.DB, schema string) (map[string][]string, error)
 	return partitions, nil
 }
 
-func dropPartitions(db *sqlx.DB, table string, partitions []string) error {
+func (instance *Instance) dropPartitions(db *sqlx.DB, table string, partitions []string, opts BulkDropOptions) error {
 	for _, partName := range partitions {
-		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s",
+		if err := opts.throttle(); err != nil {
+			return err
+		}
+		err := instance.exec(db, fmt.Sprintf("ALTER TABLE %s DROP PARTITION %s",
 			EscapeIdentifier(table),
 			EscapeIdentifier(partName)))
 		if err != nil {
@@ -885,13 +1219,104 @@ func dropPartitions(db *sqlx.DB, table string, partitions []string) error {
 	return nil
 }
 
+// ExecOptions controls how statements are run by ExecStatements.
+type ExecOptions struct {
+	Params    string    // Session params, in the same format as for ConnectionPool
+	Throttler Throttler // If non-nil, Wait is called before each statement
+}
+
+// ExecStatements runs the supplied statements sequentially, using the supplied
+// default schema (which may be "" if not relevant). All statements are run on
+// the same connection, so session state persists between them. Execution stops
+// at the first error, which is returned along with the statement that caused
+// it. Failed statements are retried according to the instance's retry policy,
+// but only while no explicit transaction is open: retrying a single statement
+// after InnoDB has rolled back its transaction would break atomicity.
+func (instance *Instance) ExecStatements(schema string, statements []string, opts ExecOptions) error {
+	db, release, err := instance.leasedConnectionPool(schema, opts.Params)
+	if err != nil {
+		return err
+	}
+	defer release()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	var trx trxTracker
+	for _, stmt := range statements {
+		if opts.Throttler != nil {
+			if err := opts.Throttler.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		exec := func() error {
+			_, err := conn.ExecContext(ctx, stmt)
+			return err
+		}
+		if inTrx := trx.track(stmt); inTrx {
+			err = exec()
+		} else {
+			err = instance.retry(ctx, false, exec)
+		}
+		if err != nil {
+			return fmt.Errorf("Error executing statement on %s: %w\nStatement: %s", instance, err, stmt)
+		}
+	}
+	return nil
+}
+
+var (
+	reTrxBegin      = regexp.MustCompile(`(?i)^\s*(?:BEGIN|START\s+TRANSACTION|XA\s+(?:START|BEGIN))\b`)
+	reTrxEnd        = regexp.MustCompile(`(?i)^\s*(?:COMMIT|ROLLBACK(?:\s+WORK)?\s*(?:AND\b|RELEASE\b|;|$)|XA\s+(?:COMMIT|ROLLBACK)\b)`)
+	reAutocommitOff = regexp.MustCompile(`(?i)^\s*SET\b.*\bautocommit\s*:?=\s*(?:0|OFF|FALSE)\b`)
+	reAutocommitOn  = regexp.MustCompile(`(?i)^\s*SET\b.*\bautocommit\s*:?=\s*(?:1|ON|TRUE)\b`)
+)
+
+// trxTracker determines whether statements run sequentially on a single
+// connection may be part of a transaction, based on the statement text. It errs
+// on the side of considering a transaction open.
+type trxTracker struct {
+	explicit      bool // true if BEGIN, START TRANSACTION, or XA START has been run
+	autocommitOff bool // true if autocommit has been disabled
+}
+
+// track updates the tracker's state for stmt, and returns true if stmt may run
+// as part of a transaction, or may end one.
+func (trx *trxTracker) track(stmt string) bool {
+	before := trx.explicit || trx.autocommitOff
+	if reTrxBegin.MatchString(stmt) {
+		trx.explicit = true
+	} else if reTrxEnd.MatchString(stmt) {
+		trx.explicit = false
+	} else if reAutocommitOff.MatchString(stmt) {
+		trx.autocommitOff = true
+	} else if reAutocommitOn.MatchString(stmt) {
+		trx.explicit, trx.autocommitOff = false, false // enabling autocommit implicitly commits
+	}
+	return before || trx.explicit || trx.autocommitOff
+}
+
 // DefaultCharSetAndCollation returns the instance's default character set and
 // collation
 func (instance *Instance) DefaultCharSetAndCollation() (serverCharSet, serverCollation string, err error) {
-	db, err := instance.CachedConnectionPool("", "")
+	db, release, err := instance.leasedConnectionPool("", "")
 	if err != nil {
 		return
 	}
-	err = db.QueryRow("SELECT @@global.character_set_server, @@global.collation_server").Scan(&serverCharSet, &serverCollation)
+	defer release()
+	err = instance.retry(context.Background(), true, func() error {
+		return db.QueryRow("SELECT @@global.character_set_server, @@global.collation_server").Scan(&serverCharSet, &serverCollation)
+	})
 	return
 }
+
+// exec runs a statement which modifies the database, retrying according to
+// the instance's retry policy.
+func (instance *Instance) exec(db *sqlx.DB, statement string) error {
+	return instance.retry(context.Background(), false, func() error {
+		_, err := db.Exec(statement)
+		return err
+	})
+}
@@ -1,13 +1,18 @@
 package tengo
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -146,6 +151,132 @@ func TestInstanceIntrospectionParams(t *testing.T) {
 	assertParams(FlavorMySQL80, "NO_FIELD_OPTIONS,NO_BACKSLASH_ESCAPES,NO_KEY_OPTIONS,NO_TABLE_OPTIONS", "sql_quote_show_create=1&information_schema_stats_expiry=0&sql_mode=%27NO_BACKSLASH_ESCAPES%27")
 }
 
+// vtgateConnector is a driver.Connector emulating how Vitess vtgate responds
+// to the queries made by Instance.hydrateVars: only the version variables may
+// be queried.
+type vtgateConnector struct{}
+
+func (vc vtgateConnector) Connect(ctx context.Context) (driver.Conn, error) { return vtgateConn{}, nil }
+func (vc vtgateConnector) Driver() driver.Driver                            { return mysql.MySQLDriver{} }
+
+type vtgateConn struct{}
+
+func (vc vtgateConn) Prepare(query string) (driver.Stmt, error) { return vtgateStmt(query), nil }
+func (vc vtgateConn) Close() error                              { return nil }
+func (vc vtgateConn) Begin() (driver.Tx, error)                 { return nil, errors.New("Not supported") }
+
+type vtgateStmt string
+
+func (vs vtgateStmt) Close() error  { return nil }
+func (vs vtgateStmt) NumInput() int { return 0 }
+func (vs vtgateStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("Not supported")
+}
+func (vs vtgateStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.Contains(string(vs), "@@global.innodb_buffer_pool_size") {
+		return nil, &mysql.MySQLError{Number: 1105, Message: "unsupported: system variable innodb_buffer_pool_size"}
+	}
+	return &vtgateRows{values: []driver.Value{[]byte("Version: 18.0.0"), []byte("8.0.30-Vitess")}}, nil
+}
+
+type vtgateRows struct {
+	values []driver.Value
+	done   bool
+}
+
+func (vr *vtgateRows) Columns() []string { return []string{"versioncomment", "version"} }
+func (vr *vtgateRows) Close() error      { return nil }
+func (vr *vtgateRows) Next(dest []driver.Value) error {
+	if vr.done {
+		return io.EOF
+	}
+	vr.done = true
+	copy(dest, vr.values)
+	return nil
+}
+
+func TestInstanceHydrateVarsVitess(t *testing.T) {
+	instance, err := NewInstance("mysql", "username:password@tcp(1.2.3.4:3306)/")
+	if err != nil {
+		t.Fatalf("NewInstance returned unexpected error: %v", err)
+	}
+	db := sqlx.NewDb(sql.OpenDB(vtgateConnector{}), "mysql")
+	defer db.Close()
+	instance.hydrateVars(db, true)
+	if !instance.valid {
+		t.Fatal("Expected instance to be valid after hydrateVars")
+	}
+	if expected := (Flavor{VendorVitess, 8, 0, 30}); instance.flavor != expected {
+		t.Errorf("Expected flavor %s, instead found %s", expected, instance.flavor)
+	}
+
+	// Other vars are left at zero values, which must result in default behavior
+	if instance.maxUserConns != 0 || instance.waitTimeout != 0 || instance.bufferPoolSize != 0 {
+		t.Errorf("Unexpected values for vars not supported by vtgate: maxUserConns=%d waitTimeout=%d bufferPoolSize=%d", instance.maxUserConns, instance.waitTimeout, instance.bufferPoolSize)
+	}
+	if limit := instance.maxPoolConns(); limit != 0 {
+		t.Errorf("Expected no limit on pool conns, instead found %d", limit)
+	}
+	if params := instance.introspectionParams(); params != "sql_quote_show_create=1" {
+		t.Errorf("Unexpected introspection params %q", params)
+	}
+}
+
+func TestTrxTracker(t *testing.T) {
+	cases := []struct {
+		statements []string
+		expected   []bool
+	}{
+		{[]string{"UPDATE t SET x = 1", "DELETE FROM t"}, []bool{false, false}},
+		{[]string{"BEGIN", "UPDATE t SET x = 1", "COMMIT", "DELETE FROM t"}, []bool{true, true, true, false}},
+		{[]string{"start transaction read write", "UPDATE t SET x = 1", "ROLLBACK", "DELETE FROM t"}, []bool{true, true, true, false}},
+		{[]string{"BEGIN", "SAVEPOINT s1", "ROLLBACK TO SAVEPOINT s1", "UPDATE t SET x = 1", "COMMIT"}, []bool{true, true, true, true, true}},
+		{[]string{"SET autocommit=0", "UPDATE t SET x = 1", "COMMIT", "DELETE FROM t"}, []bool{true, true, true, true}},
+		{[]string{"SET SESSION autocommit = OFF", "UPDATE t SET x = 1", "SET autocommit=1", "DELETE FROM t"}, []bool{true, true, true, false}},
+		{[]string{"SET sql_mode = ''", "ALTER TABLE t ADD COLUMN y int"}, []bool{false, false}},
+	}
+	for n, c := range cases {
+		var trx trxTracker
+		for i, stmt := range c.statements {
+			if actual := trx.track(stmt); actual != c.expected[i] {
+				t.Errorf("cases[%d]: Expected track(%q) to return %t, instead found %t", n, stmt, c.expected[i], actual)
+			}
+		}
+	}
+}
+
+func TestInstanceIntrospectionConcurrency(t *testing.T) {
+	instance, err := NewInstance("mysql", "username:password@tcp(1.2.3.4:3306)/")
+	if err != nil {
+		t.Fatalf("NewInstance returned unexpected error: %v", err)
+	}
+	cases := []struct {
+		maxUserConns         int
+		schemaCount          int
+		expectWorkers        int
+		expectConnsPerSchema int
+	}{
+		{0, 1, 1, 20},
+		{0, 3, 3, 6},
+		{0, 500, 5, 4},
+		{1000, 500, 5, 4},
+		{20, 500, 2, 5},
+		{20, 1, 1, 10},
+		{11, 500, 1, 2},
+		{14, 500, 1, 4},
+	}
+	for _, c := range cases {
+		instance.maxUserConns = c.maxUserConns
+		workers, conns := instance.introspectionConcurrency(c.schemaCount)
+		if workers != c.expectWorkers || conns != c.expectConnsPerSchema {
+			t.Errorf("maxUserConns=%d schemaCount=%d: expected %d workers with %d conns each; instead found %d workers with %d conns each", c.maxUserConns, c.schemaCount, c.expectWorkers, c.expectConnsPerSchema, workers, conns)
+		}
+		if workers*conns > maxIntrospectionConns {
+			t.Errorf("maxUserConns=%d schemaCount=%d: total conns %d exceeds budget", c.maxUserConns, c.schemaCount, workers*conns)
+		}
+	}
+}
+
 func (s TengoIntegrationSuite) TestInstanceConnect(t *testing.T) {
 	// Connecting to invalid schema should return an error
 	db, err := s.d.Connect("does-not-exist", "")
@@ -451,6 +582,53 @@ func (s TengoIntegrationSuite) TestInstanceSchemas(t *testing.T) {
 	}
 }
 
+func (s TengoIntegrationSuite) TestInstanceForEachSchema(t *testing.T) {
+	schemas, err := s.d.Schemas()
+	if err != nil {
+		t.Fatalf("Unexpected error from Schemas: %v", err)
+	}
+
+	seen := make(map[string]bool, len(schemas))
+	err = s.d.ForEachSchema(func(schema *Schema) error {
+		if seen[schema.Name] {
+			t.Errorf("Schema %s passed to callback multiple times", schema.Name)
+		}
+		seen[schema.Name] = true
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error from ForEachSchema: %v", err)
+	} else if len(seen) != len(schemas) {
+		t.Errorf("Expected ForEachSchema to process %d schemas, instead processed %d", len(schemas), len(seen))
+	}
+
+	// Callback errors should halt processing and be returned as-is
+	stopErr := errors.New("stop here")
+	var calls int
+	err = s.d.ForEachSchema(func(schema *Schema) error {
+		calls++
+		return stopErr
+	})
+	if err != stopErr {
+		t.Errorf("Expected ForEachSchema to return callback's error, instead found %v", err)
+	} else if calls != 1 {
+		t.Errorf("Expected callback to be called once, instead called %d times", calls)
+	}
+
+	// Filtering by name
+	calls = 0
+	err = s.d.ForEachSchema(func(schema *Schema) error {
+		calls++
+		if schema.Name != "testing" || schema.Table("actor") == nil {
+			t.Errorf("Unexpected schema passed to callback: %s", schema.Name)
+		}
+		return nil
+	}, "testing", "doesnt_exist")
+	if err != nil || calls != 1 {
+		t.Errorf("Unexpected result from ForEachSchema with name filter: calls=%d err=%v", calls, err)
+	}
+}
+
 func (s TengoIntegrationSuite) TestInstanceShowCreateTable(t *testing.T) {
 	t1create, err1 := s.d.ShowCreateTable("testing", "actor")
 	t2create, err2 := s.d.ShowCreateTable("testing", "actor_in_film")
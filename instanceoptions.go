@@ -0,0 +1,234 @@
+package tengo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// PasswordProvider returns a password for establishing a new connection. This
+// permits use of short-lived credentials, such as IAM authentication tokens,
+// which must be regenerated periodically.
+type PasswordProvider func(ctx context.Context) (string, error)
+
+// InstanceOptions permits constructing an Instance from structured options,
+// rather than a DSN string. See NewInstanceWithOptions.
+type InstanceOptions struct {
+	Host       string // Ignored if SocketPath is set
+	Port       int    // 3306 if 0; ignored if SocketPath is set
+	SocketPath string // Path to UNIX domain socket; if set, Host and Port are ignored
+	User       string
+	Password   string
+
+	// PasswordProvider, if non-nil, is called to obtain a fresh password each
+	// time a new connection is established, overriding Password.
+	PasswordProvider PasswordProvider
+
+	// TLS settings. If TLS is true, or any of the TLS file fields are set, all
+	// connections will require TLS. TLSCAFile specifies a PEM file of
+	// certificate authorities used to verify the server certificate; if empty,
+	// the system's CAs are used. TLSCertFile and TLSKeyFile specify a PEM client
+	// certificate and key, and must be used together. TLSServerName overrides
+	// the host name used to verify the server certificate; it does not apply to
+	// other instances discovered from this one, such as replicas, which are
+	// verified using their own host names. TLSSkipVerify disables verification
+	// of the server certificate entirely.
+	TLS           bool
+	TLSCAFile     string
+	TLSCertFile   string
+	TLSKeyFile    string
+	TLSServerName string
+	TLSSkipVerify bool
+
+	// AllowCleartextPasswords permits the mysql_clear_password authentication
+	// plugin, which is required by some token-based authentication schemes. This
+	// should only be used with TLS or a UNIX domain socket.
+	AllowCleartextPasswords bool
+
+	ConnectTimeout time.Duration // Dial timeout for new connections; driver default if 0
+	Params         string        // Additional default params in format "foo=bar&fizz=buzz"
+	Tracer         QueryTracer   // If non-nil, all statements are traced; see Instance.SetQueryTracer
+	RetryPolicy    RetryPolicy   // See Instance.SetRetryPolicy; no retries if zero value
+}
+
+// tlsConfigCounter is used to generate unique names for TLS configs registered
+// with the driver.
+var tlsConfigCounter uint64
+
+// registerTLSConfig registers config with the driver under a new unique name,
+// which is returned.
+func registerTLSConfig(config *tls.Config) (string, error) {
+	name := fmt.Sprintf("tengo-%d", atomic.AddUint64(&tlsConfigCounter, 1))
+	if err := mysql.RegisterTLSConfig(name, config); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// NewInstanceWithOptions returns a pointer to a new Instance based on the
+// supplied options. If TLS options are used, a TLS config is registered with
+// the driver automatically, and deregistered by Instance.CloseAll. Certificate
+// files are read immediately, so any problem with them results in an error
+// here, rather than at connection time.
+func NewInstanceWithOptions(opts InstanceOptions) (*Instance, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = opts.User
+	cfg.Passwd = opts.Password
+	if opts.SocketPath != "" {
+		cfg.Net = "unix"
+		cfg.Addr = opts.SocketPath
+	} else if opts.Host != "" {
+		port := opts.Port
+		if port == 0 {
+			port = 3306
+		}
+		cfg.Net = "tcp"
+		cfg.Addr = net.JoinHostPort(opts.Host, strconv.Itoa(port))
+	} else {
+		return nil, fmt.Errorf("NewInstanceWithOptions: either Host or SocketPath must be supplied")
+	}
+
+	params, err := url.ParseQuery(opts.Params)
+	if err != nil {
+		return nil, fmt.Errorf("NewInstanceWithOptions: invalid Params: %s", err)
+	}
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	} else if tlsConfig != nil {
+		name, err := registerTLSConfig(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("tls", name)
+	}
+	if opts.AllowCleartextPasswords {
+		params.Set("allowCleartextPasswords", "true")
+	}
+	if opts.ConnectTimeout > 0 {
+		params.Set("timeout", opts.ConnectTimeout.String())
+	}
+	dsn := cfg.FormatDSN()
+	if len(params) > 0 {
+		dsn += "?" + params.Encode()
+	}
+	instance, err := NewInstance("mysql", dsn)
+	if err != nil {
+		if tlsConfig != nil {
+			mysql.DeregisterTLSConfig(params.Get("tls"))
+		}
+		return nil, err
+	}
+	if tlsConfig != nil {
+		instance.tlsConfigName, instance.tlsConfig = params.Get("tls"), tlsConfig
+	}
+	instance.passwordProvider = opts.PasswordProvider
+	instance.tracer = opts.Tracer
+	instance.retryPolicy = opts.RetryPolicy
+	return instance, nil
+}
+
+// tlsConfig returns a *tls.Config based on the TLS fields of opts, or nil if
+// TLS is not in use.
+func (opts InstanceOptions) tlsConfig() (*tls.Config, error) {
+	if !opts.TLS && !opts.TLSSkipVerify && opts.TLSCAFile == "" && opts.TLSCertFile == "" && opts.TLSKeyFile == "" {
+		return nil, nil
+	}
+	config := &tls.Config{
+		ServerName:         opts.TLSServerName,
+		InsecureSkipVerify: opts.TLSSkipVerify,
+	}
+	if config.ServerName == "" && opts.SocketPath == "" {
+		config.ServerName = opts.Host
+	}
+	if opts.TLSCAFile != "" {
+		pem, err := ioutil.ReadFile(opts.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read TLS CA file: %s", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No valid certificates found in TLS CA file %s", opts.TLSCAFile)
+		}
+	}
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		if opts.TLSCertFile == "" || opts.TLSKeyFile == "" {
+			return nil, fmt.Errorf("TLSCertFile and TLSKeyFile must be used together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to load TLS client certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// providerConnector is a driver.Connector which obtains a fresh password from
+// a PasswordProvider for each new connection.
+type providerConnector struct {
+	cfg      *mysql.Config
+	provider PasswordProvider
+}
+
+// Connect satisfies the driver.Connector interface.
+func (pc *providerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := pc.provider(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to obtain password: %s", err)
+	}
+	cfg := pc.cfg.Clone()
+	cfg.Passwd = password
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver satisfies the driver.Connector interface.
+func (pc *providerConnector) Driver() driver.Driver {
+	return mysql.MySQLDriver{}
+}
+
+// connector returns a driver.Connector for the supplied full DSN. If the
+// instance has a password provider, the connector obtains a fresh password
+// for each new connection. If tracer is non-nil, all statements executed using
+// the connector's connections are traced; callers should supply the instance's
+// tracer, which must be read while holding instance.m.
+func (instance *Instance) connector(fullDSN string, tracer QueryTracer) (connector driver.Connector, err error) {
+	if instance.tlsConfig != nil {
+		// Re-register, in case CloseAll deregistered the config previously
+		if err := mysql.RegisterTLSConfig(instance.tlsConfigName, instance.tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := mysql.ParseDSN(fullDSN)
+	if err != nil {
+		return nil, err
+	}
+	if instance.passwordProvider != nil {
+		connector = &providerConnector{cfg: cfg, provider: instance.passwordProvider}
+	} else if connector, err = mysql.NewConnector(cfg); err != nil {
+		return nil, err
+	}
+	if tracer != nil {
+		connector = &tracingConnector{
+			Connector:   connector,
+			tracer:      tracer,
+			schema:      cfg.DBName,
+			interpolate: cfg.InterpolateParams,
+		}
+	}
+	return connector, nil
+}
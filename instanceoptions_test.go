@@ -0,0 +1,122 @@
+package tengo
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewInstanceWithOptions(t *testing.T) {
+	opts := InstanceOptions{
+		Host:                    "some.host",
+		User:                    "username",
+		Password:                "password",
+		AllowCleartextPasswords: true,
+		ConnectTimeout:          3 * time.Second,
+		Params:                  "foo=bar",
+	}
+	instance, err := NewInstanceWithOptions(opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstanceWithOptions: %v", err)
+	}
+	if instance.BaseDSN != "username:password@tcp(some.host:3306)/" || instance.Host != "some.host" || instance.Port != 3306 {
+		t.Errorf("Unexpected instance fields: %+v", *instance)
+	}
+	expectParams := map[string]string{"foo": "bar", "allowCleartextPasswords": "true", "timeout": "3s"}
+	if len(instance.defaultParams) != len(expectParams) {
+		t.Errorf("Unexpected default params: %v", instance.defaultParams)
+	}
+	for k, v := range expectParams {
+		if instance.defaultParams[k] != v {
+			t.Errorf("Expected param %s=%s, instead found %q", k, v, instance.defaultParams[k])
+		}
+	}
+
+	opts = InstanceOptions{SocketPath: "/var/lib/mysql/mysql.sock", User: "root", TLSSkipVerify: true}
+	if instance, err = NewInstanceWithOptions(opts); err != nil {
+		t.Fatalf("Unexpected error from NewInstanceWithOptions: %v", err)
+	}
+	if instance.SocketPath != opts.SocketPath || instance.Host != "localhost" {
+		t.Errorf("Unexpected instance fields: %+v", *instance)
+	}
+	if tlsName := instance.defaultParams["tls"]; !strings.HasPrefix(tlsName, "tengo-") {
+		t.Errorf("Expected tls param to refer to registered config, instead found %q", tlsName)
+	}
+
+	badCAFile, err := ioutil.TempFile("", "tengo-ca")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %v", err)
+	}
+	defer os.Remove(badCAFile.Name())
+	badCAFile.WriteString("not a certificate")
+	badCAFile.Close()
+	badOpts := []InstanceOptions{
+		{User: "root"},
+		{Host: "some.host", Params: "foo=%zz"},
+		{Host: "some.host", TLSCAFile: "/does/not/exist.pem"},
+		{Host: "some.host", TLSCAFile: badCAFile.Name()},
+		{Host: "some.host", TLSCertFile: "client-cert.pem"},
+	}
+	for _, opts := range badOpts {
+		if _, err := NewInstanceWithOptions(opts); err == nil {
+			t.Errorf("Expected error from NewInstanceWithOptions(%+v), but err was nil", opts)
+		}
+	}
+}
+
+func TestPasswordProviderError(t *testing.T) {
+	expectErr := errors.New("token service unavailable")
+	instance, err := NewInstanceWithOptions(InstanceOptions{
+		Host: "127.0.0.1",
+		Port: 1,
+		User: "root",
+		PasswordProvider: func(ctx context.Context) (string, error) {
+			return "", expectErr
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstanceWithOptions: %v", err)
+	}
+	_, err = instance.ConnectionPool("", "")
+	if err == nil || !strings.Contains(err.Error(), expectErr.Error()) {
+		t.Errorf("Expected ConnectionPool to return password provider error, instead found %v", err)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceWithOptionsPasswordProvider(t *testing.T) {
+	var calls int
+	instance, err := NewInstanceWithOptions(InstanceOptions{
+		Host: s.d.Host,
+		Port: s.d.Port,
+		User: s.d.User,
+		PasswordProvider: func(ctx context.Context) (string, error) {
+			calls++
+			return s.d.Password, nil
+		},
+		ConnectTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstanceWithOptions: %v", err)
+	}
+	if ok, err := instance.CanConnect(); !ok || err != nil {
+		t.Fatalf("Unexpected return from CanConnect: %t, %v", ok, err)
+	}
+	if calls == 0 {
+		t.Error("Expected password provider to be called, but it was not")
+	}
+
+	// Rotated credentials should be obtained for subsequent connections
+	callsBefore := calls
+	db, err := instance.ConnectionPool("testing", "")
+	if err != nil {
+		t.Fatalf("Unexpected error from ConnectionPool: %v", err)
+	}
+	db.Close()
+	if calls == callsBefore {
+		t.Error("Expected password provider to be called again for new pool, but it was not")
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/VividCortex/mysqlerr"
 	"github.com/jmoiron/sqlx"
@@ -26,7 +29,7 @@ func QuerySchemaTable(ctx context.Context, db *sqlx.DB, schema, table string, fl
 	if table == "" {
 		return nil, fmt.Errorf("QuerySchemaTable exepects non=empty table name")
 	}
-	tables, err := querySchemaTables(ctx, db, schema, table, flavor)
+	tables, err := querySchemaTables(ctx, db, schema, flavor, tableFilter{names: []string{table}})
 	if err != nil {
 		return nil, err
 	}
@@ -36,8 +39,32 @@ func QuerySchemaTable(ctx context.Context, db *sqlx.DB, schema, table string, fl
 	return tables[0], nil
 }
 
-func querySchemaTables(ctx context.Context, db *sqlx.DB, schema, explicitTable string, flavor Flavor) ([]*Table, error) {
-	tables, havePartitions, err := queryTablesInSchema(ctx, db, schema, explicitTable, flavor)
+// querySchemaTables introspects tables in schema. Only tables matching filter
+// are returned, and the information_schema queries are restricted to those
+// tables as well.
+func querySchemaTables(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, filter tableFilter) ([]*Table, error) {
+	// With a very long list of tables, it is faster (and avoids placeholder
+	// limits) to just introspect everything and then filter the result
+	if len(filter.names) > maxTableNameFilter {
+		names := filter.names
+		filter.names = nil
+		tables, err := querySchemaTables(ctx, db, schema, flavor, filter)
+		if err != nil {
+			return nil, err
+		}
+		wanted := make(map[string]bool, len(names))
+		for _, name := range names {
+			wanted[name] = true
+		}
+		filtered := make([]*Table, 0, len(names))
+		for _, t := range tables {
+			if wanted[t.Name] {
+				filtered = append(filtered, t)
+			}
+		}
+		return filtered, nil
+	}
+	tables, havePartitions, err := queryTablesInSchema(ctx, db, schema, flavor, filter)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +76,7 @@ func querySchemaTables(ctx context.Context, db *sqlx.DB, schema, explicitTable s
 		g.Go(func() (err error) {
 			t.CreateStatement, err = showCreateTable(subCtx, db, t.Name)
 			if err != nil {
-				err = fmt.Errorf("Error executing SHOW CREATE TABLE for %s.%s: %s", EscapeIdentifier(schema), EscapeIdentifier(t.Name), err)
+				err = fmt.Errorf("Error executing SHOW CREATE TABLE for %s.%s: %w", EscapeIdentifier(schema), EscapeIdentifier(t.Name), err)
 			}
 			return err
 		})
@@ -57,27 +84,27 @@ func querySchemaTables(ctx context.Context, db *sqlx.DB, schema, explicitTable s
 
 	var columnsByTableName map[string][]*Column
 	g.Go(func() (err error) {
-		columnsByTableName, err = queryColumnsInSchema(subCtx, db, schema, flavor)
+		columnsByTableName, err = queryColumnsInSchema(subCtx, db, schema, flavor, filter)
 		return err
 	})
 
 	var primaryKeyByTableName map[string]*Index
 	var secondaryIndexesByTableName map[string][]*Index
 	g.Go(func() (err error) {
-		primaryKeyByTableName, secondaryIndexesByTableName, err = queryIndexesInSchema(subCtx, db, schema, flavor)
+		primaryKeyByTableName, secondaryIndexesByTableName, err = queryIndexesInSchema(subCtx, db, schema, flavor, filter)
 		return err
 	})
 
 	var foreignKeysByTableName map[string][]*ForeignKey
 	g.Go(func() (err error) {
-		foreignKeysByTableName, err = queryForeignKeysInSchema(subCtx, db, schema, flavor)
+		foreignKeysByTableName, err = queryForeignKeysInSchema(subCtx, db, schema, flavor, filter)
 		return err
 	})
 
 	var checksByTableName map[string][]*Check
 	if flavor.HasCheckConstraints() {
 		g.Go(func() (err error) {
-			checksByTableName, err = queryChecksInSchema(subCtx, db, schema, flavor)
+			checksByTableName, err = queryChecksInSchema(subCtx, db, schema, flavor, filter)
 			return err
 		})
 	}
@@ -85,7 +112,7 @@ func querySchemaTables(ctx context.Context, db *sqlx.DB, schema, explicitTable s
 	var partitioningByTableName map[string]*TablePartitioning
 	if havePartitions {
 		g.Go(func() (err error) {
-			partitioningByTableName, err = queryPartitionsInSchema(subCtx, db, schema, flavor)
+			partitioningByTableName, err = queryPartitionsInSchema(subCtx, db, schema, flavor, filter)
 			return err
 		})
 	}
@@ -168,7 +195,7 @@ func querySchemaTables(ctx context.Context, db *sqlx.DB, schema, explicitTable s
 	return tables, nil
 }
 
-func queryTablesInSchema(ctx context.Context, db *sqlx.DB, schema, explicitTable string, flavor Flavor) ([]*Table, bool, error) {
+func queryTablesInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, filter tableFilter) ([]*Table, bool, error) {
 	var rawTables []struct {
 		Name               string         `db:"table_name"`
 		Type               string         `db:"table_type"`
@@ -180,7 +207,6 @@ func queryTablesInSchema(ctx context.Context, db *sqlx.DB, schema, explicitTable
 		CharSet            string         `db:"character_set_name"`
 		CollationIsDefault string         `db:"is_default"`
 	}
-	args := []interface{}{schema}
 	query := `
 		SELECT SQL_BUFFER_RESULT
 		       t.table_name AS table_name, t.table_type AS table_type, t.engine AS engine,
@@ -191,13 +217,10 @@ func queryTablesInSchema(ctx context.Context, db *sqlx.DB, schema, explicitTable
 		JOIN   information_schema.collations c ON t.table_collation = c.collation_name
 		WHERE  t.table_schema = ?
 		AND    t.table_type = 'BASE TABLE'`
-	if explicitTable != "" {
-		query += `
-		AND    t.table_name = ?`
-		args = append(args, explicitTable)
-	}
+	where, args := filter.clause("t.table_name", schema)
+	query += where
 	if err := db.SelectContext(ctx, &rawTables, query, args...); err != nil {
-		return nil, false, fmt.Errorf("Error querying information_schema.tables for schema %s: %s", schema, err)
+		return nil, false, fmt.Errorf("Error querying information_schema.tables for schema %s: %w", schema, err)
 	}
 	if len(rawTables) == 0 {
 		return []*Table{}, false, nil
@@ -226,7 +249,7 @@ func queryTablesInSchema(ctx context.Context, db *sqlx.DB, schema, explicitTable
 	return tables, havePartitions, nil
 }
 
-func queryColumnsInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor) (map[string][]*Column, error) {
+func queryColumnsInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, filter tableFilter) (map[string][]*Column, error) {
 	stripDisplayWidth := flavor.OmitIntDisplayWidth()
 	var rawColumns []struct {
 		Name               string         `db:"column_name"`
@@ -252,15 +275,16 @@ func queryColumnsInSchema(ctx context.Context, db *sqlx.DB, schema string, flavo
 		          c.collation_name AS collation_name, co.is_default AS is_default
 		FROM      information_schema.columns c
 		LEFT JOIN information_schema.collations co ON co.collation_name = c.collation_name
-		WHERE     c.table_schema = ?
+		WHERE     c.table_schema = ?%s
 		ORDER BY  c.table_name, c.ordinal_position`
 	genExpr := "NULL"
 	if flavor.GeneratedColumns() {
 		genExpr = "c.generation_expression"
 	}
-	query = fmt.Sprintf(query, genExpr)
-	if err := db.SelectContext(ctx, &rawColumns, query, schema); err != nil {
-		return nil, fmt.Errorf("Error querying information_schema.columns for schema %s: %s", schema, err)
+	where, args := filter.clause("c.table_name", schema)
+	query = fmt.Sprintf(query, genExpr, where)
+	if err := db.SelectContext(ctx, &rawColumns, query, args...); err != nil {
+		return nil, fmt.Errorf("Error querying information_schema.columns for schema %s: %w", schema, err)
 	}
 	columnsByTableName := make(map[string][]*Column)
 	for _, rawColumn := range rawColumns {
@@ -341,7 +365,7 @@ func queryColumnsInSchema(ctx context.Context, db *sqlx.DB, schema string, flavo
 	return columnsByTableName, nil
 }
 
-func queryIndexesInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor) (map[string]*Index, map[string][]*Index, error) {
+func queryIndexesInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, filter tableFilter) (map[string]*Index, map[string][]*Index, error) {
 	var rawIndexes []struct {
 		Name       string         `db:"index_name"`
 		TableName  string         `db:"table_name"`
@@ -363,7 +387,7 @@ func queryIndexesInSchema(ctx context.Context, db *sqlx.DB, schema string, flavo
 		         index_comment AS index_comment, index_type AS index_type,
 		         collation AS collation, %s AS expression, %s AS is_visible
 		FROM     information_schema.statistics
-		WHERE    table_schema = ?`
+		WHERE    table_schema = ?%s`
 	exprSelect, visSelect := "NULL", "'YES'"
 	if flavor.MySQLishMinVersion(8, 0) {
 		// Index expressions added in 8.0.13
@@ -371,10 +395,13 @@ func queryIndexesInSchema(ctx context.Context, db *sqlx.DB, schema string, flavo
 			exprSelect = "expression"
 		}
 		visSelect = "is_visible" // available in all 8.0
+	} else if flavor.VendorMinVersion(VendorMariaDB, 10, 6) {
+		visSelect = "IF(ignored = 'YES', 'NO', 'YES')" // MariaDB calls these ignored indexes
 	}
-	query = fmt.Sprintf(query, exprSelect, visSelect)
-	if err := db.SelectContext(ctx, &rawIndexes, query, schema); err != nil {
-		return nil, nil, fmt.Errorf("Error querying information_schema.statistics for schema %s: %s", schema, err)
+	where, args := filter.clause("table_name", schema)
+	query = fmt.Sprintf(query, exprSelect, visSelect, where)
+	if err := db.SelectContext(ctx, &rawIndexes, query, args...); err != nil {
+		return nil, nil, fmt.Errorf("Error querying information_schema.statistics for schema %s: %w", schema, err)
 	}
 
 	primaryKeyByTableName := make(map[string]*Index)
@@ -428,7 +455,7 @@ func queryIndexesInSchema(ctx context.Context, db *sqlx.DB, schema string, flavo
 	return primaryKeyByTableName, secondaryIndexesByTableName, nil
 }
 
-func queryForeignKeysInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor) (map[string][]*ForeignKey, error) {
+func queryForeignKeysInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, filter tableFilter) (map[string][]*ForeignKey, error) {
 	var rawForeignKeys []struct {
 		Name                 string `db:"constraint_name"`
 		TableName            string `db:"table_name"`
@@ -451,10 +478,13 @@ func queryForeignKeysInSchema(ctx context.Context, db *sqlx.DB, schema string, f
 		JOIN     information_schema.key_column_usage kcu ON kcu.constraint_name = rc.constraint_name AND
 		                                 kcu.table_schema = ? AND
 		                                 kcu.referenced_column_name IS NOT NULL
-		WHERE    rc.constraint_schema = ?
+		WHERE    rc.constraint_schema = ?%s
 		ORDER BY BINARY rc.constraint_name, kcu.ordinal_position`
-	if err := db.SelectContext(ctx, &rawForeignKeys, query, schema, schema); err != nil {
-		return nil, fmt.Errorf("Error querying foreign key constraints for schema %s: %s", schema, err)
+	where, args := filter.clause("rc.table_name", schema)
+	query = fmt.Sprintf(query, where)
+	args = append([]interface{}{schema}, args...)
+	if err := db.SelectContext(ctx, &rawForeignKeys, query, args...); err != nil {
+		return nil, fmt.Errorf("Error querying foreign key constraints for schema %s: %w", schema, err)
 	}
 	foreignKeysByTableName := make(map[string][]*ForeignKey)
 	foreignKeysByName := make(map[string]*ForeignKey)
@@ -479,7 +509,7 @@ func queryForeignKeysInSchema(ctx context.Context, db *sqlx.DB, schema string, f
 	return foreignKeysByTableName, nil
 }
 
-func queryChecksInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor) (map[string][]*Check, error) {
+func queryChecksInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, filter tableFilter) (map[string][]*Check, error) {
 	checksByTableName := make(map[string][]*Check)
 	var rawChecks []struct {
 		Name      string `db:"constraint_name"`
@@ -506,18 +536,20 @@ func queryChecksInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor
 			         constraint_name AS constraint_name, check_clause AS check_clause,
 			         table_name AS table_name, 'YES' AS enforced
 			FROM     information_schema.check_constraints
-			WHERE    constraint_schema = ?`
+			WHERE    constraint_schema = ?%s`
 	} else {
 		query = `
 			SELECT   SQL_BUFFER_RESULT
 			         constraint_name AS constraint_name, '' AS check_clause,
 			         table_name AS table_name, enforced AS enforced
 			FROM     information_schema.table_constraints
-			WHERE    table_schema = ? AND constraint_type = 'CHECK'
+			WHERE    table_schema = ? AND constraint_type = 'CHECK'%s
 			ORDER BY table_name, constraint_name`
 	}
-	if err := db.SelectContext(ctx, &rawChecks, query, schema); err != nil {
-		return nil, fmt.Errorf("Error querying check constraints for schema %s: %s", schema, err)
+	where, args := filter.clause("table_name", schema)
+	query = fmt.Sprintf(query, where)
+	if err := db.SelectContext(ctx, &rawChecks, query, args...); err != nil {
+		return nil, fmt.Errorf("Error querying check constraints for schema %s: %w", schema, err)
 	}
 	for _, rawCheck := range rawChecks {
 		check := &Check{
@@ -530,7 +562,7 @@ func queryChecksInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor
 	return checksByTableName, nil
 }
 
-func queryPartitionsInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor) (map[string]*TablePartitioning, error) {
+func queryPartitionsInSchema(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, filter tableFilter) (map[string]*TablePartitioning, error) {
 	var rawPartitioning []struct {
 		TableName     string         `db:"table_name"`
 		PartitionName string         `db:"partition_name"`
@@ -554,11 +586,13 @@ func queryPartitionsInSchema(ctx context.Context, db *sqlx.DB, schema string, fl
 		         p.partition_comment AS partition_comment
 		FROM     information_schema.partitions p
 		WHERE    p.table_schema = ?
-		AND      p.partition_name IS NOT NULL
+		AND      p.partition_name IS NOT NULL%s
 		ORDER BY p.table_name, p.partition_ordinal_position,
 		         p.subpartition_ordinal_position`
-	if err := db.SelectContext(ctx, &rawPartitioning, query, schema); err != nil {
-		return nil, fmt.Errorf("Error querying information_schema.partitions for schema %s: %s", schema, err)
+	where, args := filter.clause("p.table_name", schema)
+	query = fmt.Sprintf(query, where)
+	if err := db.SelectContext(ctx, &rawPartitioning, query, args...); err != nil {
+		return nil, fmt.Errorf("Error querying information_schema.partitions for schema %s: %w", schema, err)
 	}
 
 	partitioningByTableName := make(map[string]*TablePartitioning)
@@ -584,6 +618,253 @@ func queryPartitionsInSchema(ctx context.Context, db *sqlx.DB, schema string, fl
 	return partitioningByTableName, nil
 }
 
+// refreshClockSkew is subtracted from the "since" time supplied to
+// refreshSchemaTables, to tolerate clock differences between client and server
+// as well as the one-second granularity of information_schema timestamps.
+const refreshClockSkew = time.Minute
+
+// refreshSchemaTables returns the tables in prior's schema, re-introspecting
+// only those which are new or have changed since prior was introspected at
+// time since. Unchanged tables are reused from prior; if only their next
+// auto-increment value has changed, a shallow copy is returned instead, so that
+// prior is never modified.
+// Changes are detected using information_schema.tables.create_time, which is
+// updated by any ALTER TABLE on flavors without a data dictionary. On MySQL 8+,
+// or if since is zero, or for tables lacking a create_time (such as partitioned
+// tables in some versions), SHOW CREATE TABLE is compared instead.
+// information_schema.tables.update_time is not used, since it only reflects
+// changes to data, not to the table definition.
+func refreshSchemaTables(ctx context.Context, db *sqlx.DB, prior *Schema, since time.Time, flavor Flavor) ([]*Table, error) {
+	var rawTables []struct {
+		Name          string        `db:"table_name"`
+		AutoIncrement sql.NullInt64 `db:"auto_increment"`
+		CreateTime    sql.NullInt64 `db:"create_time"`
+	}
+	query := `
+		SELECT SQL_BUFFER_RESULT
+		       table_name AS table_name, auto_increment AS auto_increment,
+		       UNIX_TIMESTAMP(create_time) AS create_time
+		FROM   information_schema.tables
+		WHERE  table_schema = ?
+		AND    table_type = 'BASE TABLE'`
+	if err := db.SelectContext(ctx, &rawTables, query, prior.Name); err != nil {
+		return nil, fmt.Errorf("Error querying information_schema.tables for schema %s: %w", prior.Name, err)
+	}
+
+	priorTables := prior.TablesByName()
+	useCreateTime := !since.IsZero() && !flavor.HasDataDictionary()
+	threshold := since.Add(-refreshClockSkew).Unix()
+	tables := make([]*Table, len(rawTables))
+	var changed []string
+	var compare []int // positions in tables which need SHOW CREATE TABLE comparison
+	for n, rawTable := range rawTables {
+		priorTable := priorTables[rawTable.Name]
+		if priorTable == nil {
+			changed = append(changed, rawTable.Name)
+			continue
+		}
+		tables[n] = priorTable
+		if useCreateTime && rawTable.CreateTime.Valid {
+			if rawTable.CreateTime.Int64 >= threshold {
+				changed = append(changed, rawTable.Name)
+				tables[n] = nil
+			} else if t := tableWithNextAutoInc(priorTable, uint64(rawTable.AutoIncrement.Int64)); t != nil {
+				tables[n] = t
+			} else {
+				changed = append(changed, rawTable.Name)
+				tables[n] = nil
+			}
+		} else {
+			compare = append(compare, n)
+		}
+	}
+
+	// For tables that could not be assessed by create_time, compare SHOW CREATE
+	// TABLE, ignoring next auto-increment value
+	var mu sync.Mutex
+	g, subCtx := errgroup.WithContext(ctx)
+	for _, n := range compare {
+		n := n // avoid issues with goroutines and loop iterator values
+		g.Go(func() error {
+			priorTable := tables[n]
+			create, err := showCreateTable(subCtx, db, priorTable.Name)
+			if err == sql.ErrNoRows {
+				return nil // dropped since querying information_schema
+			} else if err != nil {
+				return fmt.Errorf("Error executing SHOW CREATE TABLE for %s.%s: %w", EscapeIdentifier(prior.Name), EscapeIdentifier(priorTable.Name), err)
+			}
+			if priorTable.Engine == "InnoDB" {
+				create = NormalizeCreateOptions(create)
+			}
+			actual, nextAutoInc := ParseCreateAutoInc(create)
+			expected, _ := ParseCreateAutoInc(priorTable.CreateStatement)
+			if actual == expected {
+				if create != priorTable.CreateStatement {
+					t := *priorTable
+					t.CreateStatement = create
+					if t.NextAutoIncrement = nextAutoInc; t.NextAutoIncrement == 0 && t.HasAutoIncrement() {
+						t.NextAutoIncrement = 1
+					}
+					tables[n] = &t
+				}
+				return nil
+			}
+			mu.Lock()
+			changed = append(changed, priorTable.Name)
+			tables[n] = nil
+			mu.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	// Fully introspect new and changed tables
+	var refreshed map[string]*Table
+	if len(changed) > 0 {
+		result, err := querySchemaTables(ctx, db, prior.Name, flavor, tableFilter{names: changed})
+		if err != nil {
+			return nil, err
+		}
+		refreshed = make(map[string]*Table, len(result))
+		for _, t := range result {
+			refreshed[t.Name] = t
+		}
+	}
+	result := make([]*Table, 0, len(tables))
+	for n, t := range tables {
+		if t == nil {
+			t = refreshed[rawTables[n].Name]
+		}
+		if t != nil { // nil if table was dropped concurrently
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// tableWithNextAutoInc returns t if its next auto-increment value is already
+// nextAutoInc, or a shallow copy of t with an updated next auto-increment value
+// otherwise. If the value cannot be updated because t's CreateStatement lacks
+// an AUTO_INCREMENT clause to adjust, nil is returned.
+func tableWithNextAutoInc(t *Table, nextAutoInc uint64) *Table {
+	if !t.HasAutoIncrement() || nextAutoInc == 0 || nextAutoInc == t.NextAutoIncrement {
+		return t
+	}
+	matches := reParseCreateAutoInc.FindStringSubmatch(t.CreateStatement)
+	if matches == nil {
+		return nil
+	}
+	newClause := strings.Replace(matches[1], matches[2], strconv.FormatUint(nextAutoInc, 10), 1)
+	copied := *t
+	copied.CreateStatement = strings.Replace(t.CreateStatement, matches[1], newClause, 1)
+	copied.NextAutoIncrement = nextAutoInc
+	return &copied
+}
+
+// maxTableNameFilter is the largest number of exact table names that
+// querySchemaTables will place in an IN clause of its information_schema
+// queries.
+const maxTableNameFilter = 1000
+
+// IntrospectionOptions restricts which objects are introspected. Filtering is
+// performed in the information_schema queries themselves, so introspecting a
+// few tables of a very large schema is considerably faster than introspecting
+// the entire schema and ignoring most of the result. The zero value introspects
+// everything.
+type IntrospectionOptions struct {
+	// IncludeTables, if non-empty, restricts introspection to tables with names
+	// matching at least one of these patterns. Patterns use the syntax of SQL
+	// LIKE, i.e. % matches any string and _ matches any single character, and
+	// are subject to the server's case-sensitivity of table names.
+	IncludeTables []string
+
+	// ExcludeTables skips introspection of tables with names matching any of
+	// these patterns, which use the same syntax as IncludeTables.
+	ExcludeTables []string
+
+	// ObjectTypes, if non-empty, restricts introspection to objects of these
+	// types. For example, supply []ObjectType{ObjectTypeTable} to skip routines
+	// entirely, or []ObjectType{ObjectTypeProc} to obtain only procedures.
+	ObjectTypes []ObjectType
+}
+
+// wantsType returns true if opts permits introspection of objects of type ot.
+func (opts IntrospectionOptions) wantsType(ot ObjectType) bool {
+	if len(opts.ObjectTypes) == 0 {
+		return true
+	}
+	for _, typ := range opts.ObjectTypes {
+		if typ == ot {
+			return true
+		}
+	}
+	return false
+}
+
+// routineTypes returns the routine types permitted by opts, or nil if all
+// routine types are permitted.
+func (opts IntrospectionOptions) routineTypes() (types []ObjectType) {
+	if opts.wantsType(ObjectTypeProc) && opts.wantsType(ObjectTypeFunc) {
+		return nil
+	}
+	for _, ot := range []ObjectType{ObjectTypeProc, ObjectTypeFunc} {
+		if opts.wantsType(ot) {
+			types = append(types, ot)
+		}
+	}
+	return types
+}
+
+func (opts IntrospectionOptions) tableFilter() tableFilter {
+	return tableFilter{
+		include: opts.IncludeTables,
+		exclude: opts.ExcludeTables,
+	}
+}
+
+// tableFilter restricts the tables included in information_schema queries.
+// The zero value permits all tables.
+type tableFilter struct {
+	names   []string // exact table names
+	include []string // LIKE patterns, any of which may match
+	exclude []string // LIKE patterns, none of which may match
+}
+
+// clause returns a string for appending to the WHERE of an information_schema
+// query, restricting column to tables permitted by the filter. The returned
+// args begin with schema, which the caller's query should use as its first
+// placeholder. If the filter permits all tables, the clause is blank.
+func (filter tableFilter) clause(column, schema string) (string, []interface{}) {
+	args := []interface{}{schema}
+	var b strings.Builder
+	if len(filter.names) > 0 {
+		fmt.Fprintf(&b, "\n\t\tAND      %s IN (%s)", column, placeholders(len(filter.names)))
+		for _, name := range filter.names {
+			args = append(args, name)
+		}
+	}
+	if len(filter.include) > 0 {
+		likes := make([]string, len(filter.include))
+		for n, pattern := range filter.include {
+			likes[n] = column + " LIKE ?"
+			args = append(args, pattern)
+		}
+		fmt.Fprintf(&b, "\n\t\tAND      (%s)", strings.Join(likes, " OR "))
+	}
+	for _, pattern := range filter.exclude {
+		fmt.Fprintf(&b, "\n\t\tAND      %s NOT LIKE ?", column)
+		args = append(args, pattern)
+	}
+	return b.String(), args
+}
+
+// placeholders returns a comma-separated list of count query placeholders.
+func placeholders(count int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", count), ", ")
+}
+
 var reIndexLine = regexp.MustCompile("^\\s+(?:UNIQUE |FULLTEXT |SPATIAL )?KEY `((?:[^`]|``)+)` (?:USING \\w+ )?\\([`(]")
 
 // MySQL 8.0 uses a different index order in SHOW CREATE TABLE than in
@@ -820,7 +1101,15 @@ func fixChecks(t *Table, flavor Flavor) {
 	}
 }
 
-func querySchemaRoutines(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor) ([]*Routine, error) {
+// querySchemaRoutines introspects routines in schema. If onlyTypes is
+// non-empty, only routines of those types are returned.
+func querySchemaRoutines(ctx context.Context, db *sqlx.DB, schema string, flavor Flavor, onlyTypes ...ObjectType) ([]*Routine, error) {
+	// Vitess does not support stored routines, and vtgate does not support the
+	// SHOW CREATE statements needed to introspect them
+	if flavor.Vendor == VendorVitess {
+		return []*Routine{}, nil
+	}
+
 	// Obtain the routines in the schema
 	// We completely exclude routines that the user can call, but not examine --
 	// e.g. user has EXECUTE priv but missing other vital privs. In this case
@@ -848,8 +1137,16 @@ func querySchemaRoutines(ctx context.Context, db *sqlx.DB, schema string, flavor
 		       r.definer AS definer, r.database_collation AS database_collation
 		FROM   information_schema.routines r
 		WHERE  r.routine_schema = ? AND routine_definition IS NOT NULL`
-	if err := db.SelectContext(ctx, &rawRoutines, query, schema); err != nil {
-		return nil, fmt.Errorf("Error querying information_schema.routines for schema %s: %s", schema, err)
+	args := []interface{}{schema}
+	if len(onlyTypes) > 0 {
+		query += fmt.Sprintf(`
+		AND    r.routine_type IN (%s)`, placeholders(len(onlyTypes)))
+		for _, ot := range onlyTypes {
+			args = append(args, ot.Caps())
+		}
+	}
+	if err := db.SelectContext(ctx, &rawRoutines, query, args...); err != nil {
+		return nil, fmt.Errorf("Error querying information_schema.routines for schema %s: %w", schema, err)
 	}
 	if len(rawRoutines) == 0 {
 		return []*Routine{}, nil
@@ -924,7 +1221,7 @@ func querySchemaRoutines(ctx context.Context, db *sqlx.DB, schema string, flavor
 						r.CreateStatement = strings.Replace(r.CreateStatement, "\r\n", "\n", -1)
 						err = r.parseCreateStatement(flavor, schema)
 					} else {
-						err = fmt.Errorf("Error executing SHOW CREATE %s for %s.%s: %s", r.Type.Caps(), EscapeIdentifier(schema), EscapeIdentifier(r.Name), err)
+						err = fmt.Errorf("Error executing SHOW CREATE %s for %s.%s: %w", r.Type.Caps(), EscapeIdentifier(schema), EscapeIdentifier(r.Name), err)
 					}
 					return err
 				})
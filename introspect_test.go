@@ -3,8 +3,15 @@ package tengo
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/VividCortex/mysqlerr"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
 )
 
 func (s TengoIntegrationSuite) TestInstanceSchemaIntrospection(t *testing.T) {
@@ -313,3 +320,227 @@ func (s TengoIntegrationSuite) TestInstanceRoutineIntrospection(t *testing.T) {
 		t.Error("Expected non-nil error return from showCreateRoutine with invalid type, instead found nil")
 	}
 }
+
+func (s TengoIntegrationSuite) TestInstanceRefreshSchema(t *testing.T) {
+	prior := s.GetSchema(t, "testing")
+	db, err := s.d.Connect("testing", "")
+	if err != nil {
+		t.Fatalf("Unexpected error from Connect: %s", err)
+	}
+	statements := []string{
+		"ALTER TABLE actor_in_film ADD COLUMN role varchar(30)",
+		"CREATE TABLE refreshed_new (id int unsigned NOT NULL, PRIMARY KEY (id))",
+		"DROP TABLE no_rows",
+		"INSERT INTO has_rows (name) VALUES ('Bartholomew')",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Unexpected error from Exec: %s", err)
+		}
+	}
+	expected := s.GetSchema(t, "testing")
+
+	// Zero since forces SHOW CREATE TABLE comparison for all existing tables, so
+	// untouched tables should be reused from prior. A non-zero since may use
+	// create_time instead, which can't confirm any table as unchanged here, since
+	// the test data was just created.
+	for _, since := range []time.Time{{}, time.Now()} {
+		refreshed, err := s.d.RefreshSchema(prior, since)
+		if err != nil {
+			t.Fatalf("Unexpected error from RefreshSchema: %s", err)
+		}
+		if objDiffs := refreshed.Diff(expected).ObjectDiffs(); len(objDiffs) > 0 {
+			t.Errorf("Expected refreshed schema to match full introspection, instead found %d diffs: %+v", len(objDiffs), objDiffs)
+		}
+		if len(refreshed.Tables) != len(expected.Tables) || len(refreshed.Routines) != len(expected.Routines) {
+			t.Errorf("Expected refreshed schema to have %d tables and %d routines; instead found %d and %d", len(expected.Tables), len(expected.Routines), len(refreshed.Tables), len(refreshed.Routines))
+		}
+		if refreshed.HasTable("no_rows") || !refreshed.HasTable("refreshed_new") {
+			t.Error("RefreshSchema did not reflect dropped or created table as expected")
+		}
+		if refreshed.Table("has_rows").NextAutoIncrement != expected.Table("has_rows").NextAutoIncrement {
+			t.Errorf("Expected has_rows next auto-increment to be %d, instead found %d", expected.Table("has_rows").NextAutoIncrement, refreshed.Table("has_rows").NextAutoIncrement)
+		}
+		if since.IsZero() && refreshed.Table("actor") != prior.Table("actor") {
+			t.Error("Expected unchanged table actor to be reused from prior schema, but it was not")
+		}
+	}
+	if prior.HasTable("refreshed_new") || !prior.HasTable("no_rows") || len(prior.Table("actor_in_film").Columns) != 2 {
+		t.Error("RefreshSchema unexpectedly modified the prior schema")
+	}
+
+	prior.Name = "doesnt_exist"
+	if _, err := s.d.RefreshSchema(prior, time.Time{}); err != sql.ErrNoRows {
+		t.Errorf("Expected RefreshSchema on nonexistent schema to return sql.ErrNoRows, instead found %v", err)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceSchemaWithOptions(t *testing.T) {
+	full := s.GetSchema(t, "testing")
+
+	opts := IntrospectionOptions{
+		IncludeTables: []string{"actor%", "has\\_rows"},
+		ExcludeTables: []string{"%film"},
+		ObjectTypes:   []ObjectType{ObjectTypeTable, ObjectTypeFunc},
+	}
+	schema, err := s.d.SchemaWithOptions("testing", opts)
+	if err != nil {
+		t.Fatalf("Unexpected error from SchemaWithOptions: %v", err)
+	}
+	if len(schema.Tables) != 2 || !schema.HasTable("actor") || !schema.HasTable("has_rows") {
+		t.Errorf("Unexpected tables returned: %+v", schema.TablesByName())
+	}
+	for _, table := range schema.Tables {
+		if clauses, supported := table.Diff(full.Table(table.Name)); !supported || len(clauses) > 0 || table.CreateStatement != full.Table(table.Name).CreateStatement {
+			t.Errorf("Table %s from SchemaWithOptions does not match full introspection", table.Name)
+		}
+	}
+	if len(schema.Routines) != len(full.FunctionsByName()) || len(schema.ProceduresByName()) != 0 {
+		t.Errorf("Expected only functions to be introspected; instead found %d routines", len(schema.Routines))
+	}
+
+	opts = IntrospectionOptions{ObjectTypes: []ObjectType{ObjectTypeProc}}
+	if schema, err = s.d.SchemaWithOptions("testing", opts); err != nil {
+		t.Fatalf("Unexpected error from SchemaWithOptions: %v", err)
+	} else if len(schema.Tables) != 0 || len(schema.Routines) != len(full.ProceduresByName()) {
+		t.Errorf("Expected only procedures; instead found %d tables and %d routines", len(schema.Tables), len(schema.Routines))
+	}
+
+	if _, err := s.d.SchemaWithOptions("doesnt_exist", opts); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows from SchemaWithOptions on nonexistent schema, instead found %v", err)
+	}
+}
+
+func TestTableWithNextAutoInc(t *testing.T) {
+	table := aTable(5)
+	if result := tableWithNextAutoInc(&table, 5); result != &table {
+		t.Error("Expected same table to be returned when next auto-inc is unchanged")
+	}
+	if result := tableWithNextAutoInc(&table, 0); result != &table {
+		t.Error("Expected same table to be returned when next auto-inc is unknown")
+	}
+	result := tableWithNextAutoInc(&table, 123)
+	if result == nil || result == &table {
+		t.Fatalf("Expected a copy of the table to be returned, instead found %p", result)
+	}
+	if result.NextAutoIncrement != 123 || !strings.Contains(result.CreateStatement, " AUTO_INCREMENT=123 ") {
+		t.Errorf("Next auto-inc not updated as expected: %d / %s", result.NextAutoIncrement, result.CreateStatement)
+	}
+	if table.NextAutoIncrement != 5 || !strings.Contains(table.CreateStatement, " AUTO_INCREMENT=5 ") {
+		t.Error("Original table unexpectedly modified")
+	}
+
+	// Without an AUTO_INCREMENT clause to adjust, nil is returned
+	table = aTable(1)
+	if result := tableWithNextAutoInc(&table, 2); result != nil {
+		t.Errorf("Expected nil return, instead found %+v", result)
+	}
+
+	// Tables without an auto-inc column are always returned unchanged
+	table = anotherTable()
+	if result := tableWithNextAutoInc(&table, 2); result != &table {
+		t.Error("Expected same table to be returned when no auto-inc column present")
+	}
+}
+
+func TestTableFilterClause(t *testing.T) {
+	clause, args := tableFilter{}.clause("t.table_name", "myschema")
+	if clause != "" || len(args) != 1 || args[0] != "myschema" {
+		t.Errorf("Unexpected result from clause with zero-value filter: %q, %v", clause, args)
+	}
+	clause, args = tableFilter{names: []string{"a", "b"}}.clause("t.table_name", "myschema")
+	if !strings.HasSuffix(clause, "AND      t.table_name IN (?, ?)") || len(args) != 3 || args[2] != "b" {
+		t.Errorf("Unexpected result from clause: %q, %v", clause, args)
+	}
+	filter := tableFilter{
+		include: []string{"foo%", "bar_"},
+		exclude: []string{"%_old", "%_new"},
+	}
+	clause, args = filter.clause("table_name", "myschema")
+	expected := []string{
+		"AND      (table_name LIKE ? OR table_name LIKE ?)",
+		"AND      table_name NOT LIKE ?",
+		"AND      table_name NOT LIKE ?",
+	}
+	lines := strings.Split(strings.TrimSpace(clause), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Unexpected result from clause: %q", clause)
+	}
+	for n := range lines {
+		if strings.TrimSpace(lines[n]) != expected[n] {
+			t.Errorf("Line %d of clause: expected %q, found %q", n, expected[n], strings.TrimSpace(lines[n]))
+		}
+	}
+	if len(args) != 5 || args[1] != "foo%" || args[4] != "%_new" {
+		t.Errorf("Unexpected args from clause: %v", args)
+	}
+}
+
+func TestIntrospectionOptionsObjectTypes(t *testing.T) {
+	cases := []struct {
+		types        []ObjectType
+		wantsTable   bool
+		routineTypes []ObjectType
+	}{
+		{nil, true, nil},
+		{[]ObjectType{ObjectTypeTable}, true, nil},
+		{[]ObjectType{ObjectTypeProc}, false, []ObjectType{ObjectTypeProc}},
+		{[]ObjectType{ObjectTypeFunc, ObjectTypeTable}, true, []ObjectType{ObjectTypeFunc}},
+		{[]ObjectType{ObjectTypeFunc, ObjectTypeProc}, false, nil},
+	}
+	for _, c := range cases {
+		opts := IntrospectionOptions{ObjectTypes: c.types}
+		if opts.wantsType(ObjectTypeTable) != c.wantsTable {
+			t.Errorf("ObjectTypes %v: expected wantsType(table) to be %t", c.types, c.wantsTable)
+		}
+		if rt := opts.routineTypes(); !reflect.DeepEqual(rt, c.routineTypes) {
+			t.Errorf("ObjectTypes %v: expected routineTypes %v, instead found %v", c.types, c.routineTypes, rt)
+		}
+	}
+}
+
+// failingConnector is a driver.Connector whose connections return err for
+// every statement.
+type failingConnector struct {
+	err error
+}
+
+func (fc failingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return failingConn(fc), nil
+}
+func (fc failingConnector) Driver() driver.Driver { return mysql.MySQLDriver{} }
+
+type failingConn failingConnector
+
+func (fc failingConn) Prepare(query string) (driver.Stmt, error) { return nil, fc.err }
+func (fc failingConn) Close() error                              { return nil }
+func (fc failingConn) Begin() (driver.Tx, error)                 { return nil, fc.err }
+
+func TestIntrospectionErrorsWrapped(t *testing.T) {
+	ctx := context.Background()
+	flavor := FlavorMySQL80
+	for _, merr := range []*mysql.MySQLError{
+		{Number: mysqlerr.ER_LOCK_DEADLOCK},
+		{Number: mysqlerr.ER_LOCK_WAIT_TIMEOUT},
+	} {
+		db := sqlx.NewDb(sql.OpenDB(failingConnector{err: merr}), "mysql")
+		defer db.Close()
+		expectClass := ClassifyError(merr)
+		errs := make([]error, 8)
+		_, _, errs[0] = queryTablesInSchema(ctx, db, "testing", flavor, tableFilter{})
+		_, errs[1] = queryColumnsInSchema(ctx, db, "testing", flavor, tableFilter{})
+		_, _, errs[2] = queryIndexesInSchema(ctx, db, "testing", flavor, tableFilter{})
+		_, errs[3] = queryForeignKeysInSchema(ctx, db, "testing", flavor, tableFilter{})
+		_, errs[4] = queryChecksInSchema(ctx, db, "testing", flavor, tableFilter{})
+		_, errs[5] = queryPartitionsInSchema(ctx, db, "testing", flavor, tableFilter{})
+		_, errs[6] = refreshSchemaTables(ctx, db, &Schema{Name: "testing"}, time.Now(), flavor)
+		_, errs[7] = querySchemaRoutines(ctx, db, "testing", flavor)
+		for n, err := range errs {
+			if err == nil || err == error(merr) {
+				t.Errorf("Case %d: expected a wrapped error, instead found %v", n, err)
+			} else if class := ClassifyError(err); class != expectClass {
+				t.Errorf("Case %d: expected ClassifyError(%v) to return %s, instead found %s", n, err, expectClass, class)
+			}
+		}
+	}
+}
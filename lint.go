@@ -0,0 +1,336 @@
+package tengo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity indicates how serious a problem found by a LintRule is.
+type Severity string
+
+// Constants enumerating valid severity levels. SeverityIgnore is only useful
+// in LintOptions.Severity, to disable a rule entirely.
+const (
+	SeverityIgnore  Severity = "ignore"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Annotation represents a single problem found in a schema by a LintRule.
+type Annotation struct {
+	Key          ObjectKey // object with the problem; type is ObjectTypeDatabase for schema-level problems
+	Rule         string    // name of the LintRule that found the problem
+	Severity     Severity
+	Message      string
+	SuggestedFix string // DDL which would resolve the problem, or "" if none is available
+}
+
+func (a Annotation) String() string {
+	return fmt.Sprintf("[%s] %s: %s (%s)", a.Severity, a.Key, a.Message, a.Rule)
+}
+
+// LintOptions configures the behavior of Lint and the rules it runs.
+type LintOptions struct {
+	// Flavor is used for generating SuggestedFix DDL.
+	Flavor Flavor
+
+	// AllowedCharSets lists the character sets permitted for schemas, tables,
+	// and columns. If empty, character sets are not checked.
+	AllowedCharSets []string
+
+	// AllowedDefiners lists the definers permitted for routines, in the same
+	// format as Routine.Definer, e.g. "root@%". If empty, definers are not
+	// checked.
+	AllowedDefiners []string
+
+	// Severity overrides the default severity of rules, keyed by rule name. Use
+	// SeverityIgnore to disable a rule.
+	Severity map[string]Severity
+}
+
+// LintRule is the interface for a check performed by Lint. Callers may supply
+// their own implementations to Lint in addition to, or instead of, the rules
+// returned by DefaultLintRules.
+type LintRule interface {
+	// Name returns a short unique identifier for the rule, such as "has-pk".
+	Name() string
+
+	// Check returns annotations for any problems found in schema. The Rule field
+	// of each Annotation will be set to Name() by Lint if left blank, and the
+	// Severity will default to SeverityWarning if left blank.
+	Check(schema *Schema, opts LintOptions) []Annotation
+}
+
+// Lint runs the supplied rules against schema, returning all annotations
+// found. If no rules are supplied, DefaultLintRules is used.
+func Lint(schema *Schema, opts LintOptions, rules ...LintRule) []Annotation {
+	if len(rules) == 0 {
+		rules = DefaultLintRules()
+	}
+	var result []Annotation
+	for _, rule := range rules {
+		override := opts.Severity[rule.Name()]
+		if override == SeverityIgnore {
+			continue
+		}
+		for _, a := range rule.Check(schema, opts) {
+			if a.Rule == "" {
+				a.Rule = rule.Name()
+			}
+			if override != "" {
+				a.Severity = override
+			} else if a.Severity == "" {
+				a.Severity = SeverityWarning
+			}
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// DefaultLintRules returns the rules built into this package.
+func DefaultLintRules() []LintRule {
+	return []LintRule{
+		lintRule{"has-pk", SeverityWarning, lintHasPrimaryKey},
+		lintRule{"dupe-index", SeverityWarning, lintDupeIndex},
+		lintRule{"fk-index", SeverityWarning, lintForeignKeyIndex},
+		lintRule{"engine", SeverityWarning, lintEngine},
+		lintRule{"charset", SeverityWarning, lintCharSet},
+		lintRule{"has-float", SeverityWarning, lintHasFloat},
+		lintRule{"definer", SeverityError, lintDefiner},
+		lintRule{"display-width", SeverityWarning, lintDisplayWidth},
+	}
+}
+
+// lintRule is the LintRule implementation used for all built-in rules.
+type lintRule struct {
+	name     string
+	severity Severity
+	check    func(schema *Schema, opts LintOptions) []Annotation
+}
+
+func (rule lintRule) Name() string {
+	return rule.name
+}
+
+func (rule lintRule) Check(schema *Schema, opts LintOptions) []Annotation {
+	annotations := rule.check(schema, opts)
+	for n := range annotations {
+		annotations[n].Rule = rule.name
+		annotations[n].Severity = rule.severity
+	}
+	return annotations
+}
+
+// tableAnnotation returns an Annotation for table t. If clauses are supplied,
+// they are combined into a single ALTER TABLE as the suggested fix.
+func tableAnnotation(t *Table, opts LintOptions, message string, clauses ...TableAlterClause) Annotation {
+	a := Annotation{
+		Key:     ObjectKey{Type: ObjectTypeTable, Name: t.Name},
+		Message: message,
+	}
+	if len(clauses) > 0 {
+		mods := StatementModifiers{Flavor: opts.Flavor}
+		clauseStrings := make([]string, len(clauses))
+		for n, clause := range clauses {
+			clauseStrings[n] = clause.Clause(mods)
+		}
+		a.SuggestedFix = fmt.Sprintf("%s %s", t.AlterStatement(), strings.Join(clauseStrings, ", "))
+	}
+	return a
+}
+
+func lintHasPrimaryKey(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	for _, t := range schema.Tables {
+		if t.PrimaryKey == nil {
+			annotations = append(annotations, tableAnnotation(t, opts, "Table does not have a primary key"))
+		}
+	}
+	return annotations
+}
+
+func lintDupeIndex(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	for _, t := range schema.Tables {
+		for n, idx := range t.SecondaryIndexes {
+			if idx.RedundantTo(t.PrimaryKey) {
+				message := fmt.Sprintf("Index %s is redundant to the primary key", EscapeIdentifier(idx.Name))
+				annotations = append(annotations, tableAnnotation(t, opts, message, DropIndex{Index: idx}))
+				continue
+			}
+			for otherN, other := range t.SecondaryIndexes {
+				// If two indexes are exactly equivalent, only flag the later one
+				if n == otherN || (otherN > n && idx.Equivalent(other)) {
+					continue
+				}
+				if idx.RedundantTo(other) {
+					message := fmt.Sprintf("Index %s is redundant to index %s", EscapeIdentifier(idx.Name), EscapeIdentifier(other.Name))
+					annotations = append(annotations, tableAnnotation(t, opts, message, DropIndex{Index: idx}))
+					break
+				}
+			}
+		}
+	}
+	return annotations
+}
+
+func lintForeignKeyIndex(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	for _, t := range schema.Tables {
+		indexes := t.SecondaryIndexes
+		if t.PrimaryKey != nil {
+			indexes = append([]*Index{t.PrimaryKey}, indexes...)
+		}
+		for _, fk := range t.ForeignKeys {
+			var supported bool
+			for _, idx := range indexes {
+				if indexSupportsColumns(idx, fk.ColumnNames) {
+					supported = true
+					break
+				}
+			}
+			if !supported {
+				idx := &Index{Name: fk.Name, Type: "BTREE"}
+				for _, col := range fk.ColumnNames {
+					idx.Parts = append(idx.Parts, IndexPart{ColumnName: col})
+				}
+				message := fmt.Sprintf("Foreign key %s is not supported by an index on its columns", EscapeIdentifier(fk.Name))
+				annotations = append(annotations, tableAnnotation(t, opts, message, AddIndex{Index: idx}))
+			}
+		}
+	}
+	return annotations
+}
+
+// indexSupportsColumns returns true if idx's leftmost parts are exactly the
+// supplied column names, in the same order, without any prefix lengths.
+func indexSupportsColumns(idx *Index, colNames []string) bool {
+	if idx.Type != "BTREE" || len(idx.Parts) < len(colNames) {
+		return false
+	}
+	for n, col := range colNames {
+		if idx.Parts[n].ColumnName != col || idx.Parts[n].PrefixLength > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func lintEngine(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	for _, t := range schema.Tables {
+		if t.Engine != "InnoDB" {
+			message := fmt.Sprintf("Table uses storage engine %s instead of InnoDB", t.Engine)
+			annotations = append(annotations, tableAnnotation(t, opts, message, ChangeStorageEngine{NewStorageEngine: "InnoDB"}))
+		}
+	}
+	return annotations
+}
+
+func lintCharSet(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	if len(opts.AllowedCharSets) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(opts.AllowedCharSets))
+	for _, cs := range opts.AllowedCharSets {
+		allowed[strings.ToLower(cs)] = true
+	}
+	preferred := opts.AllowedCharSets[0]
+	if schema.CharSet != "" && !allowed[strings.ToLower(schema.CharSet)] {
+		annotations = append(annotations, Annotation{
+			Key:          ObjectKey{Type: ObjectTypeDatabase, Name: schema.Name},
+			Message:      fmt.Sprintf("Schema default character set %s is not permitted", schema.CharSet),
+			SuggestedFix: schema.AlterStatement(preferred, ""),
+		})
+	}
+	for _, t := range schema.Tables {
+		badCharSets := []string{}
+		if !allowed[strings.ToLower(t.CharSet)] {
+			badCharSets = append(badCharSets, t.CharSet)
+		}
+		for _, col := range t.Columns {
+			if col.CharSet != "" && !allowed[strings.ToLower(col.CharSet)] && col.CharSet != t.CharSet {
+				badCharSets = append(badCharSets, col.CharSet)
+			}
+		}
+		if len(badCharSets) > 0 {
+			a := tableAnnotation(t, opts, fmt.Sprintf("Table uses character set %s, which is not permitted", strings.Join(badCharSets, ", ")))
+			a.SuggestedFix = fmt.Sprintf("%s CONVERT TO CHARACTER SET %s", t.AlterStatement(), preferred)
+			annotations = append(annotations, a)
+		}
+	}
+	return annotations
+}
+
+func lintHasFloat(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	for _, t := range schema.Tables {
+		for _, col := range t.Columns {
+			colType := strings.ToLower(col.TypeInDB)
+			if strings.HasPrefix(colType, "float") || strings.HasPrefix(colType, "double") || strings.HasPrefix(colType, "real") {
+				message := fmt.Sprintf("Column %s uses approximate type %s; consider using DECIMAL for exact values", EscapeIdentifier(col.Name), col.TypeInDB)
+				annotations = append(annotations, tableAnnotation(t, opts, message))
+			}
+		}
+	}
+	return annotations
+}
+
+func lintDefiner(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	if len(opts.AllowedDefiners) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(opts.AllowedDefiners))
+	for _, definer := range opts.AllowedDefiners {
+		allowed[strings.Replace(definer, "`", "", -1)] = true
+	}
+	for _, r := range schema.Routines {
+		if !allowed[strings.Replace(r.Definer, "`", "", -1)] {
+			annotations = append(annotations, Annotation{
+				Key:     ObjectKey{Type: r.Type, Name: r.Name},
+				Message: fmt.Sprintf("%s has definer %s, which is not permitted", r.Type.Caps(), r.Definer),
+			})
+		}
+	}
+	return annotations
+}
+
+// defaultDisplayWidths maps integer types to their default display widths,
+// for signed and unsigned variants respectively.
+var defaultDisplayWidths = map[string][2]int{
+	"tinyint":   {4, 3},
+	"smallint":  {6, 5},
+	"mediumint": {9, 8},
+	"int":       {11, 10},
+	"bigint":    {20, 20},
+}
+
+func lintDisplayWidth(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	for _, t := range schema.Tables {
+		for _, col := range t.Columns {
+			matches := reDisplayWidth.FindStringSubmatch(strings.ToLower(col.TypeInDB))
+			if matches == nil || matches[0] != strings.ToLower(col.TypeInDB) || (matches[1] == "tinyint" && matches[2] == "1") {
+				continue
+			}
+			unsigned, zerofill := matches[3] != "", matches[4] != ""
+			width, _ := strconv.Atoi(matches[2])
+			defaultWidth := defaultDisplayWidths[matches[1]][0]
+			if unsigned {
+				defaultWidth = defaultDisplayWidths[matches[1]][1]
+			}
+			var message string
+			if zerofill {
+				message = fmt.Sprintf("Column %s uses ZEROFILL, which is deprecated", EscapeIdentifier(col.Name))
+			} else if width != defaultWidth {
+				message = fmt.Sprintf("Column %s has display width %d, which does not affect the range of values and is deprecated", EscapeIdentifier(col.Name), width)
+			} else {
+				continue
+			}
+			// ModifyColumn.Clause intentionally suppresses changes that only remove
+			// display width, so the MODIFY COLUMN is built directly here
+			newCol := *col
+			newCol.TypeInDB = matches[1] + matches[3]
+			a := tableAnnotation(t, opts, message)
+			a.SuggestedFix = fmt.Sprintf("%s MODIFY COLUMN %s", t.AlterStatement(), newCol.Definition(opts.Flavor, t))
+			annotations = append(annotations, a)
+		}
+	}
+	return annotations
+}
@@ -0,0 +1,143 @@
+package tengo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintDefaultRules(t *testing.T) {
+	t1, t2, t3 := aTable(1), anotherTable(), supportedTable()
+	schema := aSchema("s1", &t1, &t2, &t3)
+	p, f := aProc("latin1_swedish_ci", ""), aFunc("latin1_swedish_ci", "")
+	schema.Routines = []*Routine{&p, &f}
+	opts := LintOptions{Flavor: FlavorMySQL57}
+	if annotations := Lint(&schema, opts); len(annotations) > 0 {
+		t.Fatalf("Expected no annotations for fixture schema, instead found %v", annotations)
+	}
+
+	// Introduce one problem per default rule
+	t1.Engine = "MyISAM"
+	t2.SecondaryIndexes = append(t2.SecondaryIndexes, &Index{
+		Name:  "actor_dupe",
+		Parts: []IndexPart{{ColumnName: "actor_id"}},
+		Type:  "BTREE",
+	})
+	t3.PrimaryKey = nil
+	t3.Columns[2].TypeInDB = "int(5) unsigned"
+	t3.Columns = append(t3.Columns, &Column{Name: "score", TypeInDB: "double"})
+	t3.ForeignKeys = []*ForeignKey{{
+		Name:                  "user_fk",
+		ColumnNames:           []string{"user_id"},
+		ReferencedTableName:   "users",
+		ReferencedColumnNames: []string{"id"},
+		UpdateRule:            "RESTRICT",
+		DeleteRule:            "RESTRICT",
+	}}
+	opts.AllowedCharSets = []string{"utf8mb4", "latin1"}
+	opts.AllowedDefiners = []string{"someone@localhost"}
+
+	expected := map[string]string{
+		"engine":        "ALTER TABLE `actor` ENGINE=InnoDB",
+		"charset":       "ALTER TABLE `actor` CONVERT TO CHARACTER SET utf8mb4",
+		"dupe-index":    "ALTER TABLE `actor_in_film` DROP KEY `actor_dupe`",
+		"has-pk":        "",
+		"display-width": "ALTER TABLE `followed_posts` MODIFY COLUMN `subscribed_at` int unsigned DEFAULT NULL",
+		"has-float":     "",
+		"fk-index":      "ALTER TABLE `followed_posts` ADD KEY `user_fk` (`user_id`)",
+		"definer":       "",
+	}
+	annotations := Lint(&schema, opts)
+	seen := make(map[string]int)
+	for _, a := range annotations {
+		seen[a.Rule]++
+		if fix, ok := expected[a.Rule]; !ok {
+			t.Errorf("Unexpected annotation %s", a)
+		} else if a.SuggestedFix != fix {
+			t.Errorf("Unexpected suggested fix for %s: expected %q, found %q", a, fix, a.SuggestedFix)
+		}
+		if a.Severity == "" || a.Message == "" || a.Key.Name == "" {
+			t.Errorf("Annotation missing fields: %+v", a)
+		}
+	}
+	for rule := range expected {
+		expectCount := 1
+		if rule == "definer" {
+			expectCount = 2 // both routines
+		}
+		if seen[rule] != expectCount {
+			t.Errorf("Expected rule %s to yield %d annotations, instead found %d", rule, expectCount, seen[rule])
+		}
+	}
+
+	// Test severity overrides
+	opts.Severity = map[string]Severity{
+		"definer": SeverityIgnore,
+		"has-pk":  SeverityError,
+	}
+	for _, a := range Lint(&schema, opts) {
+		if a.Rule == "definer" {
+			t.Errorf("Expected definer rule to be ignored, but found %s", a)
+		} else if a.Rule == "has-pk" && a.Severity != SeverityError {
+			t.Errorf("Expected has-pk severity to be overridden, but found %s", a)
+		} else if a.Rule == "engine" && a.Severity != SeverityWarning {
+			t.Errorf("Expected engine severity to be default, but found %s", a)
+		}
+	}
+}
+
+type testLintRule struct{}
+
+func (testLintRule) Name() string { return "no-comment" }
+
+func (testLintRule) Check(schema *Schema, opts LintOptions) (annotations []Annotation) {
+	for _, table := range schema.Tables {
+		if table.Comment == "" {
+			annotations = append(annotations, Annotation{
+				Key:     ObjectKey{Type: ObjectTypeTable, Name: table.Name},
+				Message: "Table has no comment",
+			})
+		}
+	}
+	return annotations
+}
+
+func TestLintCustomRule(t *testing.T) {
+	t1, t2 := aTable(1), anotherTable()
+	t2.Comment = "hello world"
+	schema := aSchema("s1", &t1, &t2)
+	annotations := Lint(&schema, LintOptions{}, testLintRule{})
+	if len(annotations) != 1 {
+		t.Fatalf("Expected 1 annotation, instead found %d", len(annotations))
+	}
+	a := annotations[0]
+	if a.Rule != "no-comment" || a.Severity != SeverityWarning || a.Key.Name != t1.Name {
+		t.Errorf("Unexpected annotation: %+v", a)
+	}
+	if str := a.String(); !strings.Contains(str, "no-comment") || !strings.Contains(str, "warning") {
+		t.Errorf("Unexpected annotation string: %s", str)
+	}
+}
+
+func TestLintDisplayWidth(t *testing.T) {
+	cases := map[string]bool{
+		"int(11)":                      false,
+		"int(10) unsigned":             false,
+		"int(10)":                      true,
+		"tinyint(1)":                   false,
+		"tinyint(1) unsigned":          false,
+		"bigint(20) unsigned zerofill": true,
+		"smallint(3)":                  true,
+		"int":                          false,
+		"varchar(20)":                  false,
+		"point":                        false,
+	}
+	for colType, expectAnnotation := range cases {
+		table := aTable(1)
+		table.Columns[0].TypeInDB = colType
+		schema := aSchema("s1", &table)
+		annotations := lintDisplayWidth(&schema, LintOptions{})
+		if expectAnnotation != (len(annotations) == 1) {
+			t.Errorf("Type %s: expected annotation=%t, instead found %d annotations", colType, expectAnnotation, len(annotations))
+		}
+	}
+}
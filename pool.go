@@ -0,0 +1,212 @@
+package tengo
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PoolPolicy controls the lifecycle of an Instance's cached connection pools,
+// i.e. those returned by CachedConnectionPool or Connect. Zero-value fields
+// impose no limit. Pools obtained from ConnectionPool are not cached, and are
+// not affected by the policy. This includes the pools used internally by
+// schema introspection, which have their own fixed connection budget.
+type PoolPolicy struct {
+	// MaxPools limits the number of cached pools. When a new pool would exceed
+	// this, the least-recently-used pools are closed.
+	MaxPools int
+
+	// MaxIdleTime causes cached pools which have not been requested for this
+	// long to be closed. This is checked whenever a cached pool is requested, or
+	// upon calling EvictIdlePools.
+	MaxIdleTime time.Duration
+
+	// MaxOpenConns is a budget of open connections across all cached pools. It
+	// is divided evenly among the pools, with each permitted at least one
+	// connection, and rebalanced whenever a pool is opened or closed. Since
+	// uncached pools are not counted, this is not a cap on the total number of
+	// connections made by the Instance.
+	MaxOpenConns int
+
+	// OnOpen and OnClose, if non-nil, are called whenever a cached pool is
+	// opened or closed, for example to export metrics. OnClose receives the final
+	// statistics of the pool. These are called with the Instance's lock held, so
+	// they must not call methods of the Instance.
+	OnOpen  func(key string)
+	OnClose func(key string, stats sql.DBStats)
+}
+
+// PoolStats describes a cached connection pool.
+type PoolStats struct {
+	Key           string // in format "schema?params"
+	DefaultSchema string
+	Params        string
+	LastUsed      time.Time // last time the pool was returned by CachedConnectionPool
+	sql.DBStats
+}
+
+// SetPoolPolicy configures limits on the instance's cached connection pools.
+// The policy takes effect immediately, closing any pools that exceed it.
+// Pools currently in use are never closed by the policy. This includes pools
+// with at least one connection checked out, as well as pools being used by any
+// method of the Instance. However, a pool returned to the caller by
+// CachedConnectionPool is not protected between queries, so callers should
+// avoid retaining one while a policy is in effect, and instead call
+// CachedConnectionPool again as needed.
+func (instance *Instance) SetPoolPolicy(policy PoolPolicy) {
+	instance.m.Lock()
+	defer instance.m.Unlock()
+	if instance.poolPolicy.MaxOpenConns > 0 && policy.MaxOpenConns <= 0 {
+		instance.setPoolConns(instance.maxPoolConns()) // budget removed; restore default limit
+	}
+	instance.poolPolicy = policy
+	instance.enforcePoolPolicy("")
+}
+
+// PoolStats returns statistics for each of the instance's cached connection
+// pools, sorted by key.
+func (instance *Instance) PoolStats() []PoolStats {
+	instance.m.Lock()
+	defer instance.m.Unlock()
+	result := make([]PoolStats, 0, len(instance.connectionPool))
+	for key, db := range instance.connectionPool {
+		stats := PoolStats{
+			Key:      key,
+			LastUsed: instance.poolLastUsed[key],
+			DBStats:  db.Stats(),
+		}
+		stats.DefaultSchema, stats.Params = splitPoolKey(key)
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// EvictIdlePools closes any cached pools which have been idle longer than the
+// pool policy's MaxIdleTime, returning the number of pools closed. Callers may
+// wish to run this periodically, since otherwise idle pools are only evicted
+// when another cached pool is requested.
+func (instance *Instance) EvictIdlePools() int {
+	instance.m.Lock()
+	defer instance.m.Unlock()
+	before := len(instance.connectionPool)
+	instance.enforcePoolPolicy("")
+	return before - len(instance.connectionPool)
+}
+
+// touchPool records use of the cached pool with the supplied key. The caller
+// must hold instance.m.
+func (instance *Instance) touchPool(key string) {
+	if instance.poolLastUsed == nil {
+		instance.poolLastUsed = make(map[string]time.Time)
+	}
+	instance.poolLastUsed[key] = time.Now()
+}
+
+// leasePool protects the cached pool with the supplied key from eviction by
+// the pool policy, until the returned release function is called. The caller
+// must hold instance.m, but must not hold it when calling release.
+func (instance *Instance) leasePool(key string) (release func()) {
+	if instance.poolLeases == nil {
+		instance.poolLeases = make(map[string]int)
+	}
+	instance.poolLeases[key]++
+	return func() {
+		instance.m.Lock()
+		defer instance.m.Unlock()
+		if instance.poolLeases[key]--; instance.poolLeases[key] <= 0 {
+			delete(instance.poolLeases, key)
+		}
+	}
+}
+
+// closePool closes and removes the cached pool with the supplied key. The
+// caller must hold instance.m.
+func (instance *Instance) closePool(key string) {
+	db, ok := instance.connectionPool[key]
+	if !ok {
+		return
+	}
+	db.Close()
+	delete(instance.connectionPool, key)
+	delete(instance.poolLastUsed, key)
+	if instance.poolPolicy.OnClose != nil {
+		instance.poolPolicy.OnClose(key, db.Stats())
+	}
+}
+
+// enforcePoolPolicy closes idle and least-recently-used cached pools as needed
+// to comply with the pool policy, and then rebalances the open connection
+// budget among the remaining pools. The pool with key keep is never closed.
+// The caller must hold instance.m.
+func (instance *Instance) enforcePoolPolicy(keep string) {
+	policy := instance.poolPolicy
+	evictable := func(key string) bool {
+		return key != keep && instance.poolLeases[key] == 0 && instance.connectionPool[key].Stats().InUse == 0
+	}
+	if policy.MaxIdleTime > 0 {
+		for key := range instance.connectionPool {
+			if time.Since(instance.poolLastUsed[key]) > policy.MaxIdleTime && evictable(key) {
+				instance.closePool(key)
+			}
+		}
+	}
+	for policy.MaxPools > 0 && len(instance.connectionPool) > policy.MaxPools {
+		var lruKey string
+		var lruTime time.Time
+		for key := range instance.connectionPool {
+			if lastUsed := instance.poolLastUsed[key]; evictable(key) && (lruKey == "" || lastUsed.Before(lruTime)) {
+				lruKey, lruTime = key, lastUsed
+			}
+		}
+		if lruKey == "" {
+			break // all pools in use; permit exceeding MaxPools temporarily
+		}
+		instance.closePool(lruKey)
+	}
+
+	if policy.MaxOpenConns > 0 && len(instance.connectionPool) > 0 {
+		limit := instance.maxPoolConns()
+		share := policy.MaxOpenConns / len(instance.connectionPool)
+		if share < 1 {
+			share = 1
+		}
+		if limit == 0 || share < limit {
+			limit = share
+		}
+		instance.setPoolConns(limit)
+	}
+}
+
+// setPoolConns sets the max open conns of all cached pools. The caller must
+// hold instance.m.
+func (instance *Instance) setPoolConns(limit int) {
+	for _, db := range instance.connectionPool {
+		db.SetMaxOpenConns(limit)
+	}
+}
+
+// maxPoolConns returns the max open conns for any one connection pool,
+// ensuring it is less than any limit set on the database side either globally
+// or for this user; or 0 if there is no limit.
+func (instance *Instance) maxPoolConns() int {
+	if instance.maxUserConns <= 0 {
+		return 0
+	} else if instance.maxUserConns < 12 {
+		return 2
+	}
+	return instance.maxUserConns - 10
+}
+
+// splitPoolKey splits a connection pool key into its default schema and
+// params.
+func splitPoolKey(key string) (defaultSchema, params string) {
+	tokens := strings.SplitN(key, "?", 2)
+	if len(tokens) < 2 {
+		return tokens[0], ""
+	}
+	return tokens[0], tokens[1]
+}
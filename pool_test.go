@@ -0,0 +1,234 @@
+package tengo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// addFakePool adds a cached pool to instance without connecting, with the
+// supplied last-used time.
+func addFakePool(t *testing.T, instance *Instance, key string, lastUsed time.Time) *sqlx.DB {
+	t.Helper()
+	db, err := sqlx.Open("mysql", instance.BaseDSN)
+	if err != nil {
+		t.Fatalf("Unexpected error from sqlx.Open: %v", err)
+	}
+	instance.m.Lock()
+	instance.connectionPool[key] = db
+	instance.touchPool(key)
+	instance.poolLastUsed[key] = lastUsed
+	instance.m.Unlock()
+	return db
+}
+
+func TestPoolPolicy(t *testing.T) {
+	instance, err := NewInstance("mysql", "root:fakepw@tcp(127.0.0.1:1)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	now := time.Now()
+	addFakePool(t, instance, "?", now.Add(-time.Hour))
+	addFakePool(t, instance, "testing?", now.Add(-time.Minute))
+	addFakePool(t, instance, "testing?foo=bar", now.Add(-time.Second))
+	addFakePool(t, instance, "other?", now)
+
+	stats := instance.PoolStats()
+	if len(stats) != 4 || stats[2].Key != "testing?" || stats[3].DefaultSchema != "testing" || stats[3].Params != "foo=bar" {
+		t.Fatalf("Unexpected result from PoolStats: %+v", stats)
+	}
+
+	var closed []string
+	onClose := func(key string, stats sql.DBStats) {
+		closed = append(closed, key)
+	}
+	instance.SetPoolPolicy(PoolPolicy{MaxIdleTime: 30 * time.Minute, OnClose: onClose})
+	if !reflect.DeepEqual(closed, []string{"?"}) {
+		t.Errorf("Expected idle pool to be closed, instead closed %v", closed)
+	}
+
+	closed = nil
+	instance.SetPoolPolicy(PoolPolicy{MaxPools: 1, OnClose: onClose})
+	if !reflect.DeepEqual(closed, []string{"testing?", "testing?foo=bar"}) {
+		t.Errorf("Expected least-recently-used pools to be closed in order, instead closed %v", closed)
+	}
+	if stats := instance.PoolStats(); len(stats) != 1 || stats[0].Key != "other?" {
+		t.Errorf("Unexpected result from PoolStats: %+v", stats)
+	}
+
+	addFakePool(t, instance, "testing?", now)
+	addFakePool(t, instance, "foo?", now)
+	instance.SetPoolPolicy(PoolPolicy{MaxOpenConns: 7})
+	for _, s := range instance.PoolStats() {
+		if s.MaxOpenConnections != 2 {
+			t.Errorf("Expected pool %s to have max open conns of 2, instead found %d", s.Key, s.MaxOpenConnections)
+		}
+	}
+	instance.SetPoolPolicy(PoolPolicy{})
+	for _, s := range instance.PoolStats() {
+		if s.MaxOpenConnections != 0 {
+			t.Errorf("Expected pool %s to have unlimited max open conns, instead found %d", s.Key, s.MaxOpenConnections)
+		}
+	}
+
+	// Leased pools are not evicted until released
+	instance.m.Lock()
+	release := instance.leasePool("foo?")
+	instance.m.Unlock()
+	instance.SetPoolPolicy(PoolPolicy{MaxIdleTime: time.Millisecond})
+	time.Sleep(5 * time.Millisecond)
+	if n := instance.EvictIdlePools(); n != 2 {
+		t.Errorf("Expected EvictIdlePools to close 2 pools, instead closed %d", n)
+	}
+	release()
+	if n := instance.EvictIdlePools(); n != 1 {
+		t.Errorf("Expected EvictIdlePools to close 1 pool, instead closed %d", n)
+	}
+	if len(instance.poolLeases) != 0 {
+		t.Errorf("Expected no leases to remain, instead found %v", instance.poolLeases)
+	}
+}
+
+// stubConnector is a driver.Connector whose connections return a single row
+// with a single column, value 1, for every query.
+type stubConnector struct{}
+
+func (sc stubConnector) Connect(ctx context.Context) (driver.Conn, error) { return stubConn{}, nil }
+func (sc stubConnector) Driver() driver.Driver                            { return mysql.MySQLDriver{} }
+
+type stubConn struct{}
+
+func (sc stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{}, nil }
+func (sc stubConn) Close() error                              { return nil }
+func (sc stubConn) Begin() (driver.Tx, error)                 { return nil, errors.New("Not supported") }
+
+type stubStmt struct{}
+
+func (ss stubStmt) Close() error                                    { return nil }
+func (ss stubStmt) NumInput() int                                   { return -1 }
+func (ss stubStmt) Exec(args []driver.Value) (driver.Result, error) { return driver.ResultNoRows, nil }
+func (ss stubStmt) Query(args []driver.Value) (driver.Rows, error)  { return &stubRows{}, nil }
+
+type stubRows struct {
+	done bool
+}
+
+func (sr *stubRows) Columns() []string { return []string{"value"} }
+func (sr *stubRows) Close() error      { return nil }
+func (sr *stubRows) Next(dest []driver.Value) error {
+	if sr.done {
+		return io.EOF
+	}
+	sr.done = true
+	dest[0] = []byte("1")
+	return nil
+}
+
+// TestPoolPolicyConcurrentEviction confirms that Instance methods using cached
+// pools are not affected by another goroutine's requests evicting those pools.
+func TestPoolPolicyConcurrentEviction(t *testing.T) {
+	// Any attempt to open a new pool fails immediately, so that only the stub
+	// pools added below are usable
+	instance, err := NewInstance("mysql", "root@unix(/nonexistent/mysql.sock)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	instance.valid = true
+	addStubPool := func(key string) {
+		instance.m.Lock()
+		defer instance.m.Unlock()
+		if _, ok := instance.connectionPool[key]; !ok {
+			instance.connectionPool[key] = sqlx.NewDb(sql.OpenDB(stubConnector{}), "mysql")
+			instance.touchPool(key)
+		}
+	}
+	addStubPool("?")
+	addStubPool("other?")
+	instance.SetPoolPolicy(PoolPolicy{MaxPools: 1})
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// Errors from opening a new pool after an eviction are expected, but
+				// a pool must never be closed while a method is using it
+				if _, err := instance.ReplicationLag("SELECT 1"); err != nil && strings.Contains(err.Error(), "database is closed") {
+					t.Errorf("Pool was closed while in use: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		// Requesting the other pool evicts the workers' pool unless it is in use,
+		// and vice versa; replace whichever was evicted. Errors are expected if the
+		// workers evict the other pool before it is requested.
+		addStubPool("other?")
+		instance.CachedConnectionPool("other", "")
+		addStubPool("?")
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestSplitPoolKey(t *testing.T) {
+	cases := map[string][2]string{
+		"?":               {"", ""},
+		"testing?":        {"testing", ""},
+		"testing?foo=bar": {"testing", "foo=bar"},
+		"?a=b&c=d":        {"", "a=b&c=d"},
+		"nokey":           {"nokey", ""},
+	}
+	for key, expected := range cases {
+		if schema, params := splitPoolKey(key); schema != expected[0] || params != expected[1] {
+			t.Errorf("splitPoolKey(%q): expected %q, %q; found %q, %q", key, expected[0], expected[1], schema, params)
+		}
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstancePoolPolicy(t *testing.T) {
+	inst, err := NewInstance("mysql", s.d.DSN())
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	defer inst.CloseAll()
+	var opened int
+	inst.SetPoolPolicy(PoolPolicy{MaxPools: 1, MaxOpenConns: 3, OnOpen: func(key string) { opened++ }})
+	db1, err := inst.CachedConnectionPool("testing", "")
+	if err != nil {
+		t.Fatalf("Unexpected error from CachedConnectionPool: %v", err)
+	}
+	if _, err := inst.CachedConnectionPool("", ""); err != nil {
+		t.Fatalf("Unexpected error from CachedConnectionPool: %v", err)
+	}
+	if opened != 2 {
+		t.Errorf("Expected OnOpen to be called twice, instead called %d times", opened)
+	}
+	stats := inst.PoolStats()
+	if len(stats) != 1 || stats[0].DefaultSchema != "" || stats[0].MaxOpenConnections != 3 {
+		t.Errorf("Unexpected result from PoolStats: %+v", stats)
+	}
+	if err := db1.Ping(); err == nil {
+		t.Error("Expected evicted pool to be closed, but Ping succeeded")
+	}
+}
@@ -0,0 +1,176 @@
+package tengo
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/VividCortex/mysqlerr"
+)
+
+// Process represents a single session (connection or system thread) on an
+// instance, as reported by the processlist.
+type Process struct {
+	ID      uint64
+	User    string
+	Host    string // client host and port, e.g. "10.0.0.1:51234"
+	DB      string // default database, or "" if none
+	Command string // e.g. "Query", "Sleep", "Binlog Dump"
+	Time    time.Duration
+	State   string // thread state, or "" if none
+	Info    string // currently-executing statement, or "" if none
+	QueryID uint64 // MariaDB only; ID of currently-executing statement, for use with KillQueryID
+}
+
+// Idle returns true if the session is not currently executing anything.
+func (p *Process) Idle() bool {
+	return p.Command == "Sleep"
+}
+
+// ProcessFilter restricts which sessions are returned by Instance.Processlist.
+// Zero-value fields are not used for filtering.
+type ProcessFilter struct {
+	User          string        // Only include sessions of this user
+	Host          string        // Only include sessions from this client host; port is ignored unless included
+	DB            string        // Only include sessions with this default database
+	Command       string        // Only include sessions with this Command, e.g. "Query"
+	MinTime       time.Duration // Only include sessions whose current Command has been running at least this long
+	ExcludeIdle   bool          // Omit sessions with Command "Sleep"
+	ExcludeSystem bool          // Omit replication, event scheduler, and other system threads
+}
+
+// Processlist returns the sessions on the instance matching filter, ordered by
+// ID. The connection used to run the query is always omitted. Unless the user
+// has the PROCESS privilege, only the user's own sessions are visible.
+func (instance *Instance) Processlist(filter ProcessFilter) ([]*Process, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	where, args := filter.clause()
+	queryIDCol := "0"
+	if instance.Flavor().Vendor == VendorMariaDB {
+		queryIDCol = "query_id"
+	}
+	query := `
+		SELECT id AS id, user AS user, host AS host, db AS db,
+		       command AS command, time AS time, state AS state, info AS info,
+		       %s AS query_id
+		FROM   information_schema.processlist
+		WHERE  %s
+		ORDER BY id`
+	query = fmt.Sprintf(query, queryIDCol, where)
+	var raw []struct {
+		ID      uint64         `db:"id"`
+		User    string         `db:"user"`
+		Host    string         `db:"host"`
+		DB      sql.NullString `db:"db"`
+		Command string         `db:"command"`
+		Time    int64          `db:"time"`
+		State   sql.NullString `db:"state"`
+		Info    sql.NullString `db:"info"`
+		QueryID uint64         `db:"query_id"`
+	}
+	if err := db.Select(&raw, query, args...); err != nil {
+		return nil, fmt.Errorf("Error querying processlist on %s: %s", instance, err)
+	}
+	result := make([]*Process, len(raw))
+	for n, r := range raw {
+		result[n] = &Process{
+			ID:      r.ID,
+			User:    r.User,
+			Host:    r.Host,
+			DB:      r.DB.String,
+			Command: r.Command,
+			Time:    time.Duration(r.Time) * time.Second,
+			State:   r.State.String,
+			Info:    r.Info.String,
+			QueryID: r.QueryID,
+		}
+	}
+	return result, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// clause returns a WHERE clause, and corresponding args, for querying
+// information_schema.processlist using the filter.
+func (filter ProcessFilter) clause() (string, []interface{}) {
+	conds := []string{"id != CONNECTION_ID()"}
+	args := []interface{}{}
+	if filter.User != "" {
+		conds = append(conds, "user = ?")
+		args = append(args, filter.User)
+	}
+	if filter.Host != "" {
+		conds = append(conds, "(host = ? OR host LIKE ?)")
+		args = append(args, filter.Host, likeEscaper.Replace(filter.Host)+":%")
+	}
+	if filter.DB != "" {
+		conds = append(conds, "db = ?")
+		args = append(args, filter.DB)
+	}
+	if filter.Command != "" {
+		conds = append(conds, "command = ?")
+		args = append(args, filter.Command)
+	}
+	if filter.MinTime > 0 {
+		conds = append(conds, "time >= ?")
+		args = append(args, int64(filter.MinTime/time.Second))
+	}
+	if filter.ExcludeIdle {
+		conds = append(conds, "command != 'Sleep'")
+	}
+	if filter.ExcludeSystem {
+		conds = append(conds, "user NOT IN ('system user', 'event_scheduler') AND command NOT IN ('Daemon', 'Binlog Dump', 'Binlog Dump GTID')")
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// KillQuery terminates the statement currently being executed by the session
+// with the supplied connection ID, leaving the session connected. If the user
+// lacks privileges to kill the session, and the instance provides the Amazon
+// RDS mysql.rds_kill_query procedure, that procedure is used instead. Any
+// returned error is the unwrapped database error, permitting use of
+// IsAccessError or IsDatabaseError(err, mysqlerr.ER_NO_SUCH_THREAD).
+func (instance *Instance) KillQuery(connectionID uint64) error {
+	return instance.kill("KILL QUERY", "mysql.rds_kill_query", connectionID)
+}
+
+// KillConnection terminates the session with the supplied connection ID,
+// rolling back any open transaction. If the user lacks privileges to kill the
+// session, and the instance provides the Amazon RDS mysql.rds_kill procedure,
+// that procedure is used instead. Any returned error is the unwrapped database
+// error, permitting use of IsAccessError or
+// IsDatabaseError(err, mysqlerr.ER_NO_SUCH_THREAD).
+func (instance *Instance) KillConnection(connectionID uint64) error {
+	return instance.kill("KILL CONNECTION", "mysql.rds_kill", connectionID)
+}
+
+// KillQueryID terminates the statement with the supplied query ID, as
+// reported by Process.QueryID. This is only supported in MariaDB, and avoids
+// the race inherent in KillQuery, where the session may have moved on to a
+// different statement by the time the kill is issued.
+func (instance *Instance) KillQueryID(queryID uint64) error {
+	if instance.Flavor().Vendor != VendorMariaDB {
+		return fmt.Errorf("KillQueryID is not supported by %s", instance.Flavor())
+	}
+	return instance.kill("KILL QUERY ID", "", queryID)
+}
+
+func (instance *Instance) kill(statement, rdsProcedure string, id uint64) error {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return err
+	}
+	defer release()
+	_, err = db.Exec(fmt.Sprintf("%s %d", statement, id))
+	if rdsProcedure != "" && IsDatabaseError(err, mysqlerr.ER_KILL_DENIED_ERROR) {
+		if _, rdsErr := db.Exec(fmt.Sprintf("CALL %s(?)", rdsProcedure), id); rdsErr == nil {
+			return nil
+		}
+	}
+	return err
+}
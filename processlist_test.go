@@ -0,0 +1,111 @@
+package tengo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VividCortex/mysqlerr"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestProcessFilterClause(t *testing.T) {
+	where, args := ProcessFilter{}.clause()
+	if where != "id != CONNECTION_ID()" || len(args) != 0 {
+		t.Errorf("Unexpected result from zero-value ProcessFilter: %q, %v", where, args)
+	}
+
+	filter := ProcessFilter{
+		User:        "app",
+		Host:        "10.0.0.1",
+		DB:          "testing",
+		MinTime:     2500 * time.Millisecond,
+		ExcludeIdle: true,
+	}
+	where, args = filter.clause()
+	expectWhere := "id != CONNECTION_ID() AND user = ? AND (host = ? OR host LIKE ?) AND db = ? AND time >= ? AND command != 'Sleep'"
+	expectArgs := []interface{}{"app", "10.0.0.1", "10.0.0.1:%", "testing", int64(2)}
+	if where != expectWhere {
+		t.Errorf("Unexpected WHERE clause: %q", where)
+	}
+	if !reflect.DeepEqual(args, expectArgs) {
+		t.Errorf("Unexpected args: %v", args)
+	}
+
+	where, args = ProcessFilter{Host: "my_host%"}.clause()
+	if expected := `my\_host\%:%`; len(args) != 2 || args[1] != expected {
+		t.Errorf("Expected LIKE arg to be escaped as %q, instead found %v", expected, args)
+	}
+}
+
+func TestIsAccessErrorKillDenied(t *testing.T) {
+	err := &mysql.MySQLError{Number: mysqlerr.ER_KILL_DENIED_ERROR, Message: "You are not owner of thread 123"}
+	if !IsAccessError(err) {
+		t.Error("Expected kill denied error to be considered an access error, but it was not")
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceProcesslist(t *testing.T) {
+	db, err := s.d.ConnectionPool("testing", "")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Unable to obtain connection: %v", err)
+	}
+	defer conn.Close()
+	var connID uint64
+	if err := conn.QueryRowContext(context.Background(), "SELECT CONNECTION_ID()").Scan(&connID); err != nil {
+		t.Fatalf("Unable to obtain connection ID: %v", err)
+	}
+
+	procs, err := s.d.Processlist(ProcessFilter{DB: "testing", Command: "Sleep"})
+	if err != nil {
+		t.Fatalf("Unexpected error from Processlist: %v", err)
+	} else if len(procs) != 1 || procs[0].ID != connID || !procs[0].Idle() || procs[0].DB != "testing" {
+		t.Fatalf("Unexpected result from Processlist: %+v", procs)
+	}
+	if procs, err := s.d.Processlist(ProcessFilter{DB: "testing", ExcludeIdle: true}); err != nil || len(procs) != 0 {
+		t.Errorf("Unexpected result from Processlist with ExcludeIdle: %+v, %v", procs, err)
+	}
+
+	// Run a long query in the background, confirm it shows up, and then kill it
+	done := make(chan error)
+	go func() {
+		_, err := conn.ExecContext(context.Background(), "SELECT SLEEP(30)")
+		done <- err
+	}()
+	var running []*Process
+	for attempt := 0; attempt < 50 && len(running) == 0; attempt++ {
+		time.Sleep(20 * time.Millisecond)
+		if running, err = s.d.Processlist(ProcessFilter{DB: "testing", ExcludeIdle: true}); err != nil {
+			t.Fatalf("Unexpected error from Processlist: %v", err)
+		}
+	}
+	if len(running) != 1 || running[0].ID != connID || running[0].Info != "SELECT SLEEP(30)" {
+		t.Fatalf("Unexpected result from Processlist: %+v", running)
+	}
+	if err := s.d.KillQuery(connID); err != nil {
+		t.Fatalf("Unexpected error from KillQuery: %v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for killed query to return")
+	}
+
+	if err := s.d.KillConnection(connID); err != nil {
+		t.Errorf("Unexpected error from KillConnection: %v", err)
+	}
+	if err := s.d.KillConnection(connID); !IsDatabaseError(err, mysqlerr.ER_NO_SUCH_THREAD) {
+		t.Errorf("Expected KillConnection of nonexistent connection to return ER_NO_SUCH_THREAD, instead found %v", err)
+	}
+	if s.d.Flavor().Vendor != VendorMariaDB {
+		if err := s.d.KillQueryID(1); err == nil {
+			t.Error("Expected KillQueryID to return an error on non-MariaDB, but err was nil")
+		}
+	}
+}
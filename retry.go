@@ -0,0 +1,115 @@
+package tengo
+
+import (
+	"context"
+	"time"
+)
+
+// RetryPolicy controls how operations are retried upon encountering transient
+// errors, as classified by ClassifyError. The zero value does not retry.
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts, including the first; values below 2 disable retries
+	InitialBackoff time.Duration // Delay before the first retry
+	MaxBackoff     time.Duration // Upper bound on delay between retries; no bound if 0
+	Multiplier     float64       // Backoff growth factor per retry; 2 if less than 1
+	Classes        []ErrorClass  // Error classes to retry; all non-fatal classes if empty
+}
+
+// DefaultRetryPolicy is a reasonable policy for most environments: up to 3
+// attempts, backing off 250ms and then 500ms, for any transient error.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 250 * time.Millisecond,
+	MaxBackoff:     5 * time.Second,
+	Multiplier:     2,
+}
+
+// Do calls fn, retrying it according to the policy if it returns a retryable
+// error. The last error from fn is returned if attempts are exhausted. If ctx
+// is cancelled while waiting to retry, ctx.Err() is returned.
+func (rp RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	return rp.do(ctx, true, fn)
+}
+
+// do calls fn, retrying it according to the policy. If idempotent is false,
+// errors of class ErrorClassConnectionLost are not retried, since the server
+// may have already executed the statement before the connection was lost.
+func (rp RetryPolicy) do(ctx context.Context, idempotent bool, fn func() error) error {
+	backoff := rp.InitialBackoff
+	multiplier := rp.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= rp.MaxAttempts || !rp.retries(ClassifyError(err), idempotent) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = time.Duration(float64(backoff) * multiplier)
+		if rp.MaxBackoff > 0 && backoff > rp.MaxBackoff {
+			backoff = rp.MaxBackoff
+		}
+	}
+}
+
+// retries returns true if the policy permits retrying errors of class.
+func (rp RetryPolicy) retries(class ErrorClass, idempotent bool) bool {
+	if class == ErrorClassFatal || (class == ErrorClassConnectionLost && !idempotent) {
+		return false
+	} else if len(rp.Classes) == 0 {
+		return true
+	}
+	for _, c := range rp.Classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// withClass returns a copy of the policy which also retries errors of class,
+// making at least minAttempts attempts in total. If the policy does not retry
+// at all, the copy only retries errors of class, using a short backoff.
+func (rp RetryPolicy) withClass(class ErrorClass, minAttempts int) RetryPolicy {
+	if rp.MaxAttempts < 2 {
+		return RetryPolicy{
+			MaxAttempts:    minAttempts,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     time.Second,
+			Classes:        []ErrorClass{class},
+		}
+	}
+	if !rp.retries(class, true) {
+		rp.Classes = append(rp.Classes[:len(rp.Classes):len(rp.Classes)], class)
+	}
+	if rp.MaxAttempts < minAttempts {
+		rp.MaxAttempts = minAttempts
+	}
+	return rp
+}
+
+// dropDeadlockAttempts is the minimum number of attempts made by
+// DropTablesInSchema for each DROP TABLE which deadlocks.
+const dropDeadlockAttempts = 5
+
+// SetRetryPolicy configures how the instance's methods retry transient errors
+// encountered during introspection, DDL, and other queries. By default, no
+// retries are performed, aside from deadlocks in DropTablesInSchema, which are
+// always retried. Read-only operations are retried upon any error class
+// permitted by the policy; statements which modify the database are not
+// retried after a lost connection, since they may have already executed.
+// This should be called before the Instance is used concurrently.
+func (instance *Instance) SetRetryPolicy(policy RetryPolicy) {
+	instance.retryPolicy = policy
+}
+
+// retry calls fn, retrying according to the instance's retry policy. See
+// RetryPolicy.do for the meaning of idempotent.
+func (instance *Instance) retry(ctx context.Context, idempotent bool, fn func() error) error {
+	return instance.retryPolicy.do(ctx, idempotent, fn)
+}
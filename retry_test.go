@@ -0,0 +1,146 @@
+package tengo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/VividCortex/mysqlerr"
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: mysqlerr.ER_LOCK_DEADLOCK}
+	syntax := &mysql.MySQLError{Number: mysqlerr.ER_PARSE_ERROR}
+	failing := func(err error, failures int, calls *int) func() error {
+		return func() error {
+			*calls++
+			if *calls <= failures {
+				return err
+			}
+			return nil
+		}
+	}
+	rp := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+	ctx := context.Background()
+
+	var calls int
+	if err := rp.Do(ctx, failing(deadlock, 2, &calls)); err != nil || calls != 3 {
+		t.Errorf("Expected success after 3 calls, instead found err=%v after %d calls", err, calls)
+	}
+	calls = 0
+	if err := rp.Do(ctx, failing(deadlock, 5, &calls)); err != deadlock || calls != 3 {
+		t.Errorf("Expected deadlock error after 3 calls, instead found err=%v after %d calls", err, calls)
+	}
+	calls = 0
+	if err := rp.Do(ctx, failing(syntax, 5, &calls)); err != syntax || calls != 1 {
+		t.Errorf("Expected fatal error to not be retried, instead found err=%v after %d calls", err, calls)
+	}
+
+	// Non-idempotent operations must not be retried after lost connection
+	calls = 0
+	if err := rp.do(ctx, false, failing(mysql.ErrInvalidConn, 5, &calls)); err != mysql.ErrInvalidConn || calls != 1 {
+		t.Errorf("Expected lost connection to not be retried for non-idempotent operation, instead found err=%v after %d calls", err, calls)
+	}
+	calls = 0
+	if err := rp.do(ctx, true, failing(mysql.ErrInvalidConn, 1, &calls)); err != nil || calls != 2 {
+		t.Errorf("Expected lost connection to be retried for idempotent operation, instead found err=%v after %d calls", err, calls)
+	}
+
+	// Restricting classes
+	rp.Classes = []ErrorClass{ErrorClassLockWaitTimeout}
+	calls = 0
+	if err := rp.Do(ctx, failing(deadlock, 1, &calls)); err != deadlock || calls != 1 {
+		t.Errorf("Expected deadlock to not be retried when excluded from Classes, instead found err=%v after %d calls", err, calls)
+	}
+
+	// Zero value does not retry
+	calls = 0
+	if err := (RetryPolicy{}).Do(ctx, failing(deadlock, 1, &calls)); err != deadlock || calls != 1 {
+		t.Errorf("Expected zero-value policy to not retry, instead found err=%v after %d calls", err, calls)
+	}
+
+	// Cancelled context stops retries
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	rp = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}
+	calls = 0
+	if err := rp.Do(cancelCtx, failing(deadlock, 5, &calls)); err != context.Canceled || calls != 1 {
+		t.Errorf("Expected context cancellation to stop retries, instead found err=%v after %d calls", err, calls)
+	}
+}
+
+func TestRetryPolicyWithClass(t *testing.T) {
+	// Policy which doesn't retry at all only gains the new class
+	rp := RetryPolicy{}.withClass(ErrorClassDeadlock, 5)
+	if rp.MaxAttempts != 5 || !rp.retries(ErrorClassDeadlock, false) || rp.retries(ErrorClassLockWaitTimeout, true) {
+		t.Errorf("Unexpected result from withClass on zero-value policy: %+v", rp)
+	}
+
+	// Policy retrying all classes is unchanged aside from attempts
+	rp = DefaultRetryPolicy.withClass(ErrorClassDeadlock, 5)
+	if rp.MaxAttempts != 5 || len(rp.Classes) != 0 || rp.InitialBackoff != DefaultRetryPolicy.InitialBackoff {
+		t.Errorf("Unexpected result from withClass on default policy: %+v", rp)
+	}
+
+	// Policy restricted to other classes gains the new class, without modifying
+	// the original's slice
+	orig := RetryPolicy{MaxAttempts: 8, Classes: make([]ErrorClass, 1, 4)}
+	orig.Classes[0] = ErrorClassLockWaitTimeout
+	rp = orig.withClass(ErrorClassDeadlock, 5)
+	if rp.MaxAttempts != 8 || !rp.retries(ErrorClassDeadlock, true) || !rp.retries(ErrorClassLockWaitTimeout, true) {
+		t.Errorf("Unexpected result from withClass on restricted policy: %+v", rp)
+	}
+	if orig.retries(ErrorClassDeadlock, true) || len(orig.Classes) != 1 || orig.Classes[:2][1] == ErrorClassDeadlock {
+		t.Errorf("withClass unexpectedly modified original policy: %+v", orig)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceRetryPolicy(t *testing.T) {
+	// Hold a row lock in another session, releasing it after a delay
+	db, err := s.d.ConnectionPool("testing", "")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to begin transaction: %v", err)
+	}
+	if _, err := tx.Exec("SELECT * FROM has_rows WHERE id = 1 FOR UPDATE"); err != nil {
+		t.Fatalf("Unable to lock row: %v", err)
+	}
+	go func() {
+		time.Sleep(1500 * time.Millisecond)
+		tx.Rollback()
+	}()
+
+	inst, err := NewInstance("mysql", s.d.DSN())
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	defer inst.CloseAll()
+	inst.SetRetryPolicy(RetryPolicy{MaxAttempts: 4, InitialBackoff: 100 * time.Millisecond})
+	statements := []string{"UPDATE has_rows SET name = 'Jimbo2' WHERE id = 1"}
+	opts := ExecOptions{Params: "innodb_lock_wait_timeout=1"}
+	if err := inst.ExecStatements("testing", statements, opts); err != nil {
+		t.Errorf("Expected lock wait timeout to be retried successfully, instead found %v", err)
+	}
+
+	// Statements in an explicit transaction are not retried, and the returned
+	// error can still be classified
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatalf("Unable to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("SELECT * FROM has_rows WHERE id = 1 FOR UPDATE"); err != nil {
+		t.Fatalf("Unable to lock row: %v", err)
+	}
+	statements = []string{"BEGIN", "UPDATE has_rows SET name = 'Jimbo3' WHERE id = 1", "COMMIT"}
+	if err := inst.ExecStatements("testing", statements, opts); err == nil {
+		t.Error("Expected lock wait timeout inside transaction to not be retried, but err was nil")
+	} else if class := ClassifyError(err); class != ErrorClassLockWaitTimeout {
+		t.Errorf("Expected error to be classified as %s, instead found %s: %v", ErrorClassLockWaitTimeout, class, err)
+	}
+}
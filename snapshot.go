@@ -0,0 +1,261 @@
+package tengo
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotFormatVersion is the version of the file format written by
+// WriteSnapshot and SaveSnapshot. It will be incremented whenever a change is
+// made that prevents older versions of this package from reading the file.
+const SnapshotFormatVersion = 1
+
+// Snapshot represents the state of one or more schemas, as introspected from
+// a database server at a specific point in time. Snapshots may be persisted to
+// disk and loaded later, permitting diffs against historical schema state
+// without needing to connect to the server.
+type Snapshot struct {
+	Flavor     Flavor
+	CapturedAt time.Time
+	Schemas    []*Schema
+}
+
+// snapshotFile is the on-disk JSON representation of a Snapshot. Flavor is
+// stored as a string (in the format of Flavor.String) so that the file does not
+// depend on the numeric values of Vendor constants.
+type snapshotFile struct {
+	FormatVersion int       `json:"formatVersion"`
+	Flavor        string    `json:"flavor"`
+	CapturedAt    time.Time `json:"capturedAt"`
+	Schemas       []*Schema `json:"schemas"`
+}
+
+// NewSnapshot returns a Snapshot of the supplied schemas, which should have
+// been introspected from a server of the supplied flavor. The capture time is
+// set to the current time.
+func NewSnapshot(flavor Flavor, schemas ...*Schema) *Snapshot {
+	if schemas == nil {
+		schemas = []*Schema{}
+	}
+	return &Snapshot{
+		Flavor:     flavor,
+		CapturedAt: time.Now().UTC(),
+		Schemas:    schemas,
+	}
+}
+
+// Snapshot introspects schemas on the instance and returns them as a Snapshot.
+// If called with no args, all non-system schemas will be included. Or pass one
+// or more schema names as args to filter the result to just those schemas.
+func (instance *Instance) Snapshot(onlyNames ...string) (*Snapshot, error) {
+	capturedAt := time.Now().UTC()
+	schemas, err := instance.Schemas(onlyNames...)
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshot{
+		Flavor:     instance.Flavor(),
+		CapturedAt: capturedAt,
+		Schemas:    schemas,
+	}, nil
+}
+
+// Schema returns the schema in the snapshot with the supplied name, or nil if
+// no such schema is present.
+func (snap *Snapshot) Schema(name string) *Schema {
+	for _, s := range snap.Schemas {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// Validate confirms that the snapshot is well-formed: it must have a capture
+// time, and every schema, table, and routine must be non-nil, named, and
+// unique within its parent. Tables must have at least one column and a CREATE
+// statement. An error describing the first problem found is returned.
+func (snap *Snapshot) Validate() error {
+	if snap.CapturedAt.IsZero() {
+		return fmt.Errorf("Invalid snapshot: capture time is missing")
+	}
+	seenSchemas := make(map[string]bool, len(snap.Schemas))
+	for n, s := range snap.Schemas {
+		if s == nil || s.Name == "" {
+			return fmt.Errorf("Invalid snapshot: schema at position %d is missing or unnamed", n)
+		} else if seenSchemas[s.Name] {
+			return fmt.Errorf("Invalid snapshot: schema %s is present multiple times", EscapeIdentifier(s.Name))
+		}
+		seenSchemas[s.Name] = true
+		if err := validateSnapshotSchema(s); err != nil {
+			return fmt.Errorf("Invalid snapshot: schema %s: %s", EscapeIdentifier(s.Name), err)
+		}
+	}
+	return nil
+}
+
+func validateSnapshotSchema(s *Schema) error {
+	seenTables := make(map[string]bool, len(s.Tables))
+	for n, t := range s.Tables {
+		if t == nil || t.Name == "" {
+			return fmt.Errorf("table at position %d is missing or unnamed", n)
+		} else if seenTables[t.Name] {
+			return fmt.Errorf("table %s is present multiple times", EscapeIdentifier(t.Name))
+		} else if len(t.Columns) == 0 {
+			return fmt.Errorf("table %s has no columns", EscapeIdentifier(t.Name))
+		} else if t.CreateStatement == "" {
+			return fmt.Errorf("table %s has no CREATE TABLE statement", EscapeIdentifier(t.Name))
+		}
+		seenTables[t.Name] = true
+		for _, col := range t.Columns {
+			if col == nil || col.Name == "" || col.TypeInDB == "" {
+				return fmt.Errorf("table %s has a column that is missing a name or type", EscapeIdentifier(t.Name))
+			}
+		}
+		for _, idx := range append([]*Index{t.PrimaryKey}, t.SecondaryIndexes...) {
+			if idx != nil && len(idx.Parts) == 0 {
+				return fmt.Errorf("table %s has index %s with no parts", EscapeIdentifier(t.Name), EscapeIdentifier(idx.Name))
+			}
+		}
+	}
+	seenRoutines := make(map[ObjectKey]bool, len(s.Routines))
+	for n, r := range s.Routines {
+		if r == nil || r.Name == "" {
+			return fmt.Errorf("routine at position %d is missing or unnamed", n)
+		} else if r.Type != ObjectTypeProc && r.Type != ObjectTypeFunc {
+			return fmt.Errorf("routine %s has unsupported type %q", EscapeIdentifier(r.Name), r.Type)
+		}
+		key := ObjectKey{Type: r.Type, Name: r.Name}
+		if seenRoutines[key] {
+			return fmt.Errorf("%s is present multiple times", key)
+		}
+		seenRoutines[key] = true
+	}
+	return nil
+}
+
+// WriteSnapshot validates snap and then writes it to w in JSON format.
+func WriteSnapshot(w io.Writer, snap *Snapshot) error {
+	if err := snap.Validate(); err != nil {
+		return err
+	}
+	sf := snapshotFile{
+		FormatVersion: SnapshotFormatVersion,
+		Flavor:        snap.Flavor.String(),
+		CapturedAt:    snap.CapturedAt,
+		Schemas:       snap.Schemas,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sf)
+}
+
+// ReadSnapshot reads a snapshot in the format written by WriteSnapshot, and
+// validates it before returning it. An error is returned if the snapshot was
+// written using a newer format version than this package understands.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var sf snapshotFile
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sf); err != nil {
+		return nil, fmt.Errorf("Unable to parse snapshot: %s", err)
+	}
+	if sf.FormatVersion < 1 || sf.FormatVersion > SnapshotFormatVersion {
+		return nil, fmt.Errorf("Unsupported snapshot format version %d (expected 1 through %d)", sf.FormatVersion, SnapshotFormatVersion)
+	}
+	flavor := NewFlavor(sf.Flavor)
+	if flavor.String() != sf.Flavor {
+		return nil, fmt.Errorf("Invalid snapshot: unable to parse flavor %q", sf.Flavor)
+	}
+	snap := &Snapshot{
+		Flavor:     flavor,
+		CapturedAt: sf.CapturedAt,
+		Schemas:    sf.Schemas,
+	}
+	if snap.Schemas == nil {
+		snap.Schemas = []*Schema{}
+	}
+	if err := snap.Validate(); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// SaveSnapshot writes snap to the supplied file path, replacing any existing
+// file atomically. If the path ends in ".gz", the file will be gzip-compressed.
+// An existing file's permissions are retained; otherwise, the file is created
+// with the same permissions as os.Create would use.
+func SaveSnapshot(path string, snap *Snapshot) (err error) {
+	tmp, err := createTempFile(filepath.Dir(path), ".snapshot-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if strings.HasSuffix(path, ".gz") {
+		gz := gzip.NewWriter(tmp)
+		if err = WriteSnapshot(gz, snap); err != nil {
+			return err
+		}
+		if err = gz.Close(); err != nil {
+			return err
+		}
+	} else if err = WriteSnapshot(tmp, snap); err != nil {
+		return err
+	}
+	if fi, statErr := os.Stat(path); statErr == nil {
+		if err = tmp.Chmod(fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// createTempFile creates a new file in dir, with a name beginning with prefix.
+// Unlike ioutil.TempFile, which always uses mode 0600, the file is created with
+// mode 0666 before umask, as with os.Create.
+func createTempFile(dir, prefix string) (*os.File, error) {
+	for attempt := 0; ; attempt++ {
+		name := filepath.Join(dir, prefix+strconv.FormatInt(time.Now().UnixNano()+int64(attempt), 36))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && attempt < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// LoadSnapshot reads and validates a snapshot from the supplied file path,
+// which should have been written by SaveSnapshot. If the path ends in ".gz",
+// the file is expected to be gzip-compressed.
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return ReadSnapshot(r)
+}
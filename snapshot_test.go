@@ -0,0 +1,161 @@
+package tengo
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func aSnapshot() *Snapshot {
+	t1 := aTable(1)
+	t2 := anotherTable()
+	s1 := aSchema("s1", &t1, &t2)
+	p, f := aProc("latin1_swedish_ci", ""), aFunc("latin1_swedish_ci", "")
+	s1.Routines = []*Routine{&p, &f}
+	s2 := aSchema("s2")
+	return &Snapshot{
+		Flavor:     FlavorMySQL57,
+		CapturedAt: time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC),
+		Schemas:    []*Schema{&s1, &s2},
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, fileName := range []string{"snap.json", "snap.json.gz"} {
+		path := filepath.Join(dir, fileName)
+		snap := aSnapshot()
+		if err := SaveSnapshot(path, snap); err != nil {
+			t.Fatalf("Unexpected error from SaveSnapshot: %v", err)
+		}
+		loaded, err := LoadSnapshot(path)
+		if err != nil {
+			t.Fatalf("Unexpected error from LoadSnapshot: %v", err)
+		}
+		if loaded.Flavor != snap.Flavor || !loaded.CapturedAt.Equal(snap.CapturedAt) {
+			t.Errorf("Snapshot metadata mismatch: expected %s / %s, found %s / %s", snap.Flavor, snap.CapturedAt, loaded.Flavor, loaded.CapturedAt)
+		}
+		if len(loaded.Schemas) != len(snap.Schemas) {
+			t.Fatalf("Expected %d schemas, instead found %d", len(snap.Schemas), len(loaded.Schemas))
+		}
+		for _, s := range snap.Schemas {
+			ls := loaded.Schema(s.Name)
+			if ls == nil {
+				t.Fatalf("Expected loaded snapshot to contain schema %s, but it did not", s.Name)
+			}
+			if objDiffs := s.Diff(ls).ObjectDiffs(); len(objDiffs) != 0 {
+				t.Errorf("Expected no object diffs in schema %s, but instead found %d: %+v", s.Name, len(objDiffs), objDiffs)
+			}
+		}
+	}
+	if (&Snapshot{}).Schema("s1") != nil {
+		t.Error("Expected Schema on an empty snapshot to return nil")
+	}
+}
+
+func TestSaveSnapshotMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("File permission bits are not meaningful on Windows")
+	}
+	dir := t.TempDir()
+
+	// New file should have the same mode as one created by os.Create
+	f, err := os.Create(filepath.Join(dir, "reference"))
+	if err != nil {
+		t.Fatalf("Unexpected error from os.Create: %v", err)
+	}
+	f.Close()
+	refInfo, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatalf("Unexpected error from os.Stat: %v", err)
+	}
+	path := filepath.Join(dir, "snap.json")
+	if err := SaveSnapshot(path, aSnapshot()); err != nil {
+		t.Fatalf("Unexpected error from SaveSnapshot: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatalf("Unexpected error from os.Stat: %v", err)
+	} else if fi.Mode().Perm() != refInfo.Mode().Perm() {
+		t.Errorf("Expected new snapshot file to have mode %s, instead found %s", refInfo.Mode().Perm(), fi.Mode().Perm())
+	}
+
+	// Existing file's mode should be retained
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatalf("Unexpected error from os.Chmod: %v", err)
+	}
+	if err := SaveSnapshot(path, aSnapshot()); err != nil {
+		t.Fatalf("Unexpected error from SaveSnapshot: %v", err)
+	}
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatalf("Unexpected error from os.Stat: %v", err)
+	} else if fi.Mode().Perm() != 0640 {
+		t.Errorf("Expected existing snapshot file mode 0640 to be retained, instead found %s", fi.Mode().Perm())
+	}
+
+	// No temp files should remain
+	if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) != 2 {
+		t.Errorf("Expected only 2 files in %s, instead found %v (err=%v)", dir, entries, err)
+	}
+}
+
+func TestReadSnapshotErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, aSnapshot()); err != nil {
+		t.Fatalf("Unexpected error from WriteSnapshot: %v", err)
+	}
+	contents := buf.String()
+	if _, err := ReadSnapshot(strings.NewReader(contents)); err != nil {
+		t.Fatalf("Unexpected error from ReadSnapshot: %v", err)
+	}
+
+	cases := map[string]string{
+		"malformed":       contents[0 : len(contents)/2],
+		"format version":  strings.Replace(contents, `"formatVersion": 1`, `"formatVersion": 99`, 1),
+		"flavor":          strings.Replace(contents, `"flavor": "mysql:5.7"`, `"flavor": "oracle:12"`, 1),
+		"capture time":    strings.Replace(contents, `"capturedAt": "2020-06-01T12:30:00Z"`, `"capturedAt": "0001-01-01T00:00:00Z"`, 1),
+		"dupe schema":     strings.Replace(contents, `"databaseName": "s2"`, `"databaseName": "s1"`, 1),
+		"unknown field":   strings.Replace(contents, `"formatVersion": 1`, `"formatVersion": 1, "bogus": true`, 1),
+		"missing columns": strings.Replace(contents, `"columns": [`, `"columnz": [`, 1),
+	}
+	for name, input := range cases {
+		if input == contents {
+			t.Fatalf("Test case %q did not modify input; test setup is incorrect", name)
+		}
+		if _, err := ReadSnapshot(strings.NewReader(input)); err == nil {
+			t.Errorf("Test case %q: expected error from ReadSnapshot, but err was nil", name)
+		}
+	}
+}
+
+func TestSnapshotValidate(t *testing.T) {
+	if err := aSnapshot().Validate(); err != nil {
+		t.Fatalf("Unexpected error from Validate: %v", err)
+	}
+	mutators := []func(snap *Snapshot){
+		func(snap *Snapshot) { snap.CapturedAt = time.Time{} },
+		func(snap *Snapshot) { snap.Schemas[1].Name = "" },
+		func(snap *Snapshot) { snap.Schemas = append(snap.Schemas, nil) },
+		func(snap *Snapshot) { snap.Schemas[0].Tables[1].Name = snap.Schemas[0].Tables[0].Name },
+		func(snap *Snapshot) { snap.Schemas[0].Tables[0].Columns = nil },
+		func(snap *Snapshot) { snap.Schemas[0].Tables[0].CreateStatement = "" },
+		func(snap *Snapshot) { snap.Schemas[0].Tables[0].Columns[0].TypeInDB = "" },
+		func(snap *Snapshot) { snap.Schemas[0].Tables[0].PrimaryKey.Parts = nil },
+		func(snap *Snapshot) { snap.Schemas[0].Routines[0].Type = ObjectTypeTable },
+		func(snap *Snapshot) { snap.Schemas[0].Routines[1] = snap.Schemas[0].Routines[0] },
+	}
+	for n, mutator := range mutators {
+		snap := aSnapshot()
+		mutator(snap)
+		if err := snap.Validate(); err == nil {
+			t.Errorf("Mutator[%d]: expected error from Validate, but err was nil", n)
+		}
+		if err := WriteSnapshot(&bytes.Buffer{}, snap); err == nil {
+			t.Errorf("Mutator[%d]: expected error from WriteSnapshot, but err was nil", n)
+		}
+	}
+}
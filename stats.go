@@ -0,0 +1,166 @@
+package tengo
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strconv"
+)
+
+// TableStats contains size and growth statistics for a single table, as
+// estimated by information_schema. Row counts and sizes are approximate for
+// InnoDB tables; see the note on Instance.TableSize regarding persistent
+// statistics.
+type TableStats struct {
+	Name              string
+	Engine            string
+	Rows              int64 // estimated
+	AvgRowLength      int64
+	DataBytes         int64
+	IndexBytes        int64
+	FreeBytes         int64            // allocated but unused space, i.e. fragmentation
+	Partitions        []PartitionStats // nil if table is not partitioned
+	NextAutoIncrement uint64           // 0 if table has no auto-increment column
+	MaxAutoIncrement  uint64           // largest value permitted by the auto-increment column's type; 0 if none
+}
+
+// PartitionStats contains size statistics for one partition, or subpartition,
+// of a table.
+type PartitionStats struct {
+	Name             string
+	SubpartitionName string // "" if not subpartitioned
+	Rows             int64  // estimated
+	DataBytes        int64
+	IndexBytes       int64
+	FreeBytes        int64
+}
+
+// TotalBytes returns the total estimated on-disk size of the table, in the
+// same manner as Instance.TableSize.
+func (ts *TableStats) TotalBytes() int64 {
+	return ts.DataBytes + ts.IndexBytes + ts.FreeBytes
+}
+
+// AutoIncrementRemaining returns how many more values may be generated by the
+// table's auto-increment column before reaching the maximum for its type. If
+// the table has no integer auto-increment column, 0 and false are returned.
+func (ts *TableStats) AutoIncrementRemaining() (uint64, bool) {
+	if ts.MaxAutoIncrement == 0 || ts.NextAutoIncrement == 0 {
+		return 0, false
+	} else if ts.NextAutoIncrement > ts.MaxAutoIncrement {
+		return 0, true
+	}
+	return ts.MaxAutoIncrement - ts.NextAutoIncrement + 1, true
+}
+
+// AutoIncrementUsage returns the fraction, from 0 to 1, of the auto-increment
+// column's range which has been used. If the table has no integer
+// auto-increment column, 0 is returned.
+func (ts *TableStats) AutoIncrementUsage() float64 {
+	if ts.MaxAutoIncrement == 0 || ts.NextAutoIncrement == 0 {
+		return 0
+	} else if ts.NextAutoIncrement > ts.MaxAutoIncrement {
+		return 1
+	}
+	return float64(ts.NextAutoIncrement-1) / float64(ts.MaxAutoIncrement)
+}
+
+// TableStatistics returns statistics for every table in the schema, sorted by
+// table name. Views are excluded. If the schema does not exist or has no
+// tables, an empty slice is returned.
+func (instance *Instance) TableStatistics(schema string) ([]*TableStats, error) {
+	db, release, err := instance.leasedConnectionPool("", instance.introspectionParams())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var rawTables []struct {
+		Name         string         `db:"table_name"`
+		Engine       sql.NullString `db:"engine"`
+		Rows         sql.NullInt64  `db:"table_rows"`
+		AvgRowLength sql.NullInt64  `db:"avg_row_length"`
+		DataLength   sql.NullInt64  `db:"data_length"`
+		IndexLength  sql.NullInt64  `db:"index_length"`
+		DataFree     sql.NullInt64  `db:"data_free"`
+		AutoInc      sql.NullString `db:"auto_increment"` // may exceed range of int64
+		AutoIncType  sql.NullString `db:"auto_increment_type"`
+	}
+	query := `
+		SELECT t.table_name AS table_name, t.engine AS engine,
+		       t.table_rows AS table_rows, t.avg_row_length AS avg_row_length,
+		       t.data_length AS data_length, t.index_length AS index_length,
+		       t.data_free AS data_free, t.auto_increment AS auto_increment,
+		       c.column_type AS auto_increment_type
+		FROM   information_schema.tables t
+		LEFT JOIN information_schema.columns c
+		       ON c.table_schema = t.table_schema AND c.table_name = t.table_name
+		       AND c.extra LIKE '%auto_increment%'
+		WHERE  t.table_schema = ? AND t.table_type = 'BASE TABLE'`
+	var rawPartitions []struct {
+		TableName        string         `db:"table_name"`
+		Name             string         `db:"partition_name"`
+		SubpartitionName sql.NullString `db:"subpartition_name"`
+		Rows             sql.NullInt64  `db:"table_rows"`
+		DataLength       sql.NullInt64  `db:"data_length"`
+		IndexLength      sql.NullInt64  `db:"index_length"`
+		DataFree         sql.NullInt64  `db:"data_free"`
+	}
+	partQuery := `
+		SELECT   p.table_name AS table_name, p.partition_name AS partition_name,
+		         p.subpartition_name AS subpartition_name, p.table_rows AS table_rows,
+		         p.data_length AS data_length, p.index_length AS index_length,
+		         p.data_free AS data_free
+		FROM     information_schema.partitions p
+		WHERE    p.table_schema = ? AND p.partition_name IS NOT NULL
+		ORDER BY p.table_name, p.partition_ordinal_position, p.subpartition_ordinal_position`
+	err = instance.retry(context.Background(), true, func() error {
+		rawTables, rawPartitions = nil, nil
+		if err := db.Select(&rawTables, query, schema); err != nil {
+			return err
+		}
+		return db.Select(&rawPartitions, partQuery, schema)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*TableStats, len(rawTables))
+	byName := make(map[string]*TableStats, len(rawTables))
+	for n, rt := range rawTables {
+		ts := &TableStats{
+			Name:         rt.Name,
+			Engine:       rt.Engine.String,
+			Rows:         rt.Rows.Int64,
+			AvgRowLength: rt.AvgRowLength.Int64,
+			DataBytes:    rt.DataLength.Int64,
+			IndexBytes:   rt.IndexLength.Int64,
+			FreeBytes:    rt.DataFree.Int64,
+		}
+		if rt.AutoIncType.Valid {
+			col := &Column{TypeInDB: rt.AutoIncType.String}
+			ts.MaxAutoIncrement, _ = col.MaxIntegerValue()
+			ts.NextAutoIncrement, _ = strconv.ParseUint(rt.AutoInc.String, 10, 64)
+			if ts.NextAutoIncrement == 0 {
+				ts.NextAutoIncrement = 1
+			}
+		}
+		result[n] = ts
+		byName[ts.Name] = ts
+	}
+	for _, rp := range rawPartitions {
+		if ts := byName[rp.TableName]; ts != nil {
+			ts.Partitions = append(ts.Partitions, PartitionStats{
+				Name:             rp.Name,
+				SubpartitionName: rp.SubpartitionName.String,
+				Rows:             rp.Rows.Int64,
+				DataBytes:        rp.DataLength.Int64,
+				IndexBytes:       rp.IndexLength.Int64,
+				FreeBytes:        rp.DataFree.Int64,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
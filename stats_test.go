@@ -0,0 +1,100 @@
+package tengo
+
+import (
+	"testing"
+)
+
+func TestColumnMaxIntegerValue(t *testing.T) {
+	cases := map[string]uint64{
+		"tinyint(4)":                127,
+		"tinyint(3) unsigned":       255,
+		"smallint":                  32767,
+		"mediumint(8) unsigned":     16777215,
+		"int(11)":                   2147483647,
+		"int unsigned":              4294967295,
+		"INT(10) UNSIGNED ZEROFILL": 4294967295,
+		"bigint(20)":                9223372036854775807,
+		"bigint(20) unsigned":       18446744073709551615,
+		"integer":                   2147483647,
+	}
+	for typ, expected := range cases {
+		col := &Column{TypeInDB: typ}
+		if actual, ok := col.MaxIntegerValue(); !ok || actual != expected {
+			t.Errorf("Expected MaxIntegerValue for %s to return %d, instead found %d, %t", typ, expected, actual, ok)
+		}
+	}
+	for _, typ := range []string{"varchar(30)", "decimal(10,2)", "float", "bit(8)", "tinytext"} {
+		col := &Column{TypeInDB: typ}
+		if actual, ok := col.MaxIntegerValue(); ok || actual != 0 {
+			t.Errorf("Expected MaxIntegerValue for %s to return 0, false; instead found %d, %t", typ, actual, ok)
+		}
+	}
+}
+
+func TestTableStatsAutoIncrement(t *testing.T) {
+	ts := &TableStats{NextAutoIncrement: 101, MaxAutoIncrement: 200}
+	if remaining, ok := ts.AutoIncrementRemaining(); remaining != 100 || !ok {
+		t.Errorf("Unexpected return from AutoIncrementRemaining: %d, %t", remaining, ok)
+	}
+	if usage := ts.AutoIncrementUsage(); usage != 0.5 {
+		t.Errorf("Unexpected return from AutoIncrementUsage: %f", usage)
+	}
+	ts.NextAutoIncrement = 201
+	if remaining, ok := ts.AutoIncrementRemaining(); remaining != 0 || !ok {
+		t.Errorf("Unexpected return from AutoIncrementRemaining: %d, %t", remaining, ok)
+	}
+	if usage := ts.AutoIncrementUsage(); usage != 1 {
+		t.Errorf("Unexpected return from AutoIncrementUsage: %f", usage)
+	}
+	ts = &TableStats{DataBytes: 100, IndexBytes: 20, FreeBytes: 3}
+	if remaining, ok := ts.AutoIncrementRemaining(); remaining != 0 || ok {
+		t.Errorf("Unexpected return from AutoIncrementRemaining: %d, %t", remaining, ok)
+	}
+	if usage := ts.AutoIncrementUsage(); usage != 0 {
+		t.Errorf("Unexpected return from AutoIncrementUsage: %f", usage)
+	}
+	if total := ts.TotalBytes(); total != 123 {
+		t.Errorf("Unexpected return from TotalBytes: %d", total)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceTableStatistics(t *testing.T) {
+	stats, err := s.d.TableStatistics("testing")
+	if err != nil {
+		t.Fatalf("Unexpected error from TableStatistics: %v", err)
+	}
+	schema := s.GetSchema(t, "testing")
+	if len(stats) != len(schema.Tables) {
+		t.Fatalf("Expected stats for %d tables, instead found %d", len(schema.Tables), len(stats))
+	}
+	byName := make(map[string]*TableStats, len(stats))
+	for n, ts := range stats {
+		if n > 0 && stats[n-1].Name >= ts.Name {
+			t.Errorf("Expected stats to be sorted by name, but %s came before %s", stats[n-1].Name, ts.Name)
+		}
+		byName[ts.Name] = ts
+	}
+
+	hasRows := byName["has_rows"]
+	if hasRows == nil {
+		t.Fatal("Stats for has_rows not found")
+	}
+	if hasRows.NextAutoIncrement != 5 || hasRows.MaxAutoIncrement != 4294967295 || hasRows.Partitions != nil {
+		t.Errorf("Unexpected stats for has_rows: %+v", *hasRows)
+	}
+	if hasRows.TotalBytes() <= 0 {
+		t.Errorf("Expected positive size for has_rows, instead found %d", hasRows.TotalBytes())
+	}
+	if size, err := s.d.TableSize("testing", "has_rows"); err != nil || size != hasRows.TotalBytes() {
+		t.Errorf("Expected TableSize to match TotalBytes %d, instead found %d, %v", hasRows.TotalBytes(), size, err)
+	}
+	if noPK := byName["no_pk"]; noPK == nil || noPK.MaxAutoIncrement != 0 || noPK.NextAutoIncrement != 0 {
+		t.Errorf("Unexpected stats for table without auto-increment: %+v", noPK)
+	}
+
+	if fp := byName["followed_posts"]; fp == nil || len(fp.Partitions) != 4 {
+		t.Errorf("Expected 4 subpartitions for followed_posts, instead found %+v", fp)
+	} else if fp.Partitions[0].Name != "p0" || fp.Partitions[0].SubpartitionName == "" {
+		t.Errorf("Unexpected partition stats: %+v", fp.Partitions[0])
+	}
+}
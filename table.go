@@ -154,6 +154,20 @@ func (t *Table) HasAutoIncrement() bool {
 	return false
 }
 
+// HasGeneratedInvisiblePrimaryKey returns true if the table's primary key
+// appears to have been added automatically by MySQL 8.0.30+ due to
+// sql_generate_invisible_primary_key. Such primary keys always consist of an
+// invisible auto-increment column named my_row_id. Note that these may be
+// omitted entirely from introspection if the server has
+// show_gipk_in_create_table_and_information_schema disabled.
+func (t *Table) HasGeneratedInvisiblePrimaryKey() bool {
+	if t.PrimaryKey == nil || len(t.PrimaryKey.Parts) != 1 || t.PrimaryKey.Parts[0].ColumnName != "my_row_id" {
+		return false
+	}
+	col := t.ColumnsByName()["my_row_id"]
+	return col != nil && col.Invisible && col.AutoIncrement && strings.HasPrefix(col.TypeInDB, "bigint") && strings.HasSuffix(col.TypeInDB, "unsigned")
+}
+
 // ClusteredIndexKey returns which index is used for an InnoDB table's clustered
 // index. This will be the primary key if one exists; otherwise, it will be the
 // first unique key with non-nullable columns. If there is no such key, or if
@@ -223,18 +237,18 @@ func (t *Table) Diff(to *Table) (clauses []TableAlterClause, supported bool) {
 	// Check for default charset or collation changes first, prior to looking at
 	// column adds, to ensure the change affects any new columns that don't
 	// explicitly state to use a different charset/collation
+	cc := from.compareColumnExistence(to)
+	columnMods := cc.columnModifications()
 	if from.CharSet != to.CharSet || from.Collation != to.Collation {
-		clauses = append(clauses, ChangeCharSet{
-			CharSet:   to.CharSet,
-			Collation: to.Collation,
-		})
+		var charSetClause TableAlterClause
+		charSetClause, columnMods = from.charSetClause(to, columnMods)
+		clauses = append(clauses, charSetClause)
 	}
 
 	// Process column drops, modifications, adds. Must be done in this specific order
 	// so that column reordering works properly.
-	cc := from.compareColumnExistence(to)
 	clauses = append(clauses, cc.columnDrops()...)
-	clauses = append(clauses, cc.columnModifications()...)
+	clauses = append(clauses, columnMods...)
 	clauses = append(clauses, cc.columnAdds()...)
 
 	// Compare PK
@@ -415,6 +429,57 @@ func (t *Table) Diff(to *Table) (clauses []TableAlterClause, supported bool) {
 	return clauses, true
 }
 
+// charSetClause returns a clause changing the table's default character set
+// and collation to those of table to. If every pre-existing textual column is
+// also being converted to the new default, in the same manner as CONVERT TO
+// CHARACTER SET, a ConvertCharSet clause is returned, and the column
+// modifications which it makes redundant are removed from mods. Otherwise, a
+// ChangeCharSet clause is returned, and mods is returned unchanged.
+func (t *Table) charSetClause(to *Table, mods []TableAlterClause) (TableAlterClause, []TableAlterClause) {
+	from := t // keeping name as t in method definition to satisfy linter
+	change := ChangeCharSet{
+		CharSet:   to.CharSet,
+		Collation: to.Collation,
+	}
+	toColumns := to.ColumnsByName()
+	var anyConverted bool
+	for _, fromCol := range from.Columns {
+		toCol, stillExists := toColumns[fromCol.Name]
+		if fromCol.CharSet == "" || !stillExists {
+			continue
+		} else if toCol.CharSet != to.CharSet || toCol.Collation != to.Collation {
+			return change, mods
+		} else if fromCol.CharSet != toCol.CharSet || fromCol.Collation != toCol.Collation {
+			if !convertColumn(fromCol, to.CharSet, to.Collation, to.CollationIsDefault).Equals(toCol) {
+				return change, mods
+			}
+			anyConverted = true
+		}
+	}
+	if !anyConverted {
+		return change, mods
+	}
+
+	remaining := make([]TableAlterClause, 0, len(mods))
+	for _, clause := range mods {
+		if mc, ok := clause.(ModifyColumn); ok && (mc.OldColumn.CharSet != mc.NewColumn.CharSet || mc.OldColumn.Collation != mc.NewColumn.Collation) {
+			// Columns being converted may not also be repositioned, since CONVERT
+			// TO CHARACTER SET cannot be combined with MODIFY COLUMN of the same
+			// column
+			if mc.PositionFirst || mc.PositionAfter != nil {
+				return change, mods
+			}
+			continue
+		}
+		remaining = append(remaining, clause)
+	}
+	return ConvertCharSet{
+		Table:     from,
+		CharSet:   to.CharSet,
+		Collation: to.Collation,
+	}, remaining
+}
+
 func (t *Table) compareColumnExistence(other *Table) columnsComparison {
 	self := t // keeping name as t in method definition to satisfy linter
 	cc := columnsComparison{
@@ -25,6 +25,41 @@ func TestTableGeneratedCreateStatement(t *testing.T) {
 	}
 }
 
+func TestTableGeneratedCreateStatementNewerFlavors(t *testing.T) {
+	for _, flavor := range []Flavor{{VendorMySQL, 8, 0, 32}, {VendorAurora, 8, 0, 28}, FlavorMariaDB106, {VendorMariaDB, 10, 11, 6}} {
+		table := aTableForFlavor(flavor, 1)
+		if actual := table.GeneratedCreateStatement(flavor); actual != table.CreateStatement {
+			t.Errorf("Flavor %s: Generated DDL does not match actual DDL\nExpected:\n%s\nFound:\n%s", flavor, table.CreateStatement, actual)
+		}
+	}
+
+	// MySQL 8.0.30+ generated invisible primary key
+	flavor := Flavor{VendorMySQL, 8, 0, 34}
+	table := gipkTable()
+	if actual := table.GeneratedCreateStatement(flavor); actual != table.CreateStatement {
+		t.Errorf("Generated DDL does not match actual DDL\nExpected:\n%s\nFound:\n%s", table.CreateStatement, actual)
+	}
+	if !table.HasGeneratedInvisiblePrimaryKey() {
+		t.Error("Expected HasGeneratedInvisiblePrimaryKey to return true, but it did not")
+	}
+	table.Columns[0].Invisible = false
+	if table.HasGeneratedInvisiblePrimaryKey() {
+		t.Error("Expected HasGeneratedInvisiblePrimaryKey to return false for a visible column, but it did not")
+	}
+	table = aTable(1)
+	if table.HasGeneratedInvisiblePrimaryKey() {
+		t.Error("Expected HasGeneratedInvisiblePrimaryKey to return false for a normal table, but it did not")
+	}
+
+	// MariaDB 10.6+ ignored index
+	table = aTableForFlavor(FlavorMariaDB106, 1)
+	table.SecondaryIndexes[1].Invisible = true
+	expected := strings.Replace(table.CreateStatement, "`first_name`(1))\n", "`first_name`(1)) IGNORED\n", 1)
+	if actual := table.GeneratedCreateStatement(FlavorMariaDB106); actual != expected {
+		t.Errorf("Generated DDL does not match actual DDL\nExpected:\n%s\nFound:\n%s", expected, actual)
+	}
+}
+
 func TestTableClusteredIndexKey(t *testing.T) {
 	table := aTable(1)
 	if table.ClusteredIndexKey() == nil || table.ClusteredIndexKey() != table.PrimaryKey {
@@ -473,6 +508,10 @@ func TestTableAlterAddIndexOrder(t *testing.T) {
 		t.Errorf("Unexpected result for AlterIndex.Clause() without a MySQLish 8.0+ flavor: %q", clauseWithoutFlavor)
 	} else if clauseWithFlavor := ta.Clause(StatementModifiers{Flavor: FlavorPercona80}); clauseWithFlavor != expectClause {
 		t.Errorf("Unexpected result for AlterIndex.Clause() with a MySQLish 8.0+ flavor: %q", clauseWithFlavor)
+	} else if clauseMariaDB := ta.Clause(StatementModifiers{Flavor: FlavorMariaDB106}); clauseMariaDB != "ALTER INDEX `idx_ssn` IGNORED" {
+		t.Errorf("Unexpected result for AlterIndex.Clause() with MariaDB 10.6: %q", clauseMariaDB)
+	} else if clauseMariaDB := ta.Clause(StatementModifiers{Flavor: FlavorMariaDB105}); clauseMariaDB != "" {
+		t.Errorf("Unexpected result for AlterIndex.Clause() with MariaDB 10.5: %q", clauseMariaDB)
 	}
 
 	// Also change another aspect of the first index. Now this should be a DROP for
@@ -123,6 +123,11 @@ func TestUnitTableFlavors(t *testing.T) {
 	if table.Columns[3].OnUpdate != "current_timestamp(2)" || table.Columns[3].Default != "current_timestamp(2)" {
 		t.Error("MariaDB 10.3: Expected current_timestamp to be lowercased, but it is not")
 	}
+
+	table = aTableForFlavor(Flavor{VendorMariaDB, 10, 6, 4}, 1)
+	if table.CharSet != "utf8mb3" || table.Columns[1].Collation != "utf8mb3_general_ci" || !strings.HasSuffix(table.CreateStatement, "DEFAULT CHARSET=utf8mb3") {
+		t.Error("MariaDB 10.6: Expected utf8 to be displayed as utf8mb3, but it is not")
+	}
 	if table.GeneratedCreateStatement(FlavorMariaDB103) != table.CreateStatement {
 		t.Error("MariaDB 10.3: Expected function to reset CreateStatement to GeneratedCreateStatement, but it did not")
 	}
@@ -276,6 +281,15 @@ func aTableForFlavor(flavor Flavor, nextAutoInc uint64) Table {
 	if flavor.OmitIntDisplayWidth() {
 		stripIntDisplayWidths(&table)
 	}
+	if flavor.ShowUtf8mb3() {
+		for _, col := range table.Columns {
+			if col.CharSet == "utf8" {
+				col.CharSet, col.Collation = "utf8mb3", "utf8mb3_general_ci"
+			}
+		}
+		table.CharSet, table.Collation = "utf8mb3", "utf8mb3_general_ci"
+		table.CreateStatement = strings.Replace(table.CreateStatement, "DEFAULT CHARSET=utf8", "DEFAULT CHARSET=utf8mb3", 1)
+	}
 	return table
 }
 
@@ -327,6 +341,41 @@ func anotherTableForFlavor(flavor Flavor) Table {
 	return table
 }
 
+// gipkTable returns a table with a primary key generated automatically by
+// MySQL 8.0.30+ with sql_generate_invisible_primary_key enabled.
+func gipkTable() Table {
+	columns := []*Column{
+		{
+			Name:          "my_row_id",
+			TypeInDB:      "bigint unsigned",
+			AutoIncrement: true,
+			Invisible:     true,
+		},
+		{
+			Name:               "name",
+			TypeInDB:           "varchar(30)",
+			CharSet:            "utf8mb4",
+			Collation:          "utf8mb4_0900_ai_ci",
+			CollationIsDefault: true,
+		},
+	}
+	stmt := strings.Replace(`CREATE TABLE ~gipk~ (
+  ~my_row_id~ bigint unsigned NOT NULL AUTO_INCREMENT /*!80023 INVISIBLE */,
+  ~name~ varchar(30) NOT NULL,
+  PRIMARY KEY (~my_row_id~)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci`, "~", "`", -1)
+	return Table{
+		Name:               "gipk",
+		Engine:             "InnoDB",
+		CharSet:            "utf8mb4",
+		Collation:          "utf8mb4_0900_ai_ci",
+		CollationIsDefault: true,
+		Columns:            columns,
+		PrimaryKey:         primaryKey(columns[0]),
+		CreateStatement:    stmt,
+	}
+}
+
 func unsupportedTable() Table {
 	t := supportedTable()
 	t.CreateStatement += `
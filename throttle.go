@@ -0,0 +1,162 @@
+package tengo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Throttler is the interface for pausing a long-running operation between
+// individual units of work, such as between each DDL statement.
+type Throttler interface {
+	// Wait blocks until it is appropriate to proceed with the next unit of
+	// work, returning nil. If work should not proceed at all, for example
+	// because ctx was cancelled or a timeout elapsed, an error is returned.
+	Wait(ctx context.Context) error
+}
+
+// ThrottlerFunc adapts an ordinary function into a Throttler.
+type ThrottlerFunc func(ctx context.Context) error
+
+// Wait calls fn(ctx).
+func (fn ThrottlerFunc) Wait(ctx context.Context) error {
+	return fn(ctx)
+}
+
+// ErrReplicationStopped is returned by Instance.ReplicationLag if replication
+// is not running on the instance, meaning its lag is unknown.
+var ErrReplicationStopped = errors.New("Replication is not running")
+
+// ReplicationLag returns how far behind its source(s) the instance currently
+// is. If heartbeatQuery is empty, the lag is determined using the
+// Seconds_Behind_Source (or Seconds_Behind_Master) value of SHOW REPLICA
+// STATUS, taking the maximum across all replication channels; if replication
+// is stopped on any channel, ErrReplicationStopped is returned. If the
+// instance is not a replica, the lag is 0.
+// Alternatively, heartbeatQuery may be supplied to determine the lag using a
+// heartbeat table, such as one maintained by pt-heartbeat. The query must
+// return a single row with a single numeric column, the lag in seconds, which
+// may include a fractional component. For example:
+// SELECT UNIX_TIMESTAMP(NOW(6)) - UNIX_TIMESTAMP(MAX(ts)) FROM heartbeat.heartbeat
+func (instance *Instance) ReplicationLag(heartbeatQuery string) (time.Duration, error) {
+	if heartbeatQuery != "" {
+		db, release, err := instance.leasedConnectionPool("", "")
+		if err != nil {
+			return 0, err
+		}
+		defer release()
+		var seconds sql.NullFloat64
+		if err := db.QueryRow(heartbeatQuery).Scan(&seconds); err != nil {
+			return 0, fmt.Errorf("Error executing heartbeat query on %s: %s", instance, err)
+		} else if !seconds.Valid {
+			return 0, fmt.Errorf("Heartbeat query on %s returned NULL", instance)
+		}
+		return time.Duration(seconds.Float64 * float64(time.Second)), nil
+	}
+
+	statuses, err := instance.ReplicaStatus()
+	if err != nil {
+		return 0, err
+	}
+	var lag time.Duration
+	for _, rs := range statuses {
+		if !rs.Running() || !rs.SecondsBehind.Valid {
+			return 0, ErrReplicationStopped
+		}
+		if channelLag := time.Duration(rs.SecondsBehind.Int64) * time.Second; channelLag > lag {
+			lag = channelLag
+		}
+	}
+	return lag, nil
+}
+
+// DefaultLagPollInterval is used by LagThrottler if its PollInterval is zero.
+const DefaultLagPollInterval = time.Second
+
+// LagThrottler is a Throttler which pauses work while replication lag on any
+// of its replicas exceeds a threshold.
+type LagThrottler struct {
+	Replicas       []*Instance
+	MaxLag         time.Duration // Work is paused while any replica's lag exceeds this
+	PollInterval   time.Duration // How often to re-check lag while paused; DefaultLagPollInterval if 0
+	MaxWait        time.Duration // If positive, Wait returns an error after pausing this long
+	HeartbeatQuery string        // If non-empty, used to check lag; see Instance.ReplicationLag
+	IgnoreStopped  bool          // If true, replicas with stopped replication are ignored instead of treated as lagging
+
+	m        sync.Mutex
+	lastPass time.Time // last time all replicas were confirmed below MaxLag
+}
+
+// NewLagThrottler returns a LagThrottler for the supplied replicas, which
+// pauses work while any replica is lagging by more than maxLag.
+func NewLagThrottler(maxLag time.Duration, replicas ...*Instance) *LagThrottler {
+	return &LagThrottler{
+		Replicas:     replicas,
+		MaxLag:       maxLag,
+		PollInterval: DefaultLagPollInterval,
+	}
+}
+
+// Wait blocks until replication lag on all replicas is at or below MaxLag.
+// To avoid excessive querying, if lag was confirmed acceptable within the last
+// PollInterval, Wait returns immediately without checking again. An error is
+// returned if ctx is cancelled, MaxWait elapses, or lag cannot be determined.
+// It is safe to call Wait concurrently from multiple goroutines.
+func (lt *LagThrottler) Wait(ctx context.Context) error {
+	lt.m.Lock()
+	defer lt.m.Unlock()
+	interval := lt.PollInterval
+	if interval <= 0 {
+		interval = DefaultLagPollInterval
+	}
+	if time.Since(lt.lastPass) < interval {
+		return nil
+	}
+	var deadline <-chan time.Time
+	if lt.MaxWait > 0 {
+		timer := time.NewTimer(lt.MaxWait)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		lagging, status, err := lt.check()
+		if err != nil {
+			return err
+		} else if lagging == nil {
+			lt.lastPass = time.Now()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("Timed out after %s waiting for replication lag on %s to drop below %s (currently %s)", lt.MaxWait, lagging, lt.MaxLag, status)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// check returns the first replica found to be lagging by more than MaxLag,
+// along with a description of its status, or a nil Instance if no replicas are
+// lagging. Replicas with stopped replication are treated as lagging unless
+// IgnoreStopped is true.
+func (lt *LagThrottler) check() (*Instance, string, error) {
+	for _, replica := range lt.Replicas {
+		lag, err := replica.ReplicationLag(lt.HeartbeatQuery)
+		if err == ErrReplicationStopped {
+			if lt.IgnoreStopped {
+				continue
+			}
+			return replica, "replication stopped", nil
+		} else if err != nil {
+			return nil, "", err
+		}
+		if lag > lt.MaxLag {
+			return replica, lag.String(), nil
+		}
+	}
+	return nil, "", nil
+}
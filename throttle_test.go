@@ -0,0 +1,143 @@
+package tengo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLagThrottlerNoReplicas(t *testing.T) {
+	lt := NewLagThrottler(time.Second)
+	if err := lt.Wait(context.Background()); err != nil {
+		t.Errorf("Unexpected error from Wait: %v", err)
+	}
+	if lt.lastPass.IsZero() {
+		t.Error("Expected successful Wait to record time of last pass")
+	}
+}
+
+func TestLagThrottlerError(t *testing.T) {
+	// Nothing should be listening on port 1, so lag checks will fail
+	replica, err := NewInstance("mysql", "root:fakepw@tcp(127.0.0.1:1)/?timeout=1s")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	lt := NewLagThrottler(time.Second, replica)
+	if err := lt.Wait(context.Background()); err == nil {
+		t.Error("Expected error from Wait, but err was nil")
+	}
+
+	// A recent pass should skip the lag check entirely
+	lt.lastPass = time.Now()
+	if err := lt.Wait(context.Background()); err != nil {
+		t.Errorf("Unexpected error from Wait: %v", err)
+	}
+}
+
+func TestThrottlerFunc(t *testing.T) {
+	var calls int
+	expectErr := errors.New("stop")
+	var th Throttler = ThrottlerFunc(func(ctx context.Context) error {
+		calls++
+		if calls > 1 {
+			return expectErr
+		}
+		return nil
+	})
+	if err := th.Wait(context.Background()); err != nil {
+		t.Errorf("Unexpected error from first Wait: %v", err)
+	}
+	if err := th.Wait(context.Background()); err != expectErr {
+		t.Errorf("Unexpected error from second Wait: %v", err)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceReplicationLag(t *testing.T) {
+	// Test instance is not a replica, so lag is 0
+	if lag, err := s.d.ReplicationLag(""); lag != 0 || err != nil {
+		t.Errorf("Unexpected return from ReplicationLag: %s, %v", lag, err)
+	}
+	if lag, err := s.d.ReplicationLag("SELECT 1.5"); lag != 1500*time.Millisecond || err != nil {
+		t.Errorf("Unexpected return from ReplicationLag: %s, %v", lag, err)
+	}
+	if _, err := s.d.ReplicationLag("SELECT NULL"); err == nil {
+		t.Error("Expected error from ReplicationLag with NULL heartbeat, but err was nil")
+	}
+
+	lt := NewLagThrottler(time.Second, s.d.Instance)
+	lt.HeartbeatQuery = "SELECT 5"
+	lt.PollInterval = 10 * time.Millisecond
+	lt.MaxWait = 50 * time.Millisecond
+	if err := lt.Wait(context.Background()); err == nil {
+		t.Error("Expected timeout error from Wait, but err was nil")
+	}
+	lt.HeartbeatQuery = "SELECT 0.5"
+	if err := lt.Wait(context.Background()); err != nil {
+		t.Errorf("Unexpected error from Wait: %v", err)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceThrottledDrops(t *testing.T) {
+	var waits int
+	opts := BulkDropOptions{
+		MaxConcurrency: 2,
+		Throttler: ThrottlerFunc(func(ctx context.Context) error {
+			waits++
+			return nil
+		}),
+	}
+	tableCount := len(s.GetSchema(t, "testing").Tables)
+	if err := s.d.DropTablesInSchema("testing", opts); err != nil {
+		t.Fatalf("Unexpected error from DropTablesInSchema: %v", err)
+	}
+	if waits < tableCount {
+		t.Errorf("Expected Throttler to be called at least %d times, instead found %d", tableCount, waits)
+	}
+
+	// A Throttler error should prevent drops, and be returned
+	expectErr := errors.New("too much lag")
+	opts.Throttler = ThrottlerFunc(func(ctx context.Context) error {
+		return expectErr
+	})
+	if err := s.d.DropRoutinesInSchema("testing", opts); err != expectErr {
+		t.Errorf("Expected Throttler error to be returned, instead found %v", err)
+	}
+	if schema := s.GetSchema(t, "testing"); len(schema.Routines) == 0 {
+		t.Error("Expected routines to remain after Throttler error")
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceExecStatements(t *testing.T) {
+	var waits int
+	opts := ExecOptions{
+		Params: "foreign_key_checks=0",
+		Throttler: ThrottlerFunc(func(ctx context.Context) error {
+			waits++
+			return nil
+		}),
+	}
+	statements := []string{
+		"SET @tengo_test = 'hello'",
+		"CREATE TABLE exec_test (id int, greeting varchar(20))",
+		"INSERT INTO exec_test VALUES (1, @tengo_test)",
+	}
+	if err := s.d.ExecStatements("testing", statements, opts); err != nil {
+		t.Fatalf("Unexpected error from ExecStatements: %v", err)
+	}
+	if waits != len(statements) {
+		t.Errorf("Expected Throttler to be called %d times, instead found %d", len(statements), waits)
+	}
+	db, err := s.d.Connect("testing", "")
+	if err != nil {
+		t.Fatalf("Unexpected error from Connect: %v", err)
+	}
+	var greeting string
+	if err := db.QueryRow("SELECT greeting FROM exec_test WHERE id = 1").Scan(&greeting); err != nil || greeting != "hello" {
+		t.Errorf("Expected session state to persist between statements; instead found %q, %v", greeting, err)
+	}
+
+	if err := s.d.ExecStatements("testing", []string{"SELECT 1", "NOT VALID SQL", "SELECT 2"}, ExecOptions{}); err == nil {
+		t.Error("Expected error from ExecStatements with invalid statement, but err was nil")
+	}
+}
@@ -0,0 +1,367 @@
+package tengo
+
+import (
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+)
+
+// ReplicaStatus represents one replication channel of a replica, as reported
+// by SHOW REPLICA STATUS or SHOW SLAVE STATUS. Servers using multi-source
+// replication have one ReplicaStatus per channel.
+type ReplicaStatus struct {
+	ChannelName      string // "Channel_Name" in MySQL, "Connection_name" in MariaDB; empty for default channel
+	SourceHost       string
+	SourcePort       int
+	SourceServerID   uint32
+	SourceUUID       string // MySQL only
+	IORunning        bool
+	SQLRunning       bool
+	SecondsBehind    sql.NullInt64 // NULL if replication is not running
+	LastIOError      string
+	LastSQLError     string
+	AutoPosition     bool   // true if using GTID auto-positioning (MySQL) or Using_Gtid is not "No" (MariaDB)
+	ExecutedGTIDSet  string // MySQL only
+	RetrievedGTIDSet string // MySQL only
+}
+
+// Running returns true if both the IO and SQL threads of the replication
+// channel are running.
+func (rs ReplicaStatus) Running() bool {
+	return rs.IORunning && rs.SQLRunning
+}
+
+// ReplicaHost represents a replica connected to a source, as reported by SHOW
+// REPLICAS or SHOW SLAVE HOSTS. Host is only populated if the replica was
+// configured with the report_host option.
+type ReplicaHost struct {
+	ServerID       uint32
+	Host           string
+	Port           int
+	SourceServerID uint32
+	ReplicaUUID    string // MySQL only
+}
+
+// Topology describes an instance's position in a replication tree.
+type Topology struct {
+	ServerID      uint32
+	ServerUUID    string // MySQL only
+	ReadOnly      bool
+	SuperReadOnly bool   // MySQL 5.7+ and Percona Server 5.6.21+ only
+	GTIDMode      string // MySQL only; "" if not supported by the flavor
+	Sources       []ReplicaStatus
+	Replicas      []ReplicaHost
+	ReplicasKnown bool // false if the user lacks the privilege to list replicas
+}
+
+// IsReplica returns true if the instance has at least one replication channel
+// configured, regardless of whether replication is currently running.
+func (topo *Topology) IsReplica() bool {
+	return len(topo.Sources) > 0
+}
+
+// Writable returns true if the instance does not have read_only or
+// super_read_only enabled. Note that this does not mean the instance is a
+// primary: a replica may be misconfigured as writable, or a primary may be in
+// the middle of a failover.
+func (topo *Topology) Writable() bool {
+	return !topo.ReadOnly && !topo.SuperReadOnly
+}
+
+// Topology returns information on the instance's replication configuration:
+// its identity, read-only status, the sources it replicates from, and the
+// replicas that are currently connected to it. The user must have the
+// REPLICATION CLIENT privilege, otherwise an error is returned. Listing
+// replicas additionally requires REPLICATION SLAVE, which is often unavailable
+// on managed database services; if the user lacks it, the rest of the topology
+// is still returned, but with an empty Replicas and ReplicasKnown set to false.
+func (instance *Instance) Topology() (*Topology, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	topo := &Topology{}
+	vars, err := queryShowRows(db, "SHOW GLOBAL VARIABLES WHERE Variable_name IN ('server_id', 'server_uuid', 'read_only', 'super_read_only', 'gtid_mode')")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range vars {
+		value := row.get("Value")
+		switch strings.ToLower(row.get("Variable_name")) {
+		case "server_id":
+			topo.ServerID = row.getUint32("Value")
+		case "server_uuid":
+			topo.ServerUUID = value
+		case "read_only":
+			topo.ReadOnly = showBool(value)
+		case "super_read_only":
+			topo.SuperReadOnly = showBool(value)
+		case "gtid_mode":
+			topo.GTIDMode = value
+		}
+	}
+	if topo.Sources, err = instance.ReplicaStatus(); err != nil {
+		return nil, err
+	}
+	if topo.Replicas, err = instance.ReplicaHosts(); IsAccessError(err) {
+		topo.Replicas = []ReplicaHost{}
+	} else if err != nil {
+		return nil, err
+	} else {
+		topo.ReplicasKnown = true
+	}
+	return topo, nil
+}
+
+// ReplicaStatus returns the status of each replication channel of the
+// instance. If the instance is not a replica, an empty slice is returned.
+func (instance *Instance) ReplicaStatus() ([]ReplicaStatus, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	flavor := instance.Flavor()
+	var query string
+	if flavor.Vendor == VendorMariaDB && flavor.ReplicaTerminology() {
+		query = "SHOW ALL REPLICAS STATUS"
+	} else if flavor.Vendor == VendorMariaDB {
+		query = "SHOW ALL SLAVES STATUS"
+	} else if flavor.ReplicaTerminology() {
+		query = "SHOW REPLICA STATUS"
+	} else {
+		query = "SHOW SLAVE STATUS"
+	}
+	rows, err := queryShowRows(db, query)
+	if err != nil {
+		return nil, fmt.Errorf("Error executing %s on %s: %w", query, instance, err)
+	}
+
+	// Column names vary by flavor and version, so check both the newer and older
+	// name for each field
+	result := make([]ReplicaStatus, len(rows))
+	for n, row := range rows {
+		result[n] = ReplicaStatus{
+			ChannelName:      row.get("Channel_Name", "Connection_name"),
+			SourceHost:       row.get("Source_Host", "Master_Host"),
+			SourcePort:       int(row.getUint32("Source_Port", "Master_Port")),
+			SourceServerID:   row.getUint32("Source_Server_Id", "Master_Server_Id"),
+			SourceUUID:       row.get("Source_UUID", "Master_UUID"),
+			IORunning:        showBool(row.get("Replica_IO_Running", "Slave_IO_Running")),
+			SQLRunning:       showBool(row.get("Replica_SQL_Running", "Slave_SQL_Running")),
+			LastIOError:      row.get("Last_IO_Error"),
+			LastSQLError:     row.get("Last_SQL_Error"),
+			AutoPosition:     showBool(row.get("Auto_Position")) || (row.has("Using_Gtid") && !strings.EqualFold(row.get("Using_Gtid"), "no")),
+			ExecutedGTIDSet:  row.get("Executed_Gtid_Set"),
+			RetrievedGTIDSet: row.get("Retrieved_Gtid_Set"),
+		}
+		if lag := row.get("Seconds_Behind_Source", "Seconds_Behind_Master"); lag != "" {
+			if value, err := strconv.ParseInt(lag, 10, 64); err == nil {
+				result[n].SecondsBehind = sql.NullInt64{Int64: value, Valid: true}
+			}
+		}
+	}
+	return result, nil
+}
+
+// ReplicaHosts returns the replicas currently connected to the instance. The
+// Host field of each replica is only populated if the replica was configured
+// with the report_host option.
+func (instance *Instance) ReplicaHosts() ([]ReplicaHost, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	flavor := instance.Flavor()
+	query := "SHOW SLAVE HOSTS"
+	if flavor.Vendor == VendorMariaDB && flavor.ReplicaTerminology() {
+		query = "SHOW REPLICA HOSTS"
+	} else if flavor.ReplicaTerminology() {
+		query = "SHOW REPLICAS"
+	}
+	rows, err := queryShowRows(db, query)
+	if err != nil {
+		return nil, fmt.Errorf("Error executing %s on %s: %s", query, instance, err)
+	}
+	result := make([]ReplicaHost, len(rows))
+	for n, row := range rows {
+		result[n] = ReplicaHost{
+			ServerID:       row.getUint32("Server_id", "Server_Id"),
+			Host:           row.get("Host"),
+			Port:           int(row.getUint32("Port")),
+			SourceServerID: row.getUint32("Source_Id", "Master_id", "Master_Id"),
+			ReplicaUUID:    row.get("Replica_UUID", "Slave_UUID"),
+		}
+	}
+	return result, nil
+}
+
+// SourceInstances returns an Instance for each source that this instance
+// replicates from, using the same credentials and default params as this
+// instance. If this instance is not a replica, an empty slice is returned.
+func (instance *Instance) SourceInstances() ([]*Instance, error) {
+	statuses, err := instance.ReplicaStatus()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Instance, 0, len(statuses))
+	for _, rs := range statuses {
+		if rs.SourceHost == "" {
+			continue
+		}
+		source, err := instance.neighbor(rs.SourceHost, rs.SourcePort)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, source)
+	}
+	return result, nil
+}
+
+// ReplicaInstances returns an Instance for each replica currently connected to
+// this instance, using the same credentials and default params as this
+// instance. Replicas which were not configured with the report_host option
+// cannot be located, and are omitted from the result.
+func (instance *Instance) ReplicaInstances() ([]*Instance, error) {
+	hosts, err := instance.ReplicaHosts()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*Instance, 0, len(hosts))
+	for _, rh := range hosts {
+		if rh.Host == "" {
+			continue
+		}
+		replica, err := instance.neighbor(rh.Host, rh.Port)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, replica)
+	}
+	return result, nil
+}
+
+// neighbor returns a new Instance for the supplied host and port, using the
+// same driver, credentials, and default params as this instance. If the
+// instance uses a TLS config from NewInstanceWithOptions, the neighbor gets a
+// copy which verifies the neighbor's host name.
+func (instance *Instance) neighbor(host string, port int) (*Instance, error) {
+	cfg, err := mysql.ParseDSN(instance.BaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		port = 3306
+	}
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(host, strconv.Itoa(port))
+	cfg.DBName = ""
+	dsn := cfg.FormatDSN()
+	params, _ := url.ParseQuery(instance.buildParamString(""))
+
+	// The instance's TLS config verifies its own host name, so the neighbor
+	// needs its own copy verifying the neighbor's host name instead
+	var tlsConfig *tls.Config
+	if instance.tlsConfig != nil {
+		tlsConfig = instance.tlsConfig.Clone()
+		tlsConfig.ServerName = host
+		name, err := registerTLSConfig(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("tls", name)
+	}
+	if len(params) > 0 {
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + params.Encode()
+		} else {
+			dsn += "?" + params.Encode()
+		}
+	}
+	neighbor, err := NewInstance(instance.Driver, dsn)
+	if err != nil {
+		if tlsConfig != nil {
+			mysql.DeregisterTLSConfig(params.Get("tls"))
+		}
+		return nil, err
+	}
+	neighbor.passwordProvider = instance.passwordProvider
+	if tlsConfig != nil {
+		neighbor.tlsConfigName, neighbor.tlsConfig = params.Get("tls"), tlsConfig
+	}
+	return neighbor, nil
+}
+
+// showRow is a single row of output from a SHOW command, keyed by lowercased
+// column name. This permits handling output whose columns vary between
+// flavors and versions.
+type showRow map[string]string
+
+// get returns the value of the first of the supplied columns that is present
+// in the row, or "" if none are present or the value is NULL.
+func (row showRow) get(columns ...string) string {
+	for _, col := range columns {
+		if value, ok := row[strings.ToLower(col)]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func (row showRow) getUint32(columns ...string) uint32 {
+	value, _ := strconv.ParseUint(row.get(columns...), 10, 32)
+	return uint32(value)
+}
+
+func (row showRow) has(column string) bool {
+	_, ok := row[strings.ToLower(column)]
+	return ok
+}
+
+// showBool interprets the various boolean representations used in SHOW output.
+func showBool(value string) bool {
+	switch strings.ToLower(value) {
+	case "yes", "on", "1", "true":
+		return true
+	}
+	return false
+}
+
+// queryShowRows runs query, which is typically a SHOW command, and returns
+// each row of the result as a showRow. NULL values are omitted from each row.
+func queryShowRows(db *sqlx.DB, query string) ([]showRow, error) {
+	rows, err := db.Queryx(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := []showRow{}
+	for rows.Next() {
+		raw := make(map[string]interface{})
+		if err := rows.MapScan(raw); err != nil {
+			return nil, err
+		}
+		row := make(showRow, len(raw))
+		for col, value := range raw {
+			switch value := value.(type) {
+			case nil:
+				// omit NULLs
+			case []byte:
+				row[strings.ToLower(col)] = string(value)
+			default:
+				row[strings.ToLower(col)] = fmt.Sprint(value)
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,161 @@
+package tengo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestInstanceNeighbor(t *testing.T) {
+	instance, err := NewInstance("mysql", "username:password@tcp(1.2.3.4:3306)/?wait_timeout=20&timeout=1s")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	neighbor, err := instance.neighbor("replica.example.com", 3307)
+	if err != nil {
+		t.Fatalf("Unexpected error from neighbor: %v", err)
+	}
+	if neighbor.Host != "replica.example.com" || neighbor.Port != 3307 || neighbor.String() != "replica.example.com:3307" {
+		t.Errorf("Unexpected host or port on neighbor: %s", neighbor)
+	}
+	if neighbor.User != instance.User || neighbor.Password != instance.Password {
+		t.Errorf("Expected neighbor to have same credentials as original instance, instead found %s / %s", neighbor.User, neighbor.Password)
+	}
+	if neighbor.defaultParams["wait_timeout"] != "20" {
+		t.Errorf("Expected neighbor to have same default params as original instance, instead found %v", neighbor.defaultParams)
+	}
+
+	// Port 0 means default port
+	if neighbor, err = instance.neighbor("db2", 0); err != nil {
+		t.Fatalf("Unexpected error from neighbor: %v", err)
+	} else if neighbor.Port != 3306 {
+		t.Errorf("Expected neighbor to have default port, instead found %d", neighbor.Port)
+	}
+
+	// Neighbors of socket-based instances use TCP
+	instance, err = NewInstance("mysql", "username:password@unix(/var/lib/mysql/mysql.sock)/")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	if neighbor, err = instance.neighbor("1.2.3.5", 3306); err != nil {
+		t.Fatalf("Unexpected error from neighbor: %v", err)
+	} else if neighbor.SocketPath != "" || neighbor.Host != "1.2.3.5" {
+		t.Errorf("Unexpected neighbor: %s", neighbor)
+	}
+}
+
+func TestInstanceNeighborTLS(t *testing.T) {
+	instance, err := NewInstanceWithOptions(InstanceOptions{Host: "primary.example.com", User: "root", TLS: true})
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstanceWithOptions: %v", err)
+	}
+	neighbor, err := instance.neighbor("replica.example.com", 3306)
+	if err != nil {
+		t.Fatalf("Unexpected error from neighbor: %v", err)
+	}
+	if neighbor.defaultParams["tls"] == instance.defaultParams["tls"] {
+		t.Errorf("Expected neighbor to have a separate TLS config, but both use %q", instance.defaultParams["tls"])
+	}
+	neighborDSN := neighbor.BaseDSN + "?" + neighbor.buildParamString("")
+	if _, err := mysql.ParseDSN(neighborDSN); err != nil {
+		t.Fatalf("Unexpected error parsing neighbor DSN: %v", err)
+	} else if neighbor.tlsConfig.ServerName != "replica.example.com" {
+		t.Errorf("Expected neighbor TLS config to verify neighbor's host name, instead found %q", neighbor.tlsConfig.ServerName)
+	}
+	if instance.tlsConfig.ServerName != "primary.example.com" {
+		t.Errorf("Expected original TLS config to be unchanged, instead found ServerName %q", instance.tlsConfig.ServerName)
+	}
+
+	// CloseAll deregisters the config, but it is registered again upon use
+	neighbor.CloseAll()
+	if _, err := mysql.ParseDSN(neighborDSN); err == nil {
+		t.Error("Expected TLS config to be deregistered by CloseAll, but DSN still parsed successfully")
+	}
+	if _, err := neighbor.connector(neighborDSN, nil); err != nil {
+		t.Errorf("Unexpected error from connector after CloseAll: %v", err)
+	}
+}
+
+func TestShowRow(t *testing.T) {
+	row := showRow{
+		"master_host":      "db1",
+		"master_port":      "3306",
+		"slave_io_running": "Yes",
+		"using_gtid":       "Slave_Pos",
+	}
+	if host := row.get("Source_Host", "Master_Host"); host != "db1" {
+		t.Errorf("Expected fallback column to be used, instead found %q", host)
+	}
+	if port := row.getUint32("Source_Port", "Master_Port"); port != 3306 {
+		t.Errorf("Unexpected port %d", port)
+	}
+	if row.get("Seconds_Behind_Source", "Seconds_Behind_Master") != "" || row.getUint32("Nope") != 0 {
+		t.Error("Expected missing columns to yield zero values")
+	}
+	if !row.has("Using_Gtid") || row.has("Auto_Position") {
+		t.Error("Unexpected result from has")
+	}
+	if !showBool(row.get("Slave_IO_Running")) || showBool("No") || !showBool("ON") || showBool("") {
+		t.Error("Unexpected result from showBool")
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceTopology(t *testing.T) {
+	topo, err := s.d.Topology()
+	if err != nil {
+		t.Fatalf("Unexpected error from Topology: %v", err)
+	}
+	if topo.IsReplica() || len(topo.Replicas) > 0 || !topo.ReplicasKnown {
+		t.Errorf("Expected standalone test instance to have no sources or replicas, instead found %+v", topo)
+	}
+	if !topo.Writable() {
+		t.Error("Expected test instance to be writable")
+	}
+	flavor := s.d.Flavor()
+	if flavor.MySQLishMinVersion(5, 6) && topo.ServerUUID == "" {
+		t.Error("Expected server UUID to be populated")
+	} else if flavor.Vendor == VendorMariaDB && (topo.ServerUUID != "" || topo.GTIDMode != "") {
+		t.Errorf("Expected MariaDB to lack server UUID and GTID mode, instead found %q / %q", topo.ServerUUID, topo.GTIDMode)
+	}
+	if sources, err := s.d.SourceInstances(); err != nil || len(sources) > 0 {
+		t.Errorf("Unexpected return from SourceInstances: %v / %v", sources, err)
+	}
+	if replicas, err := s.d.ReplicaInstances(); err != nil || len(replicas) > 0 {
+		t.Errorf("Unexpected return from ReplicaInstances: %v / %v", replicas, err)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceTopologyWithoutReplicationSlave(t *testing.T) {
+	db, err := s.d.ConnectionPool("", "")
+	if err != nil {
+		t.Fatalf("Unable to connect: %v", err)
+	}
+	defer db.Close()
+	db.Exec("DROP USER 'tengo_topo'@'%'") // in case left over from a prior run
+	if _, err := db.Exec("CREATE USER 'tengo_topo'@'%'"); err != nil {
+		t.Fatalf("Unable to create user: %v", err)
+	}
+	defer db.Exec("DROP USER 'tengo_topo'@'%'")
+	if _, err := db.Exec("GRANT REPLICATION CLIENT ON *.* TO 'tengo_topo'@'%'"); err != nil {
+		t.Fatalf("Unable to grant privileges: %v", err)
+	}
+
+	inst, err := NewInstance("mysql", fmt.Sprintf("tengo_topo@tcp(%s:%d)/", s.d.Host, s.d.Port))
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	defer inst.CloseAll()
+	topo, err := inst.Topology()
+	if IsAccessError(err) {
+		t.Skipf("Skipping test: REPLICATION CLIENT is insufficient for replica status on %s: %v", s.d.Image, err)
+	} else if err != nil {
+		t.Fatalf("Unexpected error from Topology: %v", err)
+	}
+	if topo.ReplicasKnown || len(topo.Replicas) > 0 {
+		t.Errorf("Expected replicas to be unknown, instead found %+v", topo)
+	}
+	if !topo.Writable() || topo.ServerID == 0 {
+		t.Errorf("Expected remainder of topology to be populated, instead found %+v", topo)
+	}
+}
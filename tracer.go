@@ -0,0 +1,234 @@
+package tengo
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"sync"
+	"time"
+)
+
+// QueryTracer is the interface for observing every statement executed by an
+// Instance's connection pools, including introspection queries. This permits
+// logging, timing, and attributing database load.
+type QueryTracer interface {
+	// BeforeQuery is called before executing query using a connection whose
+	// default database is schema (which may be ""). The returned context is
+	// later passed to AfterQuery, permitting a tracer to propagate state such as
+	// a span or start time.
+	BeforeQuery(ctx context.Context, schema, query string) context.Context
+
+	// AfterQuery is called once the statement has completed. For statements
+	// returning rows, this occurs once the rows have been fully read or closed.
+	AfterQuery(ctx context.Context, trace QueryTrace)
+}
+
+// QueryTrace describes a completed statement, as passed to
+// QueryTracer.AfterQuery.
+type QueryTrace struct {
+	Schema   string // default database of the connection, or "" if none
+	Query    string
+	Start    time.Time
+	Duration time.Duration
+	Rows     int64 // rows returned by a query, or affected by a write; -1 if unknown
+	Err      error
+}
+
+// SetQueryTracer configures a tracer for all statements executed by this
+// instance's connection pools. Only pools created after this call are traced,
+// so typically it should be called immediately after creating the Instance.
+// Alternatively, CloseAll may be used to discard any previously-cached pools.
+// Supply nil to disable tracing of subsequently-created pools.
+func (instance *Instance) SetQueryTracer(tracer QueryTracer) {
+	instance.m.Lock()
+	defer instance.m.Unlock()
+	instance.tracer = tracer
+}
+
+// tracedConn is the set of interfaces implemented by the mysql driver's
+// connections, which tracingConn must pass through to avoid changing the
+// behavior of database/sql.
+type tracedConn interface {
+	driver.Conn
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.QueryerContext
+	driver.ExecerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.NamedValueChecker
+}
+
+// tracedStmt is the set of interfaces implemented by the mysql driver's
+// prepared statements.
+type tracedStmt interface {
+	driver.Stmt
+	driver.StmtExecContext
+	driver.StmtQueryContext
+	driver.NamedValueChecker
+}
+
+// tracedRows is the set of interfaces implemented by the mysql driver's
+// result sets.
+type tracedRows interface {
+	driver.Rows
+	driver.RowsNextResultSet
+	driver.RowsColumnTypeDatabaseTypeName
+	driver.RowsColumnTypeNullable
+	driver.RowsColumnTypePrecisionScale
+	driver.RowsColumnTypeScanType
+}
+
+// tracingConnector wraps a driver.Connector, so that all statements executed
+// using its connections are reported to a QueryTracer.
+type tracingConnector struct {
+	driver.Connector
+	tracer      QueryTracer
+	schema      string
+	interpolate bool // true if the interpolateParams DSN option is enabled
+}
+
+// Connect satisfies the driver.Connector interface.
+func (tc *tracingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := tc.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tconn, ok := conn.(tracedConn); ok {
+		return &tracingConn{tracedConn: tconn, tc: tc}, nil
+	}
+	return conn, nil
+}
+
+// trace calls the tracer's BeforeQuery, and returns a function which must be
+// called upon completion of the statement.
+func (tc *tracingConnector) trace(ctx context.Context, query string) func(rows int64, err error) {
+	ctx = tc.tracer.BeforeQuery(ctx, tc.schema, query)
+	start := time.Now()
+	var once sync.Once
+	return func(rows int64, err error) {
+		once.Do(func() {
+			tc.tracer.AfterQuery(ctx, QueryTrace{
+				Schema:   tc.schema,
+				Query:    query,
+				Start:    start,
+				Duration: time.Since(start),
+				Rows:     rows,
+				Err:      err,
+			})
+		})
+	}
+}
+
+type tracingConn struct {
+	tracedConn
+	tc *tracingConnector
+}
+
+func (c *tracingConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *tracingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.tracedConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	if tstmt, ok := stmt.(tracedStmt); ok {
+		return &tracingStmt{tracedStmt: tstmt, tc: c.tc, query: query}, nil
+	}
+	return stmt, nil
+}
+
+func (c *tracingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	// The driver requires a prepared statement for args, unless interpolating;
+	// in that case database/sql will prepare the statement, which gets traced
+	// by tracingStmt instead
+	if len(args) > 0 && !c.tc.interpolate {
+		return nil, driver.ErrSkip
+	}
+	finish := c.tc.trace(ctx, query)
+	rows, err := c.tracedConn.QueryContext(ctx, query, args)
+	return wrapTracedRows(rows, err, finish)
+}
+
+func (c *tracingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 && !c.tc.interpolate {
+		return nil, driver.ErrSkip
+	}
+	finish := c.tc.trace(ctx, query)
+	result, err := c.tracedConn.ExecContext(ctx, query, args)
+	finish(rowsAffected(result, err), err)
+	return result, err
+}
+
+type tracingStmt struct {
+	tracedStmt
+	tc    *tracingConnector
+	query string
+}
+
+func (s *tracingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	finish := s.tc.trace(ctx, s.query)
+	rows, err := s.tracedStmt.QueryContext(ctx, args)
+	return wrapTracedRows(rows, err, finish)
+}
+
+func (s *tracingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	finish := s.tc.trace(ctx, s.query)
+	result, err := s.tracedStmt.ExecContext(ctx, args)
+	finish(rowsAffected(result, err), err)
+	return result, err
+}
+
+type tracingRows struct {
+	tracedRows
+	finish func(rows int64, err error)
+	count  int64
+	err    error
+}
+
+// wrapTracedRows wraps rows so that finish is called once they are closed. If
+// the query failed, or the driver's rows cannot be wrapped, finish is called
+// immediately.
+func wrapTracedRows(rows driver.Rows, err error, finish func(int64, error)) (driver.Rows, error) {
+	if err != nil {
+		finish(0, err)
+		return nil, err
+	}
+	if trows, ok := rows.(tracedRows); ok {
+		return &tracingRows{tracedRows: trows, finish: finish}, nil
+	}
+	finish(-1, nil)
+	return rows, nil
+}
+
+func (r *tracingRows) Next(dest []driver.Value) error {
+	err := r.tracedRows.Next(dest)
+	if err == nil {
+		r.count++
+	} else if err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *tracingRows) Close() error {
+	err := r.tracedRows.Close()
+	if r.err == nil {
+		r.err = err
+	}
+	r.finish(r.count, r.err)
+	return err
+}
+
+func rowsAffected(result driver.Result, err error) int64 {
+	if err != nil || result == nil {
+		return 0
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return n
+}
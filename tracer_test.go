@@ -0,0 +1,197 @@
+package tengo
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// recordingTracer is a QueryTracer which records all traces.
+type recordingTracer struct {
+	m      sync.Mutex
+	before int
+	traces []QueryTrace
+}
+
+func (rt *recordingTracer) BeforeQuery(ctx context.Context, schema, query string) context.Context {
+	rt.m.Lock()
+	defer rt.m.Unlock()
+	rt.before++
+	return ctx
+}
+
+func (rt *recordingTracer) AfterQuery(ctx context.Context, trace QueryTrace) {
+	rt.m.Lock()
+	defer rt.m.Unlock()
+	rt.traces = append(rt.traces, trace)
+}
+
+// fakeTracedConn implements tracedConn without a database. Queries return two
+// rows, unless the query text contains "fail".
+type fakeTracedConn struct{}
+
+var errFakeQuery = errors.New("query failed")
+
+func (fakeTracedConn) Prepare(query string) (driver.Stmt, error)   { return nil, errFakeQuery }
+func (fakeTracedConn) Close() error                                { return nil }
+func (fakeTracedConn) Begin() (driver.Tx, error)                   { return nil, errFakeQuery }
+func (fakeTracedConn) Ping(ctx context.Context) error              { return nil }
+func (fakeTracedConn) ResetSession(ctx context.Context) error      { return nil }
+func (fakeTracedConn) CheckNamedValue(nv *driver.NamedValue) error { return nil }
+func (c fakeTracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.Prepare(query)
+}
+func (c fakeTracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+func (fakeTracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errFakeQuery
+	}
+	return &fakeRows{remaining: 2}, nil
+}
+func (fakeTracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "fail") {
+		return nil, errFakeQuery
+	}
+	return driver.RowsAffected(3), nil
+}
+
+type fakeRows struct {
+	remaining int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"n"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.remaining == 0 {
+		return io.EOF
+	}
+	dest[0] = int64(r.remaining)
+	r.remaining--
+	return nil
+}
+func (r *fakeRows) HasNextResultSet() bool                                  { return false }
+func (r *fakeRows) NextResultSet() error                                    { return io.EOF }
+func (r *fakeRows) ColumnTypeDatabaseTypeName(index int) string             { return "BIGINT" }
+func (r *fakeRows) ColumnTypeNullable(index int) (nullable, ok bool)        { return false, true }
+func (r *fakeRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) { return 0, 0, false }
+func (r *fakeRows) ColumnTypeScanType(index int) reflect.Type               { return reflect.TypeOf(int64(0)) }
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(ctx context.Context) (driver.Conn, error) { return fakeTracedConn{}, nil }
+func (fakeConnector) Driver() driver.Driver                            { return mysql.MySQLDriver{} }
+
+func TestTracingConnector(t *testing.T) {
+	tracer := &recordingTracer{}
+	db := sql.OpenDB(&tracingConnector{Connector: fakeConnector{}, tracer: tracer, schema: "testing", interpolate: true})
+	defer db.Close()
+
+	rows, err := db.Query("SELECT n FROM foo")
+	if err != nil {
+		t.Fatalf("Unexpected error from Query: %v", err)
+	}
+	for rows.Next() {
+		if len(tracer.traces) > 0 {
+			t.Error("AfterQuery unexpectedly called before rows were closed")
+		}
+	}
+	rows.Close()
+	if _, err := db.Exec("UPDATE foo SET n = n + 1"); err != nil {
+		t.Fatalf("Unexpected error from Exec: %v", err)
+	}
+	if _, err := db.Query("SELECT fail"); err != errFakeQuery {
+		t.Fatalf("Unexpected error from Query: %v", err)
+	}
+
+	if tracer.before != 3 || len(tracer.traces) != 3 {
+		t.Fatalf("Expected 3 calls each to BeforeQuery and AfterQuery, instead found %d and %d", tracer.before, len(tracer.traces))
+	}
+	expected := []QueryTrace{
+		{Schema: "testing", Query: "SELECT n FROM foo", Rows: 2},
+		{Schema: "testing", Query: "UPDATE foo SET n = n + 1", Rows: 3},
+		{Schema: "testing", Query: "SELECT fail", Rows: 0, Err: errFakeQuery},
+	}
+	for n, trace := range tracer.traces {
+		if trace.Start.IsZero() || trace.Duration < 0 {
+			t.Errorf("Trace %d: unexpected timing fields %+v", n, trace)
+		}
+		trace.Start, trace.Duration = expected[n].Start, expected[n].Duration
+		if trace != expected[n] {
+			t.Errorf("Trace %d: expected %+v, found %+v", n, expected[n], trace)
+		}
+	}
+
+	// Without interpolation, queries with args must be deferred to prepared
+	// statements, and not traced at the connection level
+	tc := &tracingConn{tracedConn: fakeTracedConn{}, tc: &tracingConnector{tracer: tracer}}
+	if _, err := tc.QueryContext(context.Background(), "SELECT ?", []driver.NamedValue{{Ordinal: 1, Value: int64(1)}}); err != driver.ErrSkip {
+		t.Errorf("Expected ErrSkip from QueryContext, instead found %v", err)
+	}
+	if tracer.before != 3 {
+		t.Errorf("Expected BeforeQuery to not be called for skipped query, but it was")
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceQueryTracer(t *testing.T) {
+	inst, err := NewInstance("mysql", s.d.DSN())
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	defer inst.CloseAll()
+	tracer := &recordingTracer{}
+	inst.SetQueryTracer(tracer)
+	if _, err := inst.Schema("testing"); err != nil {
+		t.Fatalf("Unexpected error from Schema: %v", err)
+	}
+	var foundShowCreate bool
+	for _, trace := range tracer.traces {
+		if trace.Err != nil {
+			t.Errorf("Unexpected error in trace %+v", trace)
+		}
+		if strings.HasPrefix(trace.Query, "SHOW CREATE TABLE") && trace.Schema == "testing" && trace.Rows == 1 {
+			foundShowCreate = true
+		}
+	}
+	if !foundShowCreate {
+		t.Errorf("Expected SHOW CREATE TABLE to be traced, but it was not found among %d traces", len(tracer.traces))
+	}
+	if tracer.before != len(tracer.traces) {
+		t.Errorf("Mismatched calls to BeforeQuery (%d) and AfterQuery (%d)", tracer.before, len(tracer.traces))
+	}
+}
+
+func TestSetQueryTracerConcurrent(t *testing.T) {
+	// Pools can't actually connect here, but the tracer is read before the
+	// connection attempt; run with -race to confirm this is safe
+	instance, err := NewInstance("mysql", "root:fakepw@tcp(127.0.0.1:1)/?timeout=100ms")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 20; n++ {
+			instance.SetQueryTracer(&recordingTracer{})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 20; n++ {
+			if db, err := instance.ConnectionPool("", ""); err == nil {
+				db.Close()
+			}
+		}
+	}()
+	wg.Wait()
+}
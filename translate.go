@@ -0,0 +1,337 @@
+package tengo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TranslationIssue describes an aspect of an object which could not be
+// translated exactly from one flavor to another. Depending on the feature, the
+// translated object either omits it, or retains it in a form which the target
+// flavor may reject.
+type TranslationIssue struct {
+	ObjectKey ObjectKey
+	Message   string
+}
+
+func (ti TranslationIssue) String() string {
+	return fmt.Sprintf("%s: %s", ti.ObjectKey, ti.Message)
+}
+
+// TranslateSchema returns a copy of schema, which should have been introspected
+// from flavor from, adjusted to represent the equivalent schema in flavor to.
+// Tables' CreateStatement fields are regenerated for the target flavor. The
+// supplied schema is not modified.
+//
+// Differences in how flavors display the same definitions are handled
+// automatically, including int display widths, utf8mb3 naming, utf8mb4
+// default collations, default value expressions, invisible column and index
+// syntax, foreign key rules, and partitioning comment wrappers. Features which
+// the target flavor lacks are reported in the returned issues. Tables with
+// UnsupportedDDL, as well as routines, are copied without modification.
+func TranslateSchema(schema *Schema, from, to Flavor) (*Schema, []TranslationIssue) {
+	var issues []TranslationIssue
+	translated := &Schema{
+		Name:     schema.Name,
+		Tables:   make([]*Table, len(schema.Tables)),
+		Routines: make([]*Routine, len(schema.Routines)),
+	}
+	translated.CharSet, translated.Collation = translateUtf8Name(schema.CharSet, schema.Collation, to)
+	translated.Collation = translateCollation(translated.CharSet, translated.Collation, to)
+	if translated.Collation != schema.Collation && translated.CharSet == schema.CharSet {
+		issues = append(issues, TranslationIssue{
+			ObjectKey: ObjectKey{Type: ObjectTypeDatabase, Name: schema.Name},
+			Message:   fmt.Sprintf("default collation %s is not available, using %s instead", schema.Collation, translated.Collation),
+		})
+	}
+	for n, t := range schema.Tables {
+		var tableIssues []TranslationIssue
+		translated.Tables[n], tableIssues = translateTable(t, from, to)
+		issues = append(issues, tableIssues...)
+	}
+	for n, r := range schema.Routines {
+		translated.Routines[n] = r
+		if from.Vendor != to.Vendor {
+			issues = append(issues, TranslationIssue{
+				ObjectKey: ObjectKey{Type: r.Type, Name: r.Name},
+				Message:   "routine body copied without translation",
+			})
+		}
+	}
+	return translated, issues
+}
+
+// translateTable returns a deep copy of t, converted from one flavor to
+// another, along with any issues preventing an exact translation.
+func translateTable(t *Table, from, to Flavor) (*Table, []TranslationIssue) {
+	var messages []string
+	problem := func(format string, a ...interface{}) {
+		messages = append(messages, fmt.Sprintf(format, a...))
+	}
+	translated := *t
+	if t.UnsupportedDDL {
+		problem("table uses features unsupported by this package; CREATE TABLE copied verbatim")
+		return &translated, tableIssues(t, messages)
+	}
+
+	translated.CharSet, translated.Collation = translateUtf8Name(t.CharSet, t.Collation, to)
+	translated.Collation = translateCollation(translated.CharSet, translated.Collation, to)
+	translated.CollationIsDefault = collationIsDefault(translated.CharSet, translated.Collation, t.CollationIsDefault, to)
+	if translated.Collation != t.Collation && translated.CharSet == t.CharSet {
+		problem("default collation %s is not available, using %s instead", t.Collation, translated.Collation)
+	}
+
+	var inlineChecks []*Check
+	translated.Columns = make([]*Column, len(t.Columns))
+	for n, col := range t.Columns {
+		c := *col
+		if strings.Contains(c.TypeInDB, "int") || strings.HasPrefix(c.TypeInDB, "year") {
+			if to.OmitIntDisplayWidth() && !from.OmitIntDisplayWidth() {
+				c.TypeInDB = StripDisplayWidth(c.TypeInDB)
+			} else if from.OmitIntDisplayWidth() && !to.OmitIntDisplayWidth() {
+				c.TypeInDB = addDisplayWidth(c.TypeInDB)
+			}
+		}
+		c.CharSet, c.Collation = translateUtf8Name(c.CharSet, c.Collation, to)
+		c.Collation = translateCollation(c.CharSet, c.Collation, to)
+		c.CollationIsDefault = collationIsDefault(c.CharSet, c.Collation, c.CollationIsDefault, to)
+		if c.Collation != col.Collation && c.CharSet == col.CharSet {
+			problem("column %s collation %s is not available, using %s instead", EscapeIdentifier(c.Name), col.Collation, c.Collation)
+		}
+		c.Default, c.OnUpdate = translateDefault(&c, from, to)
+		if c.Default == "" && col.Default != "" && col.Default != "NULL" {
+			problem("column %s default %s is not supported, removing it", EscapeIdentifier(c.Name), col.Default)
+		}
+		if c.Invisible && !to.InvisibleColumns() {
+			problem("column %s is invisible, but invisible columns are not supported; making it visible", EscapeIdentifier(c.Name))
+			c.Invisible = false
+		}
+		if c.GenerationExpr != "" && !to.GeneratedColumns() {
+			problem("column %s is a generated column, which is not supported", EscapeIdentifier(c.Name))
+		}
+		if c.Compression != "" && from.Vendor != to.Vendor {
+			problem("column %s uses %s column compression, which is not supported; removing it", EscapeIdentifier(c.Name), from.Vendor)
+			c.Compression = ""
+		}
+		if c.CheckClause != "" && to.Vendor != VendorMariaDB {
+			// Non-MariaDB flavors convert inline checks into table-level constraints,
+			// using a generated name
+			inlineChecks = append(inlineChecks, &Check{
+				Name:     fmt.Sprintf("%s_chk_%d", t.Name, len(inlineChecks)+1),
+				Clause:   c.CheckClause,
+				Enforced: true,
+			})
+			c.CheckClause = ""
+		}
+		translated.Columns[n] = &c
+	}
+
+	translated.PrimaryKey = translateIndex(t.PrimaryKey, to, problem)
+	translated.SecondaryIndexes = make([]*Index, len(t.SecondaryIndexes))
+	for n, idx := range t.SecondaryIndexes {
+		translated.SecondaryIndexes[n] = translateIndex(idx, to, problem)
+	}
+
+	translated.ForeignKeys = make([]*ForeignKey, len(t.ForeignKeys))
+	for n, fk := range t.ForeignKeys {
+		fkCopy := *fk
+		translated.ForeignKeys[n] = &fkCopy
+	}
+	if to.SortedForeignKeys() && !from.SortedForeignKeys() {
+		sort.Slice(translated.ForeignKeys, func(i, j int) bool {
+			return translated.ForeignKeys[i].Name < translated.ForeignKeys[j].Name
+		})
+	}
+
+	translated.Checks = nil
+	for _, cc := range append(inlineChecks, t.Checks...) {
+		if !to.HasCheckConstraints() {
+			problem("check constraint %s is not supported, removing it", EscapeIdentifier(cc.Name))
+			continue
+		} else if !cc.Enforced && to.Vendor == VendorMariaDB {
+			problem("check constraint %s is NOT ENFORCED, which is not supported; removing it", EscapeIdentifier(cc.Name))
+			continue
+		}
+		ccCopy := *cc
+		translated.Checks = append(translated.Checks, &ccCopy)
+	}
+
+	if t.Partitioning != nil {
+		partitioning := *t.Partitioning
+		partitioning.Partitions = make([]*Partition, len(t.Partitioning.Partitions))
+		for n, p := range t.Partitioning.Partitions {
+			pCopy := *p
+			partitioning.Partitions[n] = &pCopy
+		}
+		translated.Partitioning = &partitioning
+	}
+
+	translated.CreateStatement = translated.GeneratedCreateStatement(to)
+	return &translated, tableIssues(t, messages)
+}
+
+// tableIssues converts messages about table t into TranslationIssues.
+func tableIssues(t *Table, messages []string) []TranslationIssue {
+	issues := make([]TranslationIssue, len(messages))
+	for n, msg := range messages {
+		issues[n] = TranslationIssue{ObjectKey: ObjectKey{Type: ObjectTypeTable, Name: t.Name}, Message: msg}
+	}
+	return issues
+}
+
+// translateIndex returns a copy of idx with any attributes unsupported by
+// flavor removed, reporting each via problem.
+func translateIndex(idx *Index, flavor Flavor, problem func(string, ...interface{})) *Index {
+	if idx == nil {
+		return nil
+	}
+	translated := *idx
+	translated.Parts = make([]IndexPart, len(idx.Parts))
+	copy(translated.Parts, idx.Parts)
+	if translated.Invisible && !flavor.InvisibleIndexes() {
+		problem("index %s is invisible, which is not supported; making it visible", EscapeIdentifier(idx.Name))
+		translated.Invisible = false
+	}
+	for n := range translated.Parts {
+		part := &translated.Parts[n]
+		if part.Expression != "" && !flavor.FunctionalIndexes() {
+			problem("index %s has functional part %s, which is not supported", EscapeIdentifier(idx.Name), part.Expression)
+		}
+		if part.Descending && !flavor.DescendingIndexes() {
+			problem("index %s has a descending part, which is not supported; using ascending", EscapeIdentifier(idx.Name))
+			part.Descending = false
+		}
+	}
+	return &translated
+}
+
+// translateUtf8Name returns the name of the utf8 character set and its
+// collation as displayed by flavor, which may use either utf8 or utf8mb3 in
+// names. Other character sets are returned unchanged.
+func translateUtf8Name(charSet, collation string, flavor Flavor) (string, string) {
+	if charSet == "utf8" && flavor.ShowUtf8mb3() {
+		return "utf8mb3", "utf8mb3_" + strings.TrimPrefix(collation, "utf8_")
+	} else if charSet == "utf8mb3" && !flavor.ShowUtf8mb3() {
+		return "utf8", "utf8_" + strings.TrimPrefix(collation, "utf8mb3_")
+	}
+	return charSet, collation
+}
+
+// translateCollation returns the collation to use in flavor in place of
+// collation. Only the MySQL 8 utf8mb4_0900 collations currently require
+// translation; all other collations are returned unchanged.
+func translateCollation(charSet, collation string, flavor Flavor) string {
+	if charSet != "utf8mb4" || !strings.HasPrefix(collation, "utf8mb4_0900_") || flavor.HasDataDictionary() {
+		return collation
+	}
+	if collation == "utf8mb4_0900_bin" {
+		return "utf8mb4_bin"
+	}
+	return flavor.DefaultUtf8mb4Collation()
+}
+
+// collationIsDefault returns whether collation is the default for charSet in
+// flavor. Only utf8mb4's default collation varies between flavors, so the
+// supplied previous value is returned for other character sets.
+func collationIsDefault(charSet, collation string, previous bool, flavor Flavor) bool {
+	if charSet != "utf8mb4" {
+		return previous
+	}
+	return collation == flavor.DefaultUtf8mb4Collation()
+}
+
+// defaultIntDisplayWidths maps integer types to their display widths in flavors
+// which show them, for signed and unsigned variants respectively.
+var defaultIntDisplayWidths = map[string][2]int{
+	"tinyint":   {4, 3},
+	"smallint":  {6, 5},
+	"mediumint": {9, 8},
+	"int":       {11, 10},
+	"bigint":    {20, 20},
+}
+
+// addDisplayWidth is the inverse of StripDisplayWidth: it adds the default
+// display width to an integer or year type which lacks one. Other types are
+// returned unchanged.
+func addDisplayWidth(colType string) string {
+	if colType == "year" {
+		return "year(4)"
+	}
+	baseType, modifier := colType, ""
+	if pos := strings.IndexByte(colType, ' '); pos > -1 {
+		baseType, modifier = colType[0:pos], colType[pos:]
+	}
+	widths, ok := defaultIntDisplayWidths[baseType]
+	if !ok {
+		return colType
+	}
+	width := widths[0]
+	if modifier == " unsigned" {
+		width = widths[1]
+	}
+	return fmt.Sprintf("%s(%d)%s", baseType, width, modifier)
+}
+
+// translateDefault returns the column's default and on-update values as they
+// would be represented in flavor to. MariaDB 10.2+ represents current_timestamp
+// in lowercase with parens, and numeric defaults without quotes. Text and blob
+// defaults are only permitted in some flavors; an empty default is returned if
+// no equivalent exists.
+func translateDefault(col *Column, from, to Flavor) (defaultValue, onUpdate string) {
+	defaultValue, onUpdate = col.Default, col.OnUpdate
+	fromMaria, toMaria := from.VendorMinVersion(VendorMariaDB, 10, 2), to.VendorMinVersion(VendorMariaDB, 10, 2)
+	if fromMaria != toMaria {
+		defaultValue = translateCurrentTimestamp(defaultValue, toMaria)
+		onUpdate = translateCurrentTimestamp(onUpdate, toMaria)
+		if _, isNumeric := col.MaxIntegerValue(); isNumeric || strings.HasPrefix(col.TypeInDB, "decimal") || strings.HasPrefix(col.TypeInDB, "float") || strings.HasPrefix(col.TypeInDB, "double") {
+			if unquoted := strings.Trim(defaultValue, "'"); len(unquoted) > 0 && defaultValue != "NULL" {
+				if _, err := strconv.ParseFloat(unquoted, 64); err == nil {
+					if toMaria {
+						defaultValue = unquoted
+					} else {
+						defaultValue = "'" + unquoted + "'"
+					}
+				}
+			}
+		}
+	}
+
+	isBlob := strings.HasSuffix(col.TypeInDB, "blob") || strings.HasSuffix(col.TypeInDB, "text")
+	if isBlob && from.AllowBlobDefaults() && !to.AllowBlobDefaults() {
+		if defaultValue == "NULL" {
+			defaultValue = ""
+		} else if defaultValue != "" && defaultValue[0] != '(' {
+			if to.MySQLishMinVersion(8, 0, 13) {
+				defaultValue = "(" + defaultValue + ")"
+			} else {
+				defaultValue = ""
+			}
+		}
+	} else if isBlob && !from.AllowBlobDefaults() && to.AllowBlobDefaults() {
+		if defaultValue == "" && col.Nullable && !col.AutoIncrement && col.GenerationExpr == "" {
+			defaultValue = "NULL"
+		}
+	}
+	return defaultValue, onUpdate
+}
+
+// translateCurrentTimestamp converts CURRENT_TIMESTAMP expressions between
+// MariaDB 10.2+ format and the format used by other flavors.
+func translateCurrentTimestamp(value string, toMaria bool) string {
+	lower := strings.ToLower(value)
+	if !strings.HasPrefix(lower, "current_timestamp") {
+		return value
+	}
+	if toMaria {
+		if lower == "current_timestamp" {
+			return "current_timestamp()"
+		}
+		return lower
+	}
+	if lower == "current_timestamp()" {
+		return "CURRENT_TIMESTAMP"
+	}
+	return strings.ToUpper(value)
+}
@@ -0,0 +1,137 @@
+package tengo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslateSchemaFixtures(t *testing.T) {
+	flavor8019 := Flavor{VendorMySQL, 8, 0, 19}
+	flavors := []Flavor{FlavorMySQL57, FlavorMySQL80, flavor8019, {VendorMySQL, 8, 0, 32}, FlavorMariaDB101, FlavorMariaDB105, {VendorMariaDB, 10, 6, 4}}
+	for _, from := range flavors {
+		for _, to := range flavors {
+			fromTable := aTableForFlavor(from, 1)
+			expected := aTableForFlavor(to, 1)
+			if from.OmitIntDisplayWidth() && !to.OmitIntDisplayWidth() {
+				// The fixture's tinyint(1) unsigned loses its non-default display width
+				// in flavors which omit widths, so the default width is restored instead
+				expected.Columns[5].TypeInDB = "tinyint(3) unsigned"
+				expected.CreateStatement = strings.Replace(expected.CreateStatement, "tinyint(1) unsigned", "tinyint(3) unsigned", 1)
+			}
+			fromSchema := aSchema("translate", &fromTable)
+			translated, issues := TranslateSchema(&fromSchema, from, to)
+			if len(issues) > 0 {
+				t.Errorf("Translating %s to %s: unexpected issues %v", from, to, issues)
+			}
+			actual := translated.Tables[0]
+			if actual.CreateStatement != expected.CreateStatement {
+				t.Errorf("Translating %s to %s: CreateStatement mismatch\nExpected:\n%s\nActual:\n%s", from, to, expected.CreateStatement, actual.CreateStatement)
+			}
+			for n, col := range actual.Columns {
+				if !col.Equals(expected.Columns[n]) {
+					t.Errorf("Translating %s to %s: column mismatch\nExpected: %+v\nActual:   %+v", from, to, *expected.Columns[n], *col)
+				}
+			}
+			if fromTable.CreateStatement != aTableForFlavor(from, 1).CreateStatement {
+				t.Errorf("Translating %s to %s unexpectedly modified the original table", from, to)
+			}
+		}
+	}
+}
+
+func TestTranslateSchemaIssues(t *testing.T) {
+	table := aTableForFlavor(FlavorMySQL80, 1)
+	table.CharSet, table.Collation, table.CollationIsDefault = "utf8mb4", "utf8mb4_0900_ai_ci", true
+	table.Columns[0].Invisible = true
+	table.SecondaryIndexes[0].Invisible = true
+	table.SecondaryIndexes[1].Parts[0].Descending = true
+	table.Checks = []*Check{
+		{Name: "alivecheck", Clause: "`alive` != 0", Enforced: true},
+		{Name: "ssncheck", Clause: "`ssn` > ''", Enforced: false},
+	}
+	table.CreateStatement = table.GeneratedCreateStatement(FlavorMySQL80)
+	schema := aSchema("translate", &table)
+	schema.CharSet, schema.Collation = "utf8mb4", "utf8mb4_0900_bin"
+
+	translated, issues := TranslateSchema(&schema, FlavorMySQL80, FlavorMariaDB105)
+	if translated.Collation != "utf8mb4_bin" {
+		t.Errorf("Expected schema collation utf8mb4_bin, instead found %s", translated.Collation)
+	}
+	tt := translated.Tables[0]
+	if tt.Collation != "utf8mb4_general_ci" || !tt.CollationIsDefault {
+		t.Errorf("Unexpected table collation %s (default=%t)", tt.Collation, tt.CollationIsDefault)
+	}
+	if !tt.Columns[0].Invisible || !strings.Contains(tt.CreateStatement, "`actor_id` smallint(5) unsigned NOT NULL INVISIBLE AUTO_INCREMENT") {
+		t.Errorf("Invisible column not translated as expected:\n%s", tt.CreateStatement)
+	}
+	if tt.SecondaryIndexes[0].Invisible || tt.SecondaryIndexes[1].Parts[0].Descending {
+		t.Error("Expected unsupported index attributes to be removed")
+	}
+	if len(tt.Checks) != 1 || tt.Checks[0].Name != "alivecheck" {
+		t.Errorf("Unexpected checks in translated table: %+v", tt.Checks)
+	}
+	if table.Columns[0].Invisible != true || len(table.Checks) != 2 || table.SecondaryIndexes[0].Invisible != true {
+		t.Error("TranslateSchema unexpectedly modified the original table")
+	}
+	// schema collation, table collation, invisible index, descending part, NOT ENFORCED check
+	if len(issues) != 5 {
+		t.Errorf("Expected 5 issues, instead found %d: %v", len(issues), issues)
+	}
+
+	// MariaDB 10.8 supports descending index parts, and invisible indexes via
+	// its ignored index syntax, so these are retained
+	translated, issues = TranslateSchema(&schema, FlavorMySQL80, Flavor{VendorMariaDB, 10, 8, 0})
+	tt = translated.Tables[0]
+	if !tt.SecondaryIndexes[0].Invisible || !tt.SecondaryIndexes[1].Parts[0].Descending {
+		t.Errorf("Expected index attributes to be retained:\n%s", tt.CreateStatement)
+	}
+	for _, issue := range issues {
+		if strings.HasPrefix(issue.Message, "index ") {
+			t.Errorf("Unexpected issue: %s", issue)
+		}
+	}
+
+	// Translating to a flavor without check constraints or invisible columns
+	translated, issues = TranslateSchema(&schema, FlavorMySQL80, FlavorMySQL57)
+	tt = translated.Tables[0]
+	if tt.Columns[0].Invisible || len(tt.Checks) != 0 {
+		t.Errorf("Expected invisible column and checks to be removed:\n%s", tt.CreateStatement)
+	}
+	if len(issues) != 7 {
+		t.Errorf("Expected 7 issues, instead found %d: %v", len(issues), issues)
+	}
+}
+
+func TestTranslateSchemaInlineCheck(t *testing.T) {
+	table := aTableForFlavor(FlavorMariaDB105, 1)
+	table.Columns[5].CheckClause = "`alive` < 2"
+	schema := aSchema("translate", &table)
+	translated, issues := TranslateSchema(&schema, FlavorMariaDB105, Flavor{VendorMySQL, 8, 0, 16})
+	if len(issues) > 0 {
+		t.Errorf("Unexpected issues: %v", issues)
+	}
+	tt := translated.Tables[0]
+	if tt.Columns[5].CheckClause != "" || len(tt.Checks) != 1 || tt.Checks[0].Name != "actor_chk_1" || tt.Checks[0].Clause != "`alive` < 2" {
+		t.Errorf("Inline check not translated as expected: %+v", tt.Checks)
+	}
+}
+
+func TestAddDisplayWidth(t *testing.T) {
+	cases := map[string]string{
+		"tinyint":           "tinyint(4)",
+		"tinyint(1)":        "tinyint(1)",
+		"smallint unsigned": "smallint(5) unsigned",
+		"int":               "int(11)",
+		"bigint unsigned":   "bigint(20) unsigned",
+		"year":              "year(4)",
+		"varchar(20)":       "varchar(20)",
+	}
+	for input, expected := range cases {
+		if actual := addDisplayWidth(input); actual != expected {
+			t.Errorf("Expected addDisplayWidth(%q) to return %q, instead found %q", input, expected, actual)
+		}
+		if stripped := StripDisplayWidth(expected); input != "varchar(20)" && addDisplayWidth(stripped) != expected {
+			t.Errorf("addDisplayWidth is not the inverse of StripDisplayWidth for %q", expected)
+		}
+	}
+}
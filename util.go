@@ -173,6 +173,7 @@ func NormalizeCreateOptions(createStmt string) string {
 			commonDefaults := map[string]string{
 				"latin1":  "latin1_swedish_ci",
 				"utf8":    "utf8_general_ci",
+				"utf8mb3": "utf8mb3_general_ci",
 				"utf8mb4": "utf8mb4_0900_ai_ci", // No need to care about pre-8.0 different default in this situation!
 			}
 			tableCollation = commonDefaults[tableCharSet]
@@ -0,0 +1,206 @@
+package tengo
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Variables maps lowercased system variable names to their values, as reported
+// by SHOW VARIABLES. Typed accessor methods permit interpreting values as
+// numbers, booleans, or lists.
+type Variables map[string]string
+
+// Get returns the value of the named variable, and whether or not the variable
+// exists. The name is case-insensitive.
+func (vars Variables) Get(name string) (string, bool) {
+	value, ok := vars[strings.ToLower(name)]
+	return value, ok
+}
+
+// Int returns the value of the named variable as a signed integer. An error is
+// returned if the variable does not exist or is not numeric.
+func (vars Variables) Int(name string) (int64, error) {
+	value, ok := vars.Get(name)
+	if !ok {
+		return 0, fmt.Errorf("Variable %s does not exist", name)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// Uint returns the value of the named variable as an unsigned integer. An
+// error is returned if the variable does not exist or is not numeric. This is
+// useful for variables such as max_binlog_cache_size whose default exceeds the
+// range of int64.
+func (vars Variables) Uint(name string) (uint64, error) {
+	value, ok := vars.Get(name)
+	if !ok {
+		return 0, fmt.Errorf("Variable %s does not exist", name)
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// Bool returns true if the named variable exists and has a value of ON, YES,
+// TRUE, or 1, in any case.
+func (vars Variables) Bool(name string) bool {
+	value, _ := vars.Get(name)
+	return showBool(value)
+}
+
+// List returns the value of the named variable split on commas, for use with
+// variables such as sql_mode or optimizer_switch. Empty values result in an
+// empty slice.
+func (vars Variables) List(name string) []string {
+	value, _ := vars.Get(name)
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+// GlobalVariables returns all global system variables of the instance.
+func (instance *Instance) GlobalVariables() (Variables, error) {
+	return instance.queryVariables("SHOW GLOBAL VARIABLES")
+}
+
+// SessionVariables returns all session system variables of the instance. These
+// reflect any session variables set in the instance's default params, as well
+// as any server-side per-user settings.
+func (instance *Instance) SessionVariables() (Variables, error) {
+	return instance.queryVariables("SHOW SESSION VARIABLES")
+}
+
+func (instance *Instance) queryVariables(query string) (Variables, error) {
+	db, release, err := instance.leasedConnectionPool("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	rows, err := queryShowRows(db, query)
+	if err != nil {
+		return nil, fmt.Errorf("Error executing %s on %s: %s", query, instance, err)
+	}
+	vars := make(Variables, len(rows))
+	for _, row := range rows {
+		vars[strings.ToLower(row.get("Variable_name"))] = row.get("Value")
+	}
+	return vars, nil
+}
+
+// DefaultDriftIgnore lists patterns of variables which inherently differ
+// between instances, such as server identity, file paths, and per-session
+// counters. CompareVariables ignores these unless VariableDriftOptions.CompareAll
+// is set. Patterns use the syntax of path.Match.
+var DefaultDriftIgnore = []string{
+	"server_id",
+	"server_uuid",
+	"hostname",
+	"report_host",
+	"pid_file",
+	"socket",
+	"timestamp",
+	"pseudo_thread_id",
+	"rand_seed*",
+	"gtid_executed",
+	"gtid_purged",
+	"gtid_owned",
+	"gtid_binlog_pos",
+	"gtid_binlog_state",
+	"gtid_current_pos",
+	"gtid_slave_pos",
+	"gtid_next",
+	"last_insert_id",
+	"identity",
+	"insert_id",
+	"warning_count",
+	"error_count",
+	"log_error",
+	"general_log_file",
+	"slow_query_log_file",
+	"log_bin_basename",
+	"log_bin_index",
+	"relay_log*",
+	"innodb_buffer_pool_dump_status",
+	"innodb_buffer_pool_load_status",
+}
+
+// VariableDriftOptions controls the behavior of CompareVariables.
+type VariableDriftOptions struct {
+	Session    bool     // If true, compare session variables instead of global variables
+	Ignore     []string // Additional patterns of variable names to ignore, using the syntax of path.Match
+	CompareAll bool     // If true, variables in DefaultDriftIgnore are compared too
+}
+
+// ignored returns true if the variable name should not be compared.
+func (opts VariableDriftOptions) ignored(name string) bool {
+	patterns := opts.Ignore
+	if !opts.CompareAll {
+		patterns = append(append([]string{}, DefaultDriftIgnore...), opts.Ignore...)
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(strings.ToLower(pattern), name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// VariableDrift represents a system variable whose value is not identical
+// across a set of instances.
+type VariableDrift struct {
+	Name   string
+	Values []sql.NullString // one per instance, in the order supplied to CompareVariables; invalid if variable does not exist
+}
+
+// CompareVariables fetches system variables from each of the supplied
+// instances, and returns the variables whose values differ between any of
+// them, sorted by name. Variables which only exist on some of the instances
+// are also included. Configuration drift of this sort can explain why the same
+// schema change behaves differently between environments.
+func CompareVariables(instances []*Instance, opts VariableDriftOptions) ([]*VariableDrift, error) {
+	all := make([]Variables, len(instances))
+	names := make(map[string]bool)
+	for n, instance := range instances {
+		var err error
+		if opts.Session {
+			all[n], err = instance.SessionVariables()
+		} else {
+			all[n], err = instance.GlobalVariables()
+		}
+		if err != nil {
+			return nil, err
+		}
+		for name := range all[n] {
+			names[name] = true
+		}
+	}
+	return compareVariables(all, names, opts), nil
+}
+
+func compareVariables(all []Variables, names map[string]bool, opts VariableDriftOptions) []*VariableDrift {
+	result := []*VariableDrift{}
+	for name := range names {
+		if opts.ignored(name) {
+			continue
+		}
+		drift := &VariableDrift{Name: name, Values: make([]sql.NullString, len(all))}
+		var differs bool
+		for n, vars := range all {
+			value, ok := vars[name]
+			drift.Values[n] = sql.NullString{String: value, Valid: ok}
+			if n > 0 && drift.Values[n] != drift.Values[0] {
+				differs = true
+			}
+		}
+		if differs {
+			result = append(result, drift)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,114 @@
+package tengo
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestVariablesAccessors(t *testing.T) {
+	vars := Variables{
+		"wait_timeout":          "28800",
+		"max_binlog_cache_size": "18446744073709547520",
+		"read_only":             "OFF",
+		"log_bin":               "ON",
+		"sql_mode":              "STRICT_TRANS_TABLES,NO_ZERO_DATE",
+		"init_connect":          "",
+	}
+	if value, ok := vars.Get("WAIT_TIMEOUT"); !ok || value != "28800" {
+		t.Errorf("Unexpected return from Get: %q, %t", value, ok)
+	}
+	if n, err := vars.Int("wait_timeout"); n != 28800 || err != nil {
+		t.Errorf("Unexpected return from Int: %d, %v", n, err)
+	}
+	if _, err := vars.Int("max_binlog_cache_size"); err == nil {
+		t.Error("Expected Int to return out-of-range error, but err was nil")
+	}
+	if n, err := vars.Uint("max_binlog_cache_size"); n != 18446744073709547520 || err != nil {
+		t.Errorf("Unexpected return from Uint: %d, %v", n, err)
+	}
+	if _, err := vars.Int("doesnt_exist"); err == nil {
+		t.Error("Expected Int to return error for nonexistent variable, but err was nil")
+	}
+	if vars.Bool("read_only") || !vars.Bool("log_bin") || vars.Bool("doesnt_exist") {
+		t.Error("Unexpected return from Bool")
+	}
+	if modes := vars.List("sql_mode"); !reflect.DeepEqual(modes, []string{"STRICT_TRANS_TABLES", "NO_ZERO_DATE"}) {
+		t.Errorf("Unexpected return from List: %v", modes)
+	}
+	if modes := vars.List("init_connect"); len(modes) != 0 {
+		t.Errorf("Unexpected return from List: %v", modes)
+	}
+}
+
+func TestCompareVariables(t *testing.T) {
+	all := []Variables{
+		{"server_id": "1", "sql_mode": "A,B", "innodb_flush_log_at_trx_commit": "1", "long_query_time": "1.000000", "foo": "x"},
+		{"server_id": "2", "sql_mode": "A,B", "innodb_flush_log_at_trx_commit": "2", "long_query_time": "2.000000"},
+	}
+	names := make(map[string]bool)
+	for _, vars := range all {
+		for name := range vars {
+			names[name] = true
+		}
+	}
+	null := sql.NullString{}
+	str := func(s string) sql.NullString { return sql.NullString{String: s, Valid: true} }
+
+	drift := compareVariables(all, names, VariableDriftOptions{Ignore: []string{"LONG_QUERY_*"}})
+	expected := []*VariableDrift{
+		{Name: "foo", Values: []sql.NullString{str("x"), null}},
+		{Name: "innodb_flush_log_at_trx_commit", Values: []sql.NullString{str("1"), str("2")}},
+	}
+	if !reflect.DeepEqual(drift, expected) {
+		t.Errorf("Unexpected result from compareVariables: %+v", drift)
+	}
+
+	drift = compareVariables(all, names, VariableDriftOptions{CompareAll: true})
+	var foundNames []string
+	for _, d := range drift {
+		foundNames = append(foundNames, d.Name)
+	}
+	if expectNames := []string{"foo", "innodb_flush_log_at_trx_commit", "long_query_time", "server_id"}; !reflect.DeepEqual(foundNames, expectNames) {
+		t.Errorf("Unexpected drift with CompareAll: %v", foundNames)
+	}
+}
+
+func (s TengoIntegrationSuite) TestInstanceVariables(t *testing.T) {
+	global, err := s.d.GlobalVariables()
+	if err != nil {
+		t.Fatalf("Unexpected error from GlobalVariables: %v", err)
+	}
+	if version, _ := global.Get("version"); ParseVersion(version) != s.d.version {
+		t.Errorf("Unexpected version %q in global variables", version)
+	}
+	session, err := s.d.SessionVariables()
+	if err != nil {
+		t.Fatalf("Unexpected error from SessionVariables: %v", err)
+	}
+	if session.Bool("sql_log_bin") {
+		t.Error("Expected session variables to reflect default param sql_log_bin=0, but they did not")
+	}
+
+	other, err := NewInstance("mysql", s.d.DSN()+"?wait_timeout=123")
+	if err != nil {
+		t.Fatalf("Unexpected error from NewInstance: %v", err)
+	}
+	instances := []*Instance{s.d.Instance, other}
+	if drift, err := CompareVariables(instances, VariableDriftOptions{}); err != nil || len(drift) != 0 {
+		t.Errorf("Unexpected return from CompareVariables: %+v, %v", drift, err)
+	}
+	drift, err := CompareVariables(instances, VariableDriftOptions{Session: true})
+	if err != nil {
+		t.Fatalf("Unexpected error from CompareVariables: %v", err)
+	}
+	var foundWaitTimeout bool
+	for _, d := range drift {
+		if d.Name == "wait_timeout" {
+			foundWaitTimeout = (d.Values[1].String == "123")
+		}
+	}
+	if !foundWaitTimeout {
+		t.Errorf("Expected session wait_timeout drift, instead found %+v", drift)
+	}
+}
@@ -0,0 +1,265 @@
+package tengo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// vitessUnsupportedReason returns a description of why Vitess does not support
+// the supplied clause, or an empty string if it is supported.
+func vitessUnsupportedReason(clause TableAlterClause) string {
+	switch clause := clause.(type) {
+	case AddForeignKey:
+		return vitessForeignKeyReason(clause.ForeignKey)
+	case PartitionBy, RemovePartitioning:
+		return "Vitess does not support changing the partitioning of an existing table"
+	case ModifyPartitions:
+		if clause.ForDropTable {
+			return "Vitess manages dropping of tables itself, so partitions cannot be dropped beforehand"
+		}
+	}
+	return ""
+}
+
+func vitessForeignKeyReason(fk *ForeignKey) string {
+	return fmt.Sprintf("foreign key %s cannot be used, since Vitess does not support foreign keys", EscapeIdentifier(fk.Name))
+}
+
+// stripForeignKeys removes all foreign key definitions from a CREATE TABLE
+// statement. This operates on the statement text, rather than regenerating it,
+// so that it works properly even on tables with UnsupportedDDL.
+func stripForeignKeys(createStatement string) string {
+	lines := strings.Split(createStatement, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "CONSTRAINT ") && strings.Contains(trimmed, " FOREIGN KEY ") {
+			continue
+		}
+		// Final definition line must not have a trailing comma
+		if strings.HasPrefix(line, ")") && len(kept) > 0 {
+			kept[len(kept)-1] = strings.TrimSuffix(kept[len(kept)-1], ",")
+		}
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// VSchema represents the parts of a Vitess keyspace's VSchema which are
+// relevant to schema management: the vindexes, and which table columns they
+// use. It may be obtained using ParseVSchema on the VSchema's JSON, or
+// Instance.VSchema on a vtgate.
+type VSchema struct {
+	Sharded  bool                     `json:"sharded,omitempty"`
+	Vindexes map[string]*Vindex       `json:"vindexes,omitempty"`
+	Tables   map[string]*VSchemaTable `json:"tables,omitempty"`
+}
+
+// Vindex represents a Vitess vindex, which maps column values to shards.
+type Vindex struct {
+	Type   string            `json:"type"`
+	Params map[string]string `json:"params,omitempty"`
+	Owner  string            `json:"owner,omitempty"` // name of owning table, only for lookup vindexes
+}
+
+// VSchemaTable represents the VSchema configuration of a single table.
+type VSchemaTable struct {
+	Type           string          `json:"type,omitempty"` // e.g. "reference" or "sequence"; empty for normal tables
+	ColumnVindexes []*ColumnVindex `json:"column_vindexes,omitempty"`
+}
+
+// ColumnVindex associates one or more columns of a table with a vindex. The
+// first ColumnVindex of a table is its primary vindex, which determines the
+// shard that each row is stored on.
+type ColumnVindex struct {
+	Column  string   `json:"column,omitempty"`  // used for single-column vindexes
+	Columns []string `json:"columns,omitempty"` // used for multi-column vindexes
+	Name    string   `json:"name"`
+}
+
+// ColumnNames returns the names of the columns used by the vindex.
+func (cv *ColumnVindex) ColumnNames() []string {
+	if len(cv.Columns) > 0 {
+		return cv.Columns
+	} else if cv.Column != "" {
+		return []string{cv.Column}
+	}
+	return []string{}
+}
+
+// ParseVSchema parses a keyspace's VSchema from its JSON representation.
+// Unrecognized fields are ignored.
+func ParseVSchema(data []byte) (*VSchema, error) {
+	vs := &VSchema{}
+	if err := json.Unmarshal(data, vs); err != nil {
+		return nil, fmt.Errorf("Unable to parse VSchema: %s", err)
+	}
+	return vs, nil
+}
+
+// ShardingKey returns the names of the columns used by the table's primary
+// vindex, or nil if the table has no vindexes.
+func (vs *VSchema) ShardingKey(tableName string) []string {
+	vt := vs.Tables[tableName]
+	if vt == nil || len(vt.ColumnVindexes) == 0 {
+		return nil
+	}
+	return vt.ColumnVindexes[0].ColumnNames()
+}
+
+// VindexColumnDrop describes a column used by a vindex, which is being dropped
+// by a schema diff. Applying such a diff would break Vitess's routing of
+// queries to the table, so the VSchema must be updated first.
+type VindexColumnDrop struct {
+	Table   string
+	Column  string
+	Vindex  string
+	Primary bool // true if the vindex is the table's primary vindex, i.e. sharding key
+}
+
+func (vcd VindexColumnDrop) String() string {
+	kind := "vindex"
+	if vcd.Primary {
+		kind = "primary vindex"
+	}
+	return fmt.Sprintf("column %s of table %s is used by %s %s", EscapeIdentifier(vcd.Column), EscapeIdentifier(vcd.Table), kind, vcd.Vindex)
+}
+
+// Annotation returns a lint Annotation describing vcd, so that it may be
+// reported alongside the output of Lint. Dropping a column of the primary
+// vindex is an error, while other vindex columns are a warning.
+func (vcd VindexColumnDrop) Annotation() Annotation {
+	severity := SeverityWarning
+	if vcd.Primary {
+		severity = SeverityError
+	}
+	return Annotation{
+		Key:      ObjectKey{Type: ObjectTypeTable, Name: vcd.Table},
+		Rule:     "vindex-column",
+		Severity: severity,
+		Message:  vcd.String() + "; update the VSchema before dropping it",
+	}
+}
+
+// DroppedVindexColumns returns all columns used by vindexes which diff drops,
+// either directly or by dropping the entire table. This is useful for linting
+// diffs before running them against a sharded keyspace; see also
+// VindexColumnDrop.Annotation.
+func (vs *VSchema) DroppedVindexColumns(diff *SchemaDiff) []VindexColumnDrop {
+	var result []VindexColumnDrop
+	for _, td := range diff.TableDiffs {
+		if td.Type != DiffTypeAlter && td.Type != DiffTypeDrop {
+			continue
+		}
+		vt := vs.Tables[td.From.Name]
+		if vt == nil {
+			continue
+		}
+		dropped := make(map[string]bool)
+		if td.Type == DiffTypeAlter {
+			for _, clause := range td.alterClauses {
+				if dc, ok := clause.(DropColumn); ok {
+					dropped[dc.Column.Name] = true
+				}
+			}
+		}
+		for n, cv := range vt.ColumnVindexes {
+			for _, colName := range cv.ColumnNames() {
+				if td.Type == DiffTypeDrop || dropped[colName] {
+					result = append(result, VindexColumnDrop{
+						Table:   td.From.Name,
+						Column:  colName,
+						Vindex:  cv.Name,
+						Primary: n == 0,
+					})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// VSchema obtains the VSchema of a keyspace, using vtgate's SHOW VSCHEMA
+// commands. This only works if the instance is a Vitess vtgate. Vindex params
+// are not populated, since vtgate does not expose them in structured form.
+func (instance *Instance) VSchema(keyspace string) (*VSchema, error) {
+	db, release, err := instance.leasedConnectionPool(keyspace, "")
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	var tableNames []string
+	if err := db.Select(&tableNames, "SHOW VSCHEMA TABLES"); err != nil {
+		return nil, fmt.Errorf("Unable to obtain VSchema tables for keyspace %s: %s", keyspace, err)
+	}
+	sort.Strings(tableNames)
+	vs := &VSchema{
+		Vindexes: make(map[string]*Vindex),
+		Tables:   make(map[string]*VSchemaTable, len(tableNames)),
+	}
+	for _, tableName := range tableNames {
+		if tableName == "dual" {
+			continue
+		}
+		vt, err := queryVSchemaTable(db, tableName, vs.Vindexes)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to obtain VSchema vindexes for table %s.%s: %s", keyspace, tableName, err)
+		}
+		vs.Tables[tableName] = vt
+		vs.Sharded = vs.Sharded || len(vt.ColumnVindexes) > 0
+	}
+	return vs, nil
+}
+
+// queryVSchemaTable obtains the column vindexes of a table using vtgate's SHOW
+// VSCHEMA VINDEXES ON command. The definition of each vindex is added to
+// vindexes.
+func queryVSchemaTable(db *sqlx.DB, tableName string, vindexes map[string]*Vindex) (*VSchemaTable, error) {
+	rows, err := db.Queryx("SHOW VSCHEMA VINDEXES ON " + EscapeIdentifier(tableName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	vt := &VSchemaTable{}
+	for rows.Next() {
+		row := make(map[string]interface{})
+		if err := rows.MapScan(row); err != nil {
+			return nil, err
+		}
+		cv := &ColumnVindex{Name: vschemaRowString(row, "name")}
+		for _, col := range strings.Split(vschemaRowString(row, "columns"), ",") {
+			if col = strings.TrimSpace(col); col != "" {
+				cv.Columns = append(cv.Columns, col)
+			}
+		}
+		if len(cv.Columns) == 1 {
+			cv.Column, cv.Columns = cv.Columns[0], nil
+		}
+		vt.ColumnVindexes = append(vt.ColumnVindexes, cv)
+		vindexes[cv.Name] = &Vindex{
+			Type:  vschemaRowString(row, "type"),
+			Owner: vschemaRowString(row, "owner"),
+		}
+	}
+	return vt, rows.Err()
+}
+
+// vschemaRowString returns the string value of a column from a MapScan'ed row,
+// matching the column name case-insensitively.
+func vschemaRowString(row map[string]interface{}, colName string) string {
+	for k, v := range row {
+		if strings.EqualFold(k, colName) {
+			switch v := v.(type) {
+			case []byte:
+				return string(v)
+			case string:
+				return v
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,168 @@
+package tengo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatementModifiersVitessCreate(t *testing.T) {
+	table := foreignKeyTable()
+	td := NewCreateTable(&table)
+	if stmt, err := td.Statement(StatementModifiers{}); err != nil || stmt != table.CreateStatement {
+		t.Errorf("Unexpected return from Statement with VitessPermissive: %q, %v", stmt, err)
+	}
+
+	stmt, err := td.Statement(StatementModifiers{Vitess: VitessReject})
+	if !IsForbiddenDiff(err) {
+		t.Errorf("Expected ForbiddenDiffError with VitessReject, instead found %v", err)
+	} else if clauses := err.(*ForbiddenDiffError).Clauses; len(clauses) != 2 || !strings.Contains(clauses[0].Reason, "`customer_fk`") {
+		t.Errorf("Unexpected clauses in ForbiddenDiffError: %+v", clauses)
+	} else if stmt != table.CreateStatement {
+		t.Errorf("Unexpected statement with VitessReject: %s", stmt)
+	}
+
+	stmt, err = td.Statement(StatementModifiers{Vitess: VitessStrip})
+	if err != nil || strings.Contains(stmt, "FOREIGN KEY") || !strings.Contains(stmt, "KEY `customer` (`customer_id`)\n) ENGINE=InnoDB") {
+		t.Errorf("Unexpected return from Statement with VitessStrip: err=%v, stmt=\n%s", err, stmt)
+	}
+
+	// Tables without foreign keys are unaffected
+	table = aTable(1)
+	td = NewCreateTable(&table)
+	if stmt, err := td.Statement(StatementModifiers{Vitess: VitessReject}); err != nil || stmt != table.CreateStatement {
+		t.Errorf("Unexpected return from Statement with VitessReject: %q, %v", stmt, err)
+	}
+}
+
+func TestStatementModifiersVitessAlter(t *testing.T) {
+	from, to := foreignKeyTable(), foreignKeyTable()
+	from.ForeignKeys = from.ForeignKeys[0:1]
+	from.CreateStatement = from.GeneratedCreateStatement(FlavorUnknown)
+	to.Columns[1].Comment = "hello world"
+	to.CreateStatement = to.GeneratedCreateStatement(FlavorUnknown)
+	td := NewAlterTable(&from, &to)
+
+	_, err := td.Statement(StatementModifiers{Vitess: VitessReject})
+	if !IsForbiddenDiff(err) {
+		t.Fatalf("Expected ForbiddenDiffError with VitessReject, instead found %v", err)
+	} else if clauses := err.(*ForbiddenDiffError).Clauses; len(clauses) != 1 || !strings.HasPrefix(clauses[0].Clause, "ADD CONSTRAINT `product_fk`") {
+		t.Errorf("Unexpected clauses in ForbiddenDiffError: %+v", clauses)
+	}
+	stmt, err := td.Statement(StatementModifiers{Vitess: VitessStrip})
+	expected := "ALTER TABLE `warranties` MODIFY COLUMN `customer_id` int(10) unsigned DEFAULT NULL COMMENT 'hello world'"
+	if err != nil || stmt != expected {
+		t.Errorf("Unexpected return from Statement with VitessStrip: %q, %v", stmt, err)
+	}
+
+	// Dropping foreign keys is always permitted
+	td = NewAlterTable(&to, &from)
+	if _, err := td.Statement(StatementModifiers{Vitess: VitessReject}); err != nil {
+		t.Errorf("Unexpected error from Statement with VitessReject: %v", err)
+	}
+
+	// Partitioning an existing table
+	from, to = unpartitionedTable(FlavorUnknown), partitionedTable(FlavorUnknown)
+	td = NewAlterTable(&from, &to)
+	if _, err := td.Statement(StatementModifiers{Vitess: VitessReject}); !IsForbiddenDiff(err) {
+		t.Errorf("Expected ForbiddenDiffError with VitessReject, instead found %v", err)
+	}
+	if stmt, err := td.Statement(StatementModifiers{Vitess: VitessStrip}); stmt != "" || err != nil {
+		t.Errorf("Unexpected return from Statement with VitessStrip: %q, %v", stmt, err)
+	}
+}
+
+func TestStatementModifiersVitessRoutine(t *testing.T) {
+	proc := aProc("latin1_swedish_ci", "")
+	from, to := aSchema("s1"), aSchema("s1")
+	to.Routines = []*Routine{&proc}
+	diff := NewSchemaDiff(&from, &to)
+	if len(diff.RoutineDiffs) != 1 {
+		t.Fatalf("Expected 1 routine diff, instead found %d", len(diff.RoutineDiffs))
+	}
+	rd := diff.RoutineDiffs[0]
+	if _, err := rd.Statement(StatementModifiers{Vitess: VitessReject}); !IsForbiddenDiff(err) {
+		t.Errorf("Expected ForbiddenDiffError with VitessReject, instead found %v", err)
+	}
+	if stmt, err := rd.Statement(StatementModifiers{Vitess: VitessStrip}); stmt != "" || err != nil {
+		t.Errorf("Unexpected return from Statement with VitessStrip: %q, %v", stmt, err)
+	}
+}
+
+func TestStripForeignKeys(t *testing.T) {
+	table := foreignKeyTable()
+	table.Checks = []*Check{{Name: "check1", Clause: "`model` > 0", Enforced: true}}
+	input := table.GeneratedCreateStatement(FlavorMySQL80)
+	table.ForeignKeys = nil
+	expected := table.GeneratedCreateStatement(FlavorMySQL80)
+	if actual := stripForeignKeys(input); actual != expected {
+		t.Errorf("Unexpected result from stripForeignKeys\nExpected:\n%s\nFound:\n%s", expected, actual)
+	}
+	if actual := stripForeignKeys(expected); actual != expected {
+		t.Errorf("Expected stripForeignKeys to make no changes to a table without foreign keys, instead found:\n%s", actual)
+	}
+}
+
+func TestVSchema(t *testing.T) {
+	vs, err := ParseVSchema([]byte(`{
+		"sharded": true,
+		"vindexes": {
+			"hash": {"type": "hash"},
+			"warranty_product_lookup": {
+				"type": "consistent_lookup",
+				"params": {"table": "warranty_product_idx", "from": "product_line,model", "to": "keyspace_id"},
+				"owner": "warranties"
+			}
+		},
+		"tables": {
+			"warranties": {
+				"column_vindexes": [
+					{"column": "customer_id", "name": "hash"},
+					{"columns": ["product_line", "model"], "name": "warranty_product_lookup"}
+				]
+			},
+			"lookup": {"type": "reference"}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error from ParseVSchema: %v", err)
+	}
+	if !vs.Sharded || len(vs.Vindexes) != 2 || vs.Vindexes["warranty_product_lookup"].Owner != "warranties" {
+		t.Errorf("Unexpected result from ParseVSchema: %+v", vs)
+	}
+	if key := vs.ShardingKey("warranties"); len(key) != 1 || key[0] != "customer_id" {
+		t.Errorf("Unexpected sharding key: %v", key)
+	}
+	if key := vs.ShardingKey("lookup"); key != nil {
+		t.Errorf("Expected nil sharding key for table without vindexes, instead found %v", key)
+	}
+	if _, err := ParseVSchema([]byte("{")); err == nil {
+		t.Error("Expected error from ParseVSchema on invalid input, but err was nil")
+	}
+
+	// Dropping a column used in a secondary vindex, along with one that isn't
+	// used by any vindex
+	from, to := foreignKeyTable(), foreignKeyTable()
+	to.ForeignKeys = to.ForeignKeys[0:1]
+	to.SecondaryIndexes = to.SecondaryIndexes[0:1]
+	to.Columns = to.Columns[0:3]
+	to.Columns = append(to.Columns, &Column{Name: "extra", TypeInDB: "int", Nullable: true, Default: "NULL"})
+	to.CreateStatement = to.GeneratedCreateStatement(FlavorUnknown)
+	fromSchema, toSchema := aSchema("s1", &from), aSchema("s1", &to)
+	drops := vs.DroppedVindexColumns(NewSchemaDiff(&fromSchema, &toSchema))
+	if len(drops) != 1 || drops[0].Column != "model" || drops[0].Primary || drops[0].Vindex != "warranty_product_lookup" {
+		t.Errorf("Unexpected result from DroppedVindexColumns: %+v", drops)
+	} else if expected := "column `model` of table `warranties` is used by vindex warranty_product_lookup"; drops[0].String() != expected {
+		t.Errorf("Unexpected result from VindexColumnDrop.String(): %q", drops[0].String())
+	} else if a := drops[0].Annotation(); a.Severity != SeverityWarning || a.Key.Name != "warranties" || !strings.HasPrefix(a.Message, expected) {
+		t.Errorf("Unexpected result from VindexColumnDrop.Annotation(): %+v", a)
+	}
+
+	// Dropping the whole table
+	toSchema = aSchema("s1")
+	drops = vs.DroppedVindexColumns(NewSchemaDiff(&fromSchema, &toSchema))
+	if len(drops) != 3 || !drops[0].Primary || drops[0].Column != "customer_id" {
+		t.Errorf("Unexpected result from DroppedVindexColumns: %+v", drops)
+	} else if a := drops[0].Annotation(); a.Severity != SeverityError || a.Rule != "vindex-column" {
+		t.Errorf("Unexpected result from VindexColumnDrop.Annotation(): %+v", a)
+	}
+}